@@ -80,7 +80,8 @@ export type OAuth =
   // | 'grafananet' Deprecated. Key always changed to "grafana_com"
   | 'grafana_com'
   | 'azuread'
-  | 'okta';
+  | 'okta'
+  | 'stub';
 
 /** Map of enabled OAuth services and their respective names
  *
@@ -109,6 +109,9 @@ var (
 
 	// MPublicDashboardDatasourceQuerySuccess is a metric counter for successful queries labelled by datasource
 	MPublicDashboardDatasourceQuerySuccess *prometheus.CounterVec
+
+	// MOAuthRoleSync is a metric counter for oauth role sync outcomes labelled by provider
+	MOAuthRoleSync *prometheus.CounterVec
 )
 
 // Timers
@@ -130,6 +133,15 @@ var (
 
 	// MAccessEvaluationsSummary is a metric summary for loading permissions request duration when evaluating access
 	MAccessEvaluationsSummary prometheus.Histogram
+
+	// MAnnotationsAuthzDuration is a metric histogram for the duration of resolving the dashboards a user can read annotations of, labelled by org bucket
+	MAnnotationsAuthzDuration *prometheus.HistogramVec
+
+	// MAnnotationsAuthzPages is a metric histogram for the number of dashboard pages fetched when resolving the dashboards a user can read annotations of, labelled by org bucket
+	MAnnotationsAuthzPages *prometheus.HistogramVec
+
+	// MAnnotationsAuthzDashboards is a metric histogram for the number of dashboards a user can read annotations of, labelled by org bucket
+	MAnnotationsAuthzDashboards *prometheus.HistogramVec
 )
 
 // StatTotals
@@ -446,6 +458,12 @@ func init() {
 		Namespace: ExporterName,
 	}, []string{"datasource", "status"}, map[string][]string{"status": pubdash.QueryResultStatuses})
 
+	MOAuthRoleSync = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      "oauth_role_sync_total",
+		Help:      "counter for oauth role sync outcomes labelled by provider and outcome matched/empty/invalid_role",
+		Namespace: ExporterName,
+	}, []string{"provider", "outcome"})
+
 	MStatTotalDashboards = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name:      "stat_totals_dashboard",
 		Help:      "total amount of dashboards",
@@ -566,6 +584,27 @@ func init() {
 		Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
 	})
 
+	MAnnotationsAuthzDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:      "annotations_authz_duration_seconds",
+		Help:      "histogram for the duration of resolving the dashboards a user can read annotations of, labelled by org bucket",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 9),
+		Namespace: ExporterName,
+	}, []string{"org"})
+
+	MAnnotationsAuthzPages = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:      "annotations_authz_dashboard_pages",
+		Help:      "histogram for the number of dashboard pages fetched when resolving the dashboards a user can read annotations of, labelled by org bucket",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+		Namespace: ExporterName,
+	}, []string{"org"})
+
+	MAnnotationsAuthzDashboards = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:      "annotations_authz_visible_dashboards",
+		Help:      "histogram for the number of dashboards a user can read annotations of, labelled by org bucket",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+		Namespace: ExporterName,
+	}, []string{"org"})
+
 	MAccessEvaluationCount = prometheus.NewCounter(prometheus.CounterOpts{
 		Name:      "access_evaluation_count",
 		Help:      "number of evaluation calls",
@@ -689,6 +728,9 @@ func initMetricVars() {
 		MRenderingQueue,
 		MAccessPermissionsSummary,
 		MAccessEvaluationsSummary,
+		MAnnotationsAuthzDuration,
+		MAnnotationsAuthzPages,
+		MAnnotationsAuthzDashboards,
 		MAlertingActiveAlerts,
 		MStatTotalDashboards,
 		MStatTotalFolders,
@@ -714,6 +756,7 @@ func initMetricVars() {
 		MStatTotalPublicDashboards,
 		MPublicDashboardRequestCount,
 		MPublicDashboardDatasourceQuerySuccess,
+		MOAuthRoleSync,
 		MStatTotalCorrelations,
 	)
 }
@@ -70,6 +70,10 @@ func NewAzureADProvider(settings map[string]any, cfg *setting.Cfg, features *fea
 		return nil, err
 	}
 
+	if _, err := newTLSClientConfig(info); err != nil {
+		return nil, err
+	}
+
 	config := createOAuthConfig(info, cfg, azureADProviderName)
 	provider := &SocialAzureAD{
 		SocialBase:           newSocialBase(azureADProviderName, config, info, cfg.AutoAssignOrgRole, cfg.OAuthSkipOrgRoleUpdateSync, *features),
@@ -85,6 +89,10 @@ func NewAzureADProvider(settings map[string]any, cfg *setting.Cfg, features *fea
 		appendUniqueScope(config, OfflineAccessScope)
 	}
 
+	if err := provider.compileAttributePaths(); err != nil {
+		return nil, err
+	}
+
 	return provider, nil
 }
 
@@ -113,13 +121,13 @@ func (s *SocialAzureAD) UserInfo(ctx context.Context, client *http.Client, token
 	var role roletype.RoleType
 	var grafanaAdmin bool
 	if !s.skipOrgRoleSync {
-		role, grafanaAdmin, err = s.extractRoleAndAdmin(claims)
+		role, grafanaAdmin, err = s.extractRoleAndAdmin(claims, s.graphGroups(ctx, client))
 		if err != nil {
 			return nil, err
 		}
 
 		if !role.IsValid() {
-			return nil, errInvalidRole.Errorf("AzureAD OAuth: invalid role %q", role)
+			return nil, ErrInvalidRole.Errorf("AzureAD OAuth: invalid role %q", role)
 		}
 	}
 	s.log.Debug("AzureAD OAuth: extracted role", "email", email, "role", role)
@@ -142,7 +150,7 @@ func (s *SocialAzureAD) UserInfo(ctx context.Context, client *http.Client, token
 		s.log.Debug("AllowAssignGrafanaAdmin and skipOrgRoleSync are both set, Grafana Admin role will not be synced, consider setting one or the other")
 	}
 
-	return &BasicUserInfo{
+	userInfo := &BasicUserInfo{
 		Id:             claims.ID,
 		Name:           claims.Name,
 		Email:          email,
@@ -150,7 +158,10 @@ func (s *SocialAzureAD) UserInfo(ctx context.Context, client *http.Client, token
 		Role:           role,
 		IsGrafanaAdmin: isGrafanaAdmin,
 		Groups:         groups,
-	}, nil
+	}
+	s.setProviderIdentity(userInfo)
+
+	return userInfo, nil
 }
 
 func (s *SocialAzureAD) GetOAuthInfo() *OAuthInfo {
@@ -227,8 +238,13 @@ func (claims *azureClaims) extractEmail() string {
 }
 
 // extractRoleAndAdmin extracts the role from the claims and returns the role and whether the user is a Grafana admin.
-func (s *SocialAzureAD) extractRoleAndAdmin(claims *azureClaims) (org.RoleType, bool, error) {
+// When the claims don't carry an app role, role_attribute_path is evaluated against the groups fetched from Microsoft Graph.
+func (s *SocialAzureAD) extractRoleAndAdmin(claims *azureClaims, graphGroups []string) (org.RoleType, bool, error) {
 	if len(claims.Roles) == 0 {
+		if role, grafanaAdmin, ok := s.extractRoleFromGraphGroups(graphGroups); ok {
+			return role, grafanaAdmin, nil
+		}
+
 		if s.roleAttributeStrict {
 			return "", false, errRoleAttributeStrictViolation.Errorf("AzureAD OAuth: unset role")
 		}
@@ -247,6 +263,10 @@ func (s *SocialAzureAD) extractRoleAndAdmin(claims *azureClaims) (org.RoleType,
 		}
 	}
 
+	if role, grafanaAdmin, ok := s.extractRoleFromGraphGroups(graphGroups); ok {
+		return role, grafanaAdmin, nil
+	}
+
 	if s.roleAttributeStrict {
 		return "", false, errRoleAttributeStrictViolation.Errorf("AzureAD OAuth: idP did not return a valid role %q", claims.Roles)
 	}
@@ -254,6 +274,31 @@ func (s *SocialAzureAD) extractRoleAndAdmin(claims *azureClaims) (org.RoleType,
 	return s.defaultRole(), false, nil
 }
 
+// extractRoleFromGraphGroups evaluates role_attribute_path against the groups fetched from Microsoft Graph.
+func (s *SocialAzureAD) extractRoleFromGraphGroups(groups []string) (org.RoleType, bool, bool) {
+	if s.roleAttributePath == "" || len(groups) == 0 {
+		return "", false, false
+	}
+
+	groupBytes, err := json.Marshal(groupStruct{groups})
+	if err != nil {
+		return "", false, false
+	}
+
+	for _, path := range splitAttributePaths(s.roleAttributePath) {
+		attr, err := s.searchJSONForStringAttr(path, groupBytes)
+		if err != nil || attr == "" {
+			continue
+		}
+
+		if role, grafanaAdmin := getRoleFromSearch(attr); role.IsValid() {
+			return role, grafanaAdmin, true
+		}
+	}
+
+	return "", false, false
+}
+
 func hasRole(roles []string, role org.RoleType) bool {
 	for _, item := range roles {
 		if strings.EqualFold(item, string(role)) {
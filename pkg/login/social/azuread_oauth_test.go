@@ -18,6 +18,7 @@ import (
 
 	"github.com/grafana/grafana/pkg/infra/remotecache"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/org"
 	"github.com/grafana/grafana/pkg/setting"
 )
 
@@ -954,3 +955,31 @@ func TestSocialAzureAD_InitializeExtraFields(t *testing.T) {
 		})
 	}
 }
+
+func TestSocialAzureAD_ExtractRoleFromGraphGroups(t *testing.T) {
+	s, err := NewAzureADProvider(map[string]any{
+		"role_attribute_path": "contains(groups[*], 'admins-id') && 'Admin' || contains(groups[*], 'devs-id') && 'Editor'",
+		"use_graph_member_of": "true",
+	}, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures(), nil)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		appRoles     []string
+		graphGroups  []string
+		expectedRole org.RoleType
+	}{
+		{name: "should map the role from the Graph groups", graphGroups: []string{"devs-id"}, expectedRole: org.RoleEditor},
+		{name: "should prefer the app role over the Graph groups", appRoles: []string{"Viewer"}, graphGroups: []string{"admins-id"}, expectedRole: org.RoleViewer},
+		{name: "should use the default role without Graph groups", graphGroups: []string{}, expectedRole: org.RoleViewer},
+		{name: "should use the default role when no Graph group matches", graphGroups: []string{"other-id"}, expectedRole: org.RoleViewer},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			role, _, err := s.extractRoleAndAdmin(&azureClaims{Roles: tc.appRoles}, tc.graphGroups)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedRole, role)
+		})
+	}
+}
@@ -1,15 +1,28 @@
 package social
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/grafana/grafana/pkg/setting"
 	"github.com/grafana/grafana/pkg/util"
@@ -19,17 +32,36 @@ import (
 	"gopkg.in/ini.v1"
 )
 
+const (
+	defaultGraphMemberOfURL = "https://graph.microsoft.com/v1.0/me/memberOf"
+	graphMemberOfTimeout    = 10 * time.Second
+	// graphMemberOfMaxPages guards against endless @odata.nextLink chains
+	graphMemberOfMaxPages = 100
+
+	// defaultPaginationMaxPages guards against endless Link rel="next" chains
+	defaultPaginationMaxPages = 100
+
+	defaultUserInfoRetryBaseDelay = 500 * time.Millisecond
+	defaultRolePolicyTimeout      = 5 * time.Second
+
+	// defaultUserInfoCacheJitter spreads the expiration of cached user info responses by ±10%
+	defaultUserInfoCacheJitter = 0.1
+)
+
 var (
 	errMissingGroupMembership = &Error{"user not a member of one of the required groups"}
+
+	linkNextPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
 )
 
 type httpGetResponse struct {
-	Body    []byte
-	Headers http.Header
+	Body       []byte
+	Headers    http.Header
+	StatusCode int
 }
 
 func (s *SocialBase) IsEmailAllowed(email string) bool {
-	return isEmailAllowed(email, s.allowedDomains)
+	return !isEmailBlocked(email, s.blockedDomains) && isEmailAllowed(email, s.allowedDomains)
 }
 
 func (s *SocialBase) IsSignupAllowed() bool {
@@ -50,12 +82,85 @@ func isEmailAllowed(email string, allowedDomains []string) bool {
 	return valid
 }
 
+func isEmailBlocked(email string, blockedDomains []string) bool {
+	for _, domain := range blockedDomains {
+		if strings.HasSuffix(strings.ToLower(email), strings.ToLower("@"+domain)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// httpGetPages gets url and the pages it links to with RFC 5988 Link rel="next" headers, passing the body of
+// each page to onPage. It fails when there are more than pagination_max_pages pages.
+func (s *SocialBase) httpGetPages(ctx context.Context, client *http.Client, url string, onPage func(body []byte) error) error {
+	firstURL := url
+	for page := 0; url != ""; page++ {
+		if page >= s.paginationMaxPages {
+			return fmt.Errorf("too many pages returned by %s", firstURL)
+		}
+
+		response, err := s.httpGet(ctx, client, url)
+		if err != nil {
+			return err
+		}
+
+		if err := onPage(response.Body); err != nil {
+			return err
+		}
+
+		url = nextPageURL(response.Headers)
+	}
+
+	return nil
+}
+
+// nextPageURL returns the URL of the next page from the Link headers, or "" on the last page.
+func nextPageURL(headers http.Header) string {
+	for _, value := range headers.Values("Link") {
+		if matches := linkNextPattern.FindStringSubmatch(value); matches != nil {
+			return matches[1]
+		}
+	}
+	return ""
+}
+
+// httpGetStatusError is returned by httpGet for unsuccessful response status codes.
+type httpGetStatusError struct {
+	statusCode int
+	headers    http.Header
+	body       []byte
+}
+
+// maxErrorBodySnippet is the maximum length of the response body included in an httpGetStatusError message.
+const maxErrorBodySnippet = 256
+
+// Error returns the status code with a redacted snippet of the body, since the message ends up in logs and errors.
+func (e *httpGetStatusError) Error() string {
+	return fmt.Sprintf("unsuccessful response status code %d: %s", e.statusCode, bodySnippet(e.body))
+}
+
+// bodySnippet returns the body redacted by redactBody and truncated to maxErrorBodySnippet bytes.
+func bodySnippet(body []byte) string {
+	snippet := redactBody(body)
+	if len(snippet) <= maxErrorBodySnippet {
+		return snippet
+	}
+	return strings.ToValidUTF8(snippet[:maxErrorBodySnippet], "") + "..."
+}
+
 func (s *SocialBase) httpGet(ctx context.Context, client *http.Client, url string) (*httpGetResponse, error) {
 	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if errReq != nil {
 		return nil, errReq
 	}
 
+	return s.httpDo(client, req)
+}
+
+// httpDo sends req and reads the response, returning an httpGetStatusError for unsuccessful status codes.
+func (s *SocialBase) httpDo(client *http.Client, req *http.Request) (*httpGetResponse, error) {
 	r, errDo := client.Do(req)
 	if errDo != nil {
 		return nil, errDo
@@ -67,22 +172,552 @@ func (s *SocialBase) httpGet(ctx context.Context, client *http.Client, url strin
 		}
 	}()
 
-	body, errRead := io.ReadAll(r.Body)
+	body, errRead := readResponseBody(r)
 	if errRead != nil {
 		return nil, errRead
 	}
 
-	response := &httpGetResponse{body, r.Header}
+	response := &httpGetResponse{body, r.Header, r.StatusCode}
 
 	if r.StatusCode >= 300 {
-		return nil, fmt.Errorf("unsuccessful response status code %d: %s", r.StatusCode, string(response.Body))
+		return nil, &httpGetStatusError{statusCode: r.StatusCode, headers: r.Header, body: response.Body}
 	}
 
-	s.log.Debug("HTTP GET", "url", url, "status", r.Status, "response_body", string(response.Body))
+	s.log.Debug("HTTP "+req.Method, "url", req.URL.String(), "status", r.Status)
 
 	return response, nil
 }
 
+// readResponseBody reads the body of r, decompressing gzip and deflate encoded bodies. The transport only
+// decompresses them itself when it requested the compression, not when the request or a wrapping transport
+// set Accept-Encoding explicitly.
+func readResponseBody(r *http.Response) ([]byte, error) {
+	var reader io.Reader = r.Body
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress gzip response: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		reader = gz
+	case "deflate":
+		zr, err := zlib.NewReader(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress deflate response: %w", err)
+		}
+		defer func() { _ = zr.Close() }()
+		reader = zr
+	}
+
+	return io.ReadAll(reader)
+}
+
+// CheckHealth sends a HEAD request to the token and user info endpoints to check that the IdP is reachable,
+// e.g. after a configuration change. Client error responses, such as 405 from a token endpoint only accepting
+// POST, still prove the endpoint is reachable. It returns ErrIdPUnreachable when an endpoint can't be reached
+// or returns a server error.
+func (s *SocialBase) CheckHealth(ctx context.Context, client *http.Client) error {
+	for _, url := range []string{s.Endpoint.TokenURL, s.info.ApiUrl} {
+		if url == "" {
+			continue
+		}
+
+		if err := s.checkEndpointHealth(ctx, client, url); err != nil {
+			return ErrIdPUnreachable.Errorf("endpoint %s is unhealthy: %w", url, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SocialBase) checkEndpointHealth(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			s.log.Warn("Failed to close response body", "err", err)
+		}
+	}()
+
+	if r.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unsuccessful response status code %d", r.StatusCode)
+	}
+
+	s.log.Debug("HTTP HEAD", "url", url, "status", r.Status)
+
+	return nil
+}
+
+type graphMemberOfResponse struct {
+	Value []struct {
+		ID string `json:"id"`
+	} `json:"value"`
+	NextLink string `json:"@odata.nextLink"`
+}
+
+// fetchGraphMemberOfGroups returns the IDs of the groups the user is a member of, following the
+// @odata.nextLink pagination of the Microsoft Graph memberOf endpoint until it is exhausted.
+// It returns nil when use_graph_member_of is not set.
+func (s *SocialBase) fetchGraphMemberOfGroups(ctx context.Context, client *http.Client) ([]string, error) {
+	if s.graphMemberOfURL == "" {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, graphMemberOfTimeout)
+	defer cancel()
+
+	groups := []string{}
+	url := s.graphMemberOfURL
+	for page := 0; url != ""; page++ {
+		if page >= graphMemberOfMaxPages {
+			return nil, fmt.Errorf("too many pages returned by %s", s.graphMemberOfURL)
+		}
+
+		response, err := s.httpGet(ctx, client, url)
+		if err != nil {
+			return nil, fmt.Errorf("error getting groups from Microsoft Graph: %w", err)
+		}
+
+		var data graphMemberOfResponse
+		if err := json.Unmarshal(response.Body, &data); err != nil {
+			return nil, fmt.Errorf("error decoding Microsoft Graph groups response: %w", err)
+		}
+
+		for _, group := range data.Value {
+			if group.ID != "" {
+				groups = append(groups, group.ID)
+			}
+		}
+		url = data.NextLink
+	}
+
+	return groups, nil
+}
+
+// graphGroups returns the groups from Microsoft Graph. It returns no groups if they can't be fetched
+// so that role mapping falls back to the role from the token and user info.
+func (s *SocialBase) graphGroups(ctx context.Context, client *http.Client) []string {
+	groups, err := s.fetchGraphMemberOfGroups(ctx, client)
+	if err != nil {
+		s.log.Warn("Failed to fetch groups from Microsoft Graph, ignoring them for role mapping", "err", err)
+		return []string{}
+	}
+	if groups == nil {
+		return []string{}
+	}
+	return groups
+}
+
+// userInfoGet fetches the user info from url. When userinfo_cache_ttl is set, responses are cached
+// per access token so that concurrent requests with the same token only hit the IdP once.
+func (s *SocialBase) userInfoGet(ctx context.Context, client *http.Client, token *oauth2.Token, url string) (response *httpGetResponse, err error) {
+	ctx, span := s.startSpan(ctx, "social.fetchUserInfo")
+	defer func() { endSpan(span, err) }()
+
+	if s.userInfoCache == nil || token == nil || token.AccessToken == "" {
+		return s.userInfoGetUncached(ctx, client, token, url)
+	}
+
+	key := userInfoCacheKey(token.AccessToken, url)
+	if cached, ok := s.userInfoCache.Get(key); ok {
+		s.log.Debug("Using cached user info response", "url", url)
+		return cached.(*httpGetResponse), nil
+	}
+
+	response, err = s.userInfoGetUncached(ctx, client, token, url)
+	if err != nil {
+		return nil, err
+	}
+
+	s.userInfoCache.Set(key, response, s.userInfoCacheExpiration())
+	return response, nil
+}
+
+// userInfoCacheExpiration returns userinfo_cache_ttl randomly adjusted by up to userinfo_cache_jitter of it,
+// so that responses cached at the same time don't all expire at once and stampede the IdP.
+func (s *SocialBase) userInfoCacheExpiration() time.Duration {
+	if s.userInfoCacheJitter == 0 {
+		return s.userInfoCacheTTL
+	}
+	jitter := (2*rand.Float64() - 1) * s.userInfoCacheJitter * float64(s.userInfoCacheTTL)
+	return s.userInfoCacheTTL + time.Duration(jitter)
+}
+
+// userInfoGetUncached fetches the user info from url, failing fast with ErrUserInfoCircuitOpen while the
+// userinfo_breaker_failures circuit breaker is open.
+func (s *SocialBase) userInfoGetUncached(ctx context.Context, client *http.Client, token *oauth2.Token, url string) (*httpGetResponse, error) {
+	if s.userInfoBreaker != nil && !s.userInfoBreaker.allow() {
+		return nil, ErrUserInfoCircuitOpen.Errorf("user info requests to %s are short-circuited after repeated failures", url)
+	}
+
+	response, err := s.userInfoFetchWithRetry(ctx, client, token, url)
+	if s.userInfoBreaker != nil {
+		// only outages of the IdP open the breaker, not responses rejecting the user or the token
+		s.userInfoBreaker.record(err != nil && ctx.Err() == nil && isRetryableHTTPGetError(err))
+	}
+	s.traceUserInfo(url, response, err)
+	if err != nil {
+		return nil, ErrUserInfoFetch.Errorf("failed to get user info from %s: %w", url, err)
+	}
+	return response, nil
+}
+
+// traceUserInfo logs the user info request and its response with sensitive values redacted when trace_userinfo is set.
+func (s *SocialBase) traceUserInfo(url string, response *httpGetResponse, err error) {
+	if !s.traceUserInfoCalls {
+		return
+	}
+
+	var statusErr *httpGetStatusError
+	switch {
+	case response != nil:
+		s.log.Debug("User info trace", "url", url, "status", response.StatusCode, "response_body", redactBody(response.Body))
+	case errors.As(err, &statusErr):
+		s.log.Debug("User info trace", "url", url, "status", statusErr.statusCode, "response_body", redactBody(statusErr.body))
+	default:
+		s.log.Debug("User info trace", "url", url, "error", err)
+	}
+}
+
+// sensitiveKeys are the substrings of JSON keys whose values are masked by redactBody.
+var sensitiveKeys = []string{"email", "token", "secret", "password", "upn"}
+
+// redactBody returns the JSON body with the values of sensitive keys masked. Non JSON bodies are not logged.
+func redactBody(body []byte) string {
+	var data any
+	if err := json.Unmarshal(body, &data); err != nil {
+		return fmt.Sprintf("<%d bytes, not JSON>", len(body))
+	}
+
+	redacted, err := json.Marshal(redactValue(data))
+	if err != nil {
+		return fmt.Sprintf("<%d bytes>", len(body))
+	}
+	return string(redacted)
+}
+
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, val := range v {
+			if isSensitiveKey(key) {
+				v[key] = "[REDACTED]"
+			} else {
+				v[key] = redactValue(val)
+			}
+		}
+	case []any:
+		for i, val := range v {
+			v[i] = redactValue(val)
+		}
+	}
+	return value
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// userInfoFetch sends the user info request to url. It is a GET by default, or a POST with the form body
+// rendered from userinfo_body_template when userinfo_method is POST.
+func (s *SocialBase) userInfoFetch(ctx context.Context, client *http.Client, token *oauth2.Token, url string) (*httpGetResponse, error) {
+	if s.userInfoMethod != http.MethodPost {
+		return s.httpGet(ctx, client, url)
+	}
+
+	body, err := s.userInfoBody(token)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return s.httpDo(client, req)
+}
+
+// userInfoBody renders the form body of POST user info requests. The access token is form encoded before it is
+// injected, so that it can't alter the other fields of the body.
+func (s *SocialBase) userInfoBody(token *oauth2.Token) (string, error) {
+	tmpl := s.userInfoBodyTemplate
+	if tmpl == nil {
+		tmpl = template.Must(template.New("userinfo_body_template").Parse(defaultUserInfoBodyTemplate))
+	}
+
+	var accessToken string
+	if token != nil {
+		accessToken = token.AccessToken
+	}
+
+	var body strings.Builder
+	if err := tmpl.Execute(&body, struct{ AccessToken string }{url.QueryEscape(accessToken)}); err != nil {
+		return "", fmt.Errorf("failed to render userinfo_body_template: %w", err)
+	}
+	return body.String(), nil
+}
+
+// userInfoFetchWithRetry retries userInfoFetch up to userinfo_max_retries times with an exponential backoff
+// starting at userinfo_retry_base_delay. Only network errors and 5xx or 429 responses are retried,
+// honoring Retry-After on 429. It gives up early when the context ends before the next attempt.
+func (s *SocialBase) userInfoFetchWithRetry(ctx context.Context, client *http.Client, token *oauth2.Token, url string) (*httpGetResponse, error) {
+	for attempt := 0; ; attempt++ {
+		response, err := s.userInfoFetch(ctx, client, token, url)
+		if err == nil || attempt >= s.userInfoMaxRetries || ctx.Err() != nil || !isRetryableHTTPGetError(err) {
+			return response, err
+		}
+
+		delay := retryDelay(err, s.userInfoRetryBaseDelay, attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return nil, err
+		}
+
+		s.log.Debug("Retrying user info request", "url", url, "attempt", attempt+1, "delay", delay, "err", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// isNotFoundError returns true if err is caused by a 404 response.
+func isNotFoundError(err error) bool {
+	var statusErr *httpGetStatusError
+	return errors.As(err, &statusErr) && statusErr.statusCode == http.StatusNotFound
+}
+
+// isRetryableHTTPGetError returns true if err is caused by a 5xx or 429 response, or by the transport, e.g. a
+// refused connection or a timeout of the client. Errors ending the request context, reading or decoding the
+// response, and rendering the request are not retried.
+func isRetryableHTTPGetError(err error) bool {
+	var statusErr *httpGetStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode >= 500 || statusErr.statusCode == http.StatusTooManyRequests
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// the errors of the client are *url.Error, which is a net.Error as well
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryDelay returns the Retry-After delay of a 429 response if present, otherwise baseDelay * 2^attempt.
+func retryDelay(err error, baseDelay time.Duration, attempt int) time.Duration {
+	var statusErr *httpGetStatusError
+	if errors.As(err, &statusErr) && statusErr.statusCode == http.StatusTooManyRequests {
+		if retryAfter := statusErr.headers.Get("Retry-After"); retryAfter != "" {
+			if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+				return time.Duration(seconds) * time.Second
+			}
+			if date, err := http.ParseTime(retryAfter); err == nil {
+				return max(time.Until(date), 0)
+			}
+		}
+	}
+	return baseDelay << attempt
+}
+
+// userInfoCacheKey hashes the access token so that it is never kept in memory by the cache.
+func userInfoCacheKey(accessToken, url string) string {
+	hash := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(hash[:]) + ":" + url
+}
+
+// isEmptyBody returns true if the body is empty or only contains whitespace.
+func isEmptyBody(body []byte) bool {
+	return len(bytes.TrimSpace(body)) == 0
+}
+
+// parseXMLToMap decodes an XML document into a generic map keyed by the root element name.
+// Child elements and attributes become keys of their parent, elements repeated under the
+// same parent become arrays and elements without children hold their trimmed text.
+func parseXMLToMap(data []byte) (map[string]any, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("no root element found")
+			}
+			return nil, err
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			value, err := decodeXMLElement(decoder, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{start.Name.Local: value}, nil
+		}
+	}
+}
+
+func decodeXMLElement(decoder *xml.Decoder, start xml.StartElement) (any, error) {
+	children := map[string]any{}
+	for _, attr := range start.Attr {
+		children[attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			value, err := decodeXMLElement(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			switch existing := children[t.Name.Local].(type) {
+			case nil:
+				children[t.Name.Local] = value
+			case []any:
+				children[t.Name.Local] = append(existing, value)
+			default:
+				children[t.Name.Local] = []any{existing, value}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if len(children) == 0 {
+				return strings.TrimSpace(text.String()), nil
+			}
+			return children, nil
+		}
+	}
+}
+
+// splitAttributePaths splits a comma or newline separated list of JMESPath expressions.
+// Separators nested in brackets, parentheses, braces or quotes belong to the expression,
+// so a single expression such as "contains(groups[*], 'admin') && 'Admin'" is kept whole.
+// Backslash escaped quotes don't end a quoted identifier or literal, e.g. in "a\"b".
+func splitAttributePaths(paths string) []string {
+	var result []string
+	var depth int
+	var quote rune
+	var escaped bool
+	start := 0
+
+	appendPath := func(path string) {
+		if path = strings.TrimSpace(path); path != "" {
+			result = append(result, path)
+		}
+	}
+
+	for i, r := range paths {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(' || r == '[' || r == '{':
+			depth++
+		case r == ')' || r == ']' || r == '}':
+			depth--
+		case (r == ',' || r == '\n') && depth == 0:
+			appendPath(paths[start:i])
+			start = i + 1
+		}
+	}
+	appendPath(paths[start:])
+
+	return result
+}
+
+// compileAttributePath returns the compiled attribute path, compiling it on first use.
+func (s *SocialBase) compileAttributePath(attributePath string) (*jmespath.JMESPath, error) {
+	if compiled, ok := s.compiledPaths.Load(attributePath); ok {
+		return compiled.(*jmespath.JMESPath), nil
+	}
+
+	compiled, err := compileJMESPath(attributePath)
+	if err != nil {
+		return nil, err
+	}
+
+	s.compiledPaths.Store(attributePath, compiled)
+	return compiled, nil
+}
+
+func compileJMESPath(attributePath string) (*jmespath.JMESPath, error) {
+	compiled, err := jmespath.Compile(attributePath)
+	if err != nil {
+		return nil, ErrAttributePath.Errorf("invalid attribute path %q: %w", attributePath, err)
+	}
+	return compiled, nil
+}
+
+// ValidateAttributePath returns ErrAttributePath when expr, or one of the comma or newline separated paths
+// it falls back to, is not a valid JMESPath expression. It doesn't need a token, so that attribute path
+// settings can be validated as they are entered.
+func ValidateAttributePath(expr string) error {
+	for _, path := range splitAttributePaths(expr) {
+		if _, err := compileJMESPath(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileAttributePaths compiles the configured attribute paths up front when jmespath_strict is set,
+// so that an invalid path fails the provider construction rather than every login.
+func (s *SocialBase) compileAttributePaths() error {
+	if !s.jmespathStrict {
+		return nil
+	}
+
+	paths := append(splitAttributePaths(s.roleAttributePath), splitAttributePaths(s.info.EmailAttributePath)...)
+	paths = append(paths,
+		s.grafanaAdminPath,
+		s.teamsAttributePath,
+		s.info.GroupsAttributePath,
+		s.info.TeamIdsAttributePath,
+		s.info.Extra["login_attribute_path"],
+		s.info.Extra["name_attribute_path"],
+	)
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if _, err := s.compileAttributePath(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *SocialBase) searchJSONForAttr(attributePath string, data []byte) (any, error) {
 	if attributePath == "" {
 		return "", errors.New("no attribute path specified")
@@ -97,7 +732,12 @@ func (s *SocialBase) searchJSONForAttr(attributePath string, data []byte) (any,
 		return "", fmt.Errorf("%v: %w", "failed to unmarshal user info JSON response", err)
 	}
 
-	val, err := jmespath.Search(attributePath, buf)
+	path, err := s.compileAttributePath(attributePath)
+	if err != nil {
+		return "", err
+	}
+
+	val, err := path.Search(buf)
 	if err != nil {
 		return "", fmt.Errorf("failed to search user info JSON response with provided path: %q: %w", attributePath, err)
 	}
@@ -166,6 +806,15 @@ func createOAuthConfig(info *OAuthInfo, cfg *setting.Cfg, defaultName string) *o
 	return &config
 }
 
+// validateScopes returns an error when the scopes setting is provided but lists no scope, which would send an
+// authorization request without the scopes the IdP needs. empty_scopes is the way to request no scopes.
+func validateScopes(settingsKV map[string]any, info *OAuthInfo) error {
+	if _, provided := settingsKV["scopes"]; !provided || info.EmptyScopes || len(info.Scopes) > 0 {
+		return nil
+	}
+	return fmt.Errorf("scopes must not be empty, set empty_scopes to request no scopes")
+}
+
 func mustBool(value any, defaultValue bool) bool {
 	if value == nil {
 		return defaultValue
@@ -215,6 +864,17 @@ func createOAuthInfoFromKeyValues(settingsKV map[string]any) (*OAuthInfo, error)
 		return data, nil
 	}
 
+	// role_attribute_strict = highest and role_attribute_strict = error are strict as well
+	highest, ambiguous := false, false
+	if strict, ok := settingsKV["role_attribute_strict"].(string); ok {
+		highest = strings.EqualFold(strict, roleAttributeStrictHighest)
+		ambiguous = strings.EqualFold(strict, roleAttributeStrictError)
+		if highest || ambiguous {
+			settingsKV = maps.Clone(settingsKV)
+			settingsKV["role_attribute_strict"] = true
+		}
+	}
+
 	var oauthInfo OAuthInfo
 	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
 		DecodeHook:       emptyStrToSliceDecodeHook,
@@ -234,6 +894,8 @@ func createOAuthInfoFromKeyValues(settingsKV map[string]any) (*OAuthInfo, error)
 	if oauthInfo.EmptyScopes {
 		oauthInfo.Scopes = []string{}
 	}
+	oauthInfo.RoleAttributeHighest = highest
+	oauthInfo.RoleAttributeAmbiguous = ambiguous
 
 	return &oauthInfo, err
 }
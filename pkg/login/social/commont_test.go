@@ -1,12 +1,36 @@
 package social
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	jose "github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
 	"gopkg.in/ini.v1"
+
+	"github.com/grafana/grafana/pkg/infra/log/logtest"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
 )
 
+// createTestIDToken returns an HS256 signed JWT carrying the given claims.
+func createTestIDToken(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("test-signing-key-for-id-tokens!!")}, (&jose.SignerOptions{}).WithType("JWT"))
+	require.NoError(t, err)
+
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+
+	return token
+}
+
 func TestMapping_IniSectionOAuthInfo(t *testing.T) {
 	iniContent := `
 [test]
@@ -99,3 +123,287 @@ hosted_domain = test_hosted_domain
 
 	require.Equal(t, expectedOAuthInfo, oauthInfo)
 }
+
+func TestParseXMLToMap(t *testing.T) {
+	tests := []struct {
+		name        string
+		xml         string
+		expected    map[string]any
+		expectedErr bool
+	}{
+		{
+			name: "Should parse elements, attributes and repeated elements",
+			xml:  `<attributes source="saml"><role>Editor</role><group>a</group><group>b</group></attributes>`,
+			expected: map[string]any{
+				"attributes": map[string]any{
+					"source": "saml",
+					"role":   "Editor",
+					"group":  []any{"a", "b"},
+				},
+			},
+		},
+		{
+			name:     "Should parse a single text element",
+			xml:      `<role> Admin </role>`,
+			expected: map[string]any{"role": "Admin"},
+		},
+		{
+			name:        "Should fail on malformed XML",
+			xml:         `<attributes><role>Editor</attributes>`,
+			expectedErr: true,
+		},
+		{
+			name:        "Should fail without a root element",
+			xml:         `not xml`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := parseXMLToMap([]byte(tt.xml))
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestSplitAttributePaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		paths    string
+		expected []string
+	}{
+		{name: "empty", paths: "", expected: nil},
+		{name: "single path", paths: "role", expected: []string{"role"}},
+		{name: "comma separated", paths: "role, roles[0],custom.grafana_role", expected: []string{"role", "roles[0]", "custom.grafana_role"}},
+		{name: "newline separated", paths: "role\n roles[0]\n", expected: []string{"role", "roles[0]"}},
+		{
+			name:     "commas in function arguments and literals",
+			paths:    "contains(groups[*], 'a,b') && 'Admin', [role, other] | [0], `[1, 2]`",
+			expected: []string{"contains(groups[*], 'a,b') && 'Admin'", "[role, other] | [0]", "`[1, 2]`"},
+		},
+		{
+			name:     "commas in quoted identifiers",
+			paths:    `"https://grafana/roles,v2"[0], role`,
+			expected: []string{`"https://grafana/roles,v2"[0]`, "role"},
+		},
+		{
+			name:     "escaped quotes in quoted identifiers",
+			paths:    `"grafana\"roles,v2"[0], role`,
+			expected: []string{`"grafana\"roles,v2"[0]`, "role"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, splitAttributePaths(tt.paths))
+		})
+	}
+}
+
+func TestValidateAttributePath(t *testing.T) {
+	tests := []struct {
+		name        string
+		expr        string
+		expectedErr string
+	}{
+		{name: "empty", expr: ""},
+		{name: "single path", expr: "role"},
+		{name: "expression", expr: "contains(groups[*], 'admin') && 'Admin' || 'Viewer'"},
+		{name: "fallback paths", expr: "role, roles[0]\ncustom.grafana_role"},
+		{name: "incomplete expression", expr: "[", expectedErr: `invalid attribute path "[": SyntaxError: Incomplete expression`},
+		{name: "invalid fallback path", expr: "role, roles[", expectedErr: `invalid attribute path "roles["`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAttributePath(tt.expr)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrAttributePath)
+			require.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestSocialBase_TraceUserInfo(t *testing.T) {
+	body := `{"email": "john.doe@example.com", "name": "John Doe", "access_token": "secret-token", "identities": [{"id_token": "nested-token", "provider": "idp"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		if request.URL.Path == "/error" {
+			writer.WriteHeader(http.StatusUnauthorized)
+		}
+		_, err := writer.Write([]byte(body))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		traceUserInfo  string
+		path           string
+		expectedTraced bool
+		expectedStatus int
+	}{
+		{name: "should not trace when trace_userinfo is off", path: "/user"},
+		{name: "should trace a successful response", traceUserInfo: "true", path: "/user", expectedTraced: true, expectedStatus: http.StatusOK},
+		{name: "should trace an error response", traceUserInfo: "true", path: "/error", expectedTraced: true, expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &OAuthInfo{Extra: map[string]string{"trace_userinfo": tt.traceUserInfo}}
+			provider := newSocialBase("trace", &oauth2.Config{}, info, "", false, *featuremgmt.WithFeatures())
+			logger := &logtest.Fake{}
+			provider.log = logger
+
+			_, _ = provider.userInfoGet(context.Background(), server.Client(), &oauth2.Token{}, server.URL+tt.path)
+
+			logged := fmt.Sprint(logger.DebugLogs.Ctx...)
+			require.NotContains(t, logged, "john.doe@example.com")
+			require.NotContains(t, logged, "secret-token")
+			require.NotContains(t, logged, "nested-token")
+			if !tt.expectedTraced {
+				require.NotEqual(t, "User info trace", logger.DebugLogs.Message)
+				return
+			}
+
+			require.Equal(t, "User info trace", logger.DebugLogs.Message)
+			require.Equal(t, []any{
+				"url", server.URL + tt.path,
+				"status", tt.expectedStatus,
+				"response_body", `{"access_token":"[REDACTED]","email":"[REDACTED]","identities":[{"id_token":"[REDACTED]","provider":"idp"}],"name":"John Doe"}`,
+			}, logger.DebugLogs.Ctx)
+		})
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	require.Equal(t, "<8 bytes, not JSON>", redactBody([]byte("not json")))
+	require.Equal(t, `{"Email":"[REDACTED]","sub":"1"}`, redactBody([]byte(`{"Email": "john.doe@example.com", "sub": "1"}`)))
+}
+
+func TestSocialBase_CheckHealth(t *testing.T) {
+	statuses := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		require.Equal(t, http.MethodHead, request.Method)
+		writer.WriteHeader(statuses[request.URL.Path])
+	}))
+	defer server.Close()
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	tests := []struct {
+		name        string
+		tokenURL    string
+		apiURL      string
+		statuses    map[string]int
+		expectedErr error
+	}{
+		{
+			name:     "should succeed when the endpoints respond",
+			tokenURL: server.URL + "/token",
+			apiURL:   server.URL + "/user",
+			statuses: map[string]int{"/token": http.StatusOK, "/user": http.StatusOK},
+		},
+		{
+			name:     "should succeed when the token endpoint only accepts POST",
+			tokenURL: server.URL + "/token",
+			apiURL:   server.URL + "/user",
+			statuses: map[string]int{"/token": http.StatusMethodNotAllowed, "/user": http.StatusUnauthorized},
+		},
+		{
+			name:     "should skip unconfigured endpoints",
+			tokenURL: server.URL + "/token",
+			statuses: map[string]int{"/token": http.StatusOK},
+		},
+		{
+			name:        "should fail when an endpoint returns a server error",
+			tokenURL:    server.URL + "/token",
+			apiURL:      server.URL + "/user",
+			statuses:    map[string]int{"/token": http.StatusOK, "/user": http.StatusServiceUnavailable},
+			expectedErr: ErrIdPUnreachable,
+		},
+		{
+			name:        "should fail when an endpoint is unreachable",
+			tokenURL:    unreachable.URL + "/token",
+			expectedErr: ErrIdPUnreachable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statuses = tt.statuses
+			info := &OAuthInfo{TokenUrl: tt.tokenURL, ApiUrl: tt.apiURL}
+			provider := newSocialBase("generic_oauth", &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: tt.tokenURL}}, info, "", false, *featuremgmt.WithFeatures())
+
+			err := provider.CheckHealth(context.Background(), server.Client())
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("should respect context cancellation", func(t *testing.T) {
+		statuses = map[string]int{"/token": http.StatusOK}
+		info := &OAuthInfo{TokenUrl: server.URL + "/token"}
+		provider := newSocialBase("generic_oauth", &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: info.TokenUrl}}, info, "", false, *featuremgmt.WithFeatures())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := provider.CheckHealth(ctx, server.Client())
+		require.ErrorIs(t, err, ErrIdPUnreachable)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestSocialBase_UserInfoCacheExpiration(t *testing.T) {
+	newProvider := func(extra map[string]string) *SocialBase {
+		return newSocialBase("generic_oauth", &oauth2.Config{}, &OAuthInfo{Extra: extra}, "", false, *featuremgmt.WithFeatures())
+	}
+
+	t.Run("should spread the expirations within ±10% of the ttl by default", func(t *testing.T) {
+		provider := newProvider(map[string]string{"userinfo_cache_ttl": "10m"})
+
+		expirations := map[time.Duration]struct{}{}
+		for i := 0; i < 1000; i++ {
+			expiration := provider.userInfoCacheExpiration()
+			require.GreaterOrEqual(t, expiration, 9*time.Minute)
+			require.LessOrEqual(t, expiration, 11*time.Minute)
+			expirations[expiration] = struct{}{}
+		}
+		require.Greater(t, len(expirations), 1, "expirations are spread")
+	})
+
+	t.Run("should use the configured jitter", func(t *testing.T) {
+		provider := newProvider(map[string]string{"userinfo_cache_ttl": "10m", "userinfo_cache_jitter": "0.5"})
+
+		for i := 0; i < 1000; i++ {
+			expiration := provider.userInfoCacheExpiration()
+			require.GreaterOrEqual(t, expiration, 5*time.Minute)
+			require.LessOrEqual(t, expiration, 15*time.Minute)
+		}
+	})
+
+	t.Run("should not spread the expirations when the jitter is disabled", func(t *testing.T) {
+		provider := newProvider(map[string]string{"userinfo_cache_ttl": "10m", "userinfo_cache_jitter": "0"})
+		require.Equal(t, 10*time.Minute, provider.userInfoCacheExpiration())
+	})
+
+	t.Run("should use the default jitter when it is invalid", func(t *testing.T) {
+		require.Equal(t, defaultUserInfoCacheJitter, newProvider(map[string]string{"userinfo_cache_jitter": "1.5"}).userInfoCacheJitter)
+		require.Equal(t, defaultUserInfoCacheJitter, newProvider(map[string]string{"userinfo_cache_jitter": "-0.1"}).userInfoCacheJitter)
+		require.Equal(t, defaultUserInfoCacheJitter, newProvider(map[string]string{"userinfo_cache_jitter": "ten"}).userInfoCacheJitter)
+	})
+}
@@ -0,0 +1,45 @@
+package social
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a copy of ctx carrying id, which is sent to the IdP in the correlation_header of
+// the requests made with ctx.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID set on ctx with WithCorrelationID.
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// correlationTransport sets the header on the requests to the IdP to the correlation ID of the request
+// context, or to a generated UUID when there is none, so that the requests can be traced in the IdP logs.
+type correlationTransport struct {
+	header string
+	next   http.RoundTripper
+}
+
+func (t *correlationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get(t.header) != "" {
+		return t.next.RoundTrip(req)
+	}
+
+	id, ok := CorrelationIDFromContext(req.Context())
+	if !ok {
+		id = uuid.NewString()
+	}
+
+	// a RoundTripper must not modify the request it was given
+	req = req.Clone(req.Context())
+	req.Header.Set(t.header, id)
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,85 @@
+package social
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestCorrelationHeader(t *testing.T) {
+	tests := []struct {
+		name              string
+		correlationHeader string
+		correlationID     string
+		expectGenerated   bool
+	}{
+		{
+			name:              "should send the correlation ID of the context",
+			correlationHeader: "x-request-id",
+			correlationID:     "request-1234",
+		},
+		{
+			name:              "should generate a correlation ID when the context has none",
+			correlationHeader: "X-Request-ID",
+			expectGenerated:   true,
+		},
+		{
+			name: "should not send a correlation header by default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{}
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				headers[request.URL.Path] = request.Header.Get("X-Request-ID")
+				writer.Header().Set("Content-Type", "application/json")
+				body := `{"email": "john.doe@example.com"}`
+				if request.URL.Path == "/token" {
+					body = `{"access_token": "access-token", "token_type": "Bearer"}`
+				}
+				_, err := writer.Write([]byte(body))
+				require.NoError(t, err)
+			}))
+			defer server.Close()
+
+			settings := map[string]any{
+				"api_url":            server.URL + "/user",
+				"token_url":          server.URL + "/token",
+				"correlation_header": tt.correlationHeader,
+			}
+			provider, err := NewGenericOAuthProvider(settings, setting.NewCfg(), featuremgmt.WithFeatures())
+			require.NoError(t, err)
+			client, err := newHTTPClient(provider.info)
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			if tt.correlationID != "" {
+				ctx = WithCorrelationID(ctx, tt.correlationID)
+			}
+
+			token, err := provider.Exchange(context.WithValue(ctx, oauth2.HTTPClient, client), "code")
+			require.NoError(t, err)
+			_, err = provider.UserInfo(ctx, client, token)
+			require.NoError(t, err)
+
+			for _, path := range []string{"/token", "/user"} {
+				switch {
+				case tt.expectGenerated:
+					_, err := uuid.Parse(headers[path])
+					require.NoError(t, err, "generated correlation ID of %s", path)
+				default:
+					require.Equal(t, tt.correlationID, headers[path], "correlation ID of %s", path)
+				}
+			}
+		})
+	}
+}
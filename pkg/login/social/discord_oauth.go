@@ -0,0 +1,215 @@
+package social
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2"
+
+	"github.com/grafana/grafana/pkg/models/roletype"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+const (
+	discordProviderName = "discord"
+	// defaultDiscordAPIURL is the Discord endpoint returning the current user, the guilds are fetched from its /guilds subpath.
+	defaultDiscordAPIURL = "https://discord.com/api/users/@me"
+)
+
+type SocialDiscord struct {
+	*SocialBase
+	apiUrl          string
+	skipOrgRoleSync bool
+}
+
+type DiscordUserInfoJson struct {
+	ID         string         `json:"id"`
+	Username   string         `json:"username"`
+	GlobalName string         `json:"global_name"`
+	Email      string         `json:"email"`
+	Verified   bool           `json:"verified"`
+	Guilds     []DiscordGuild `json:"guilds"`
+	rawJSON    []byte
+}
+
+type DiscordGuild struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Owner bool   `json:"owner"`
+}
+
+func NewDiscordProvider(settings map[string]any, cfg *setting.Cfg, features *featuremgmt.FeatureManager) (*SocialDiscord, error) {
+	info, err := createOAuthInfoFromKeyValues(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := newTLSClientConfig(info); err != nil {
+		return nil, err
+	}
+
+	apiUrl := strings.TrimSuffix(info.ApiUrl, "/")
+	if apiUrl == "" {
+		apiUrl = defaultDiscordAPIURL
+	}
+
+	config := createOAuthConfig(info, cfg, discordProviderName)
+	provider := &SocialDiscord{
+		SocialBase: newSocialBase(discordProviderName, config, info, cfg.AutoAssignOrgRole, cfg.OAuthSkipOrgRoleUpdateSync, *features),
+		apiUrl:     apiUrl,
+		// FIXME: Move skipOrgRoleSync to OAuthInfo
+		// skipOrgRoleSync: info.SkipOrgRoleSync
+		skipOrgRoleSync: cfg.DiscordSkipOrgRoleSync,
+	}
+
+	if err := provider.compileEmailAllowedRegex(); err != nil {
+		return nil, err
+	}
+
+	if err := provider.validateNullRoleFallback(); err != nil {
+		return nil, err
+	}
+
+	if err := provider.compileAttributePaths(); err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+// UserInfo fetches the current user and their guilds, and evaluates role_attribute_path against the user
+// with the guilds added under "guilds". The guild IDs are the groups of the user, for allowed_groups and
+// group_role_mapping.
+func (s *SocialDiscord) UserInfo(ctx context.Context, client *http.Client, token *oauth2.Token) (*BasicUserInfo, error) {
+	data, err := s.extractAPI(ctx, client, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Email == "" {
+		return nil, ErrEmailNotFound
+	}
+
+	if !data.Verified {
+		return nil, errEmailNotAllowed.Errorf("email %q is not verified by Discord", data.Email)
+	}
+
+	if err := s.checkEmailAllowedRegex(data.Email); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkEmailDomain(data.Email); err != nil {
+		return nil, err
+	}
+
+	groups := s.GetGroups(data)
+	if !s.isGroupMember(groups) {
+		return nil, errMissingGroupMembership
+	}
+
+	var role roletype.RoleType
+	var isGrafanaAdmin *bool
+	if !s.skipOrgRoleSync {
+		var grafanaAdmin bool
+		role, grafanaAdmin, err = s.extractRoleAndAdmin(data.rawJSON, groups)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.allowAssignGrafanaAdmin {
+			isGrafanaAdmin = &grafanaAdmin
+		}
+	}
+	if s.allowAssignGrafanaAdmin && s.skipOrgRoleSync {
+		s.log.Debug("AllowAssignGrafanaAdmin and skipOrgRoleSync are both set, Grafana Admin role will not be synced, consider setting one or the other")
+	}
+
+	name := data.GlobalName
+	if name == "" {
+		name = data.Username
+	}
+
+	userInfo := &BasicUserInfo{
+		Id:             data.ID,
+		Name:           name,
+		Email:          data.Email,
+		Login:          data.Username,
+		Role:           role,
+		IsGrafanaAdmin: isGrafanaAdmin,
+		Groups:         groups,
+	}
+	s.setProviderIdentity(userInfo)
+
+	return userInfo, nil
+}
+
+// extractAPI fetches the user from api_url and their guilds from its /guilds subpath, and combines them into
+// one JSON document.
+func (s *SocialDiscord) extractAPI(ctx context.Context, client *http.Client, token *oauth2.Token) (*DiscordUserInfoJson, error) {
+	userResponse, err := s.userInfoGet(ctx, client, token, s.apiUrl)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user info response: %w", err)
+	}
+
+	var user map[string]any
+	if err := json.Unmarshal(userResponse.Body, &user); err != nil {
+		return nil, fmt.Errorf("error decoding user info response: %w", err)
+	}
+
+	guildsURL := s.apiUrl + "/guilds"
+	guildsResponse, err := s.userInfoGet(ctx, client, token, guildsURL)
+	if err != nil {
+		return nil, fmt.Errorf("error getting guilds response: %w", err)
+	}
+
+	var guilds []any
+	if err := json.Unmarshal(guildsResponse.Body, &guilds); err != nil {
+		return nil, fmt.Errorf("error decoding guilds response: %w", err)
+	}
+
+	if user == nil {
+		user = map[string]any{}
+	}
+	user["guilds"] = guilds
+
+	rawJSON, err := json.Marshal(user)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding user info with guilds: %w", err)
+	}
+
+	var data DiscordUserInfoJson
+	if err := json.Unmarshal(rawJSON, &data); err != nil {
+		return nil, fmt.Errorf("error decoding user info with guilds: %w", err)
+	}
+	data.rawJSON = rawJSON
+
+	s.log.Debug("Received user info response", "raw_json", string(data.rawJSON))
+	return &data, nil
+}
+
+// GetGroups returns the IDs of the guilds the user is a member of.
+func (s *SocialDiscord) GetGroups(data *DiscordUserInfoJson) []string {
+	groups := make([]string, 0, len(data.Guilds))
+	for _, guild := range data.Guilds {
+		groups = append(groups, guild.ID)
+	}
+	return groups
+}
+
+func (s *SocialDiscord) SupportBundleContent(bf *bytes.Buffer) error {
+	bf.WriteString("## Discord specific configuration\n\n")
+	bf.WriteString("```ini\n")
+	bf.WriteString(fmt.Sprintf("api_url = %s\n", s.apiUrl))
+	bf.WriteString("```\n\n")
+
+	return s.SocialBase.SupportBundleContent(bf)
+}
+
+func (s *SocialDiscord) GetOAuthInfo() *OAuthInfo {
+	return s.info
+}
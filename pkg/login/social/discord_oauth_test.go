@@ -0,0 +1,172 @@
+package social
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/grafana/grafana/pkg/models/roletype"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestSocialDiscord_UserInfo(t *testing.T) {
+	var boolPointer *bool
+
+	const userRawJSON = `{ "id": "80351110224678912", "username": "nelly", "global_name": "Nelly", "email": "nelly@discord.com", "verified": true, "role": "Editor" }`
+	const guildsRawJSON = `[{ "id": "197038439483310086", "name": "Grafana Community", "owner": false }, { "id": "41771983423143937", "name": "Admins", "owner": true }]`
+
+	tests := []struct {
+		name                    string
+		userRawJSON             string
+		guildsRawJSON           string
+		settingSkipOrgRoleSync  bool
+		allowAssignGrafanaAdmin bool
+		RoleAttributePath       string
+		AllowedGroups           string
+		GroupRoleMapping        string
+		ExpectedEmail           string
+		ExpectedLogin           string
+		ExpectedName            string
+		ExpectedRole            roletype.RoleType
+		ExpectedGrafanaAdmin    *bool
+		ExpectedGroups          []string
+		ExpectedErr             error
+	}{
+		{
+			name:                 "Should give role from the user JSON",
+			userRawJSON:          userRawJSON,
+			guildsRawJSON:        guildsRawJSON,
+			RoleAttributePath:    "role",
+			ExpectedEmail:        "nelly@discord.com",
+			ExpectedLogin:        "nelly",
+			ExpectedName:         "Nelly",
+			ExpectedRole:         "Editor",
+			ExpectedGrafanaAdmin: boolPointer,
+			ExpectedGroups:       []string{"197038439483310086", "41771983423143937"},
+		},
+		{
+			name:                    "Should give role from the guilds in the combined JSON",
+			userRawJSON:             userRawJSON,
+			guildsRawJSON:           guildsRawJSON,
+			RoleAttributePath:       "guilds[?owner] && 'Admin' || 'Viewer'",
+			allowAssignGrafanaAdmin: true,
+			ExpectedEmail:           "nelly@discord.com",
+			ExpectedLogin:           "nelly",
+			ExpectedName:            "Nelly",
+			ExpectedRole:            "Admin",
+			ExpectedGrafanaAdmin:    falseBoolPtr(),
+			ExpectedGroups:          []string{"197038439483310086", "41771983423143937"},
+		},
+		{
+			name:                 "Should give role from the guild role mapping",
+			userRawJSON:          userRawJSON,
+			guildsRawJSON:        guildsRawJSON,
+			GroupRoleMapping:     "41771983423143937=Admin",
+			ExpectedEmail:        "nelly@discord.com",
+			ExpectedLogin:        "nelly",
+			ExpectedName:         "Nelly",
+			ExpectedRole:         "Admin",
+			ExpectedGrafanaAdmin: boolPointer,
+			ExpectedGroups:       []string{"197038439483310086", "41771983423143937"},
+		},
+		{
+			name:                   "Should give empty role when skip org role sync is enabled",
+			userRawJSON:            userRawJSON,
+			guildsRawJSON:          guildsRawJSON,
+			RoleAttributePath:      "role",
+			settingSkipOrgRoleSync: true,
+			ExpectedEmail:          "nelly@discord.com",
+			ExpectedLogin:          "nelly",
+			ExpectedName:           "Nelly",
+			ExpectedRole:           "",
+			ExpectedGrafanaAdmin:   boolPointer,
+			ExpectedGroups:         []string{"197038439483310086", "41771983423143937"},
+		},
+		{
+			name:                 "Should fall back to the username for the name",
+			userRawJSON:          `{ "id": "80351110224678912", "username": "nelly", "email": "nelly@discord.com", "verified": true }`,
+			guildsRawJSON:        `[]`,
+			ExpectedEmail:        "nelly@discord.com",
+			ExpectedLogin:        "nelly",
+			ExpectedName:         "nelly",
+			ExpectedRole:         "Viewer",
+			ExpectedGrafanaAdmin: boolPointer,
+			ExpectedGroups:       []string{},
+		},
+		{
+			name:          "Should deny users outside the allowed guilds",
+			userRawJSON:   userRawJSON,
+			guildsRawJSON: guildsRawJSON,
+			AllowedGroups: "1234",
+			ExpectedErr:   errMissingGroupMembership,
+		},
+		{
+			name:          "Should deny users without a verified email",
+			userRawJSON:   `{ "id": "80351110224678912", "username": "nelly", "email": "nelly@discord.com", "verified": false }`,
+			guildsRawJSON: guildsRawJSON,
+			ExpectedErr:   errEmailNotAllowed,
+		},
+		{
+			name:          "Should deny users without an email",
+			userRawJSON:   `{ "id": "80351110224678912", "username": "nelly" }`,
+			guildsRawJSON: guildsRawJSON,
+			ExpectedErr:   ErrEmailNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set("Content-Type", "application/json")
+				switch {
+				case strings.HasSuffix(request.URL.Path, "/users/@me/guilds"):
+					_, err := writer.Write([]byte(tt.guildsRawJSON))
+					require.NoError(t, err)
+				case strings.HasSuffix(request.URL.Path, "/users/@me"):
+					_, err := writer.Write([]byte(tt.userRawJSON))
+					require.NoError(t, err)
+				default:
+					writer.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			provider, err := NewDiscordProvider(
+				map[string]any{
+					"api_url":                    server.URL + "/api/users/@me",
+					"role_attribute_path":        tt.RoleAttributePath,
+					"allow_assign_grafana_admin": tt.allowAssignGrafanaAdmin,
+					"allowed_groups":             tt.AllowedGroups,
+					"group_role_mapping":         tt.GroupRoleMapping,
+				},
+				&setting.Cfg{
+					DiscordSkipOrgRoleSync: tt.settingSkipOrgRoleSync,
+					AutoAssignOrgRole:      "Viewer",
+				},
+				featuremgmt.WithFeatures())
+			require.NoError(t, err)
+
+			token := &oauth2.Token{AccessToken: "access-token", Expiry: time.Now().Add(time.Hour)}
+			got, err := provider.UserInfo(context.Background(), server.Client(), token)
+			if tt.ExpectedErr != nil {
+				require.ErrorIs(t, err, tt.ExpectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			require.Equal(t, "80351110224678912", got.Id)
+			require.Equal(t, tt.ExpectedEmail, got.Email)
+			require.Equal(t, tt.ExpectedLogin, got.Login)
+			require.Equal(t, tt.ExpectedName, got.Name)
+			require.Equal(t, tt.ExpectedRole, got.Role)
+			require.Equal(t, tt.ExpectedGrafanaAdmin, got.IsGrafanaAdmin)
+			require.Equal(t, tt.ExpectedGroups, got.Groups)
+		})
+	}
+}
@@ -16,6 +16,145 @@ var (
 	errRoleAttributeStrictViolation = errutil.BadRequest("oauth.role_attribute_strict_violation",
 		errutil.WithPublicMessage("IdP did not return a role attribute, please contact your administrator"))
 
-	errInvalidRole = errutil.BadRequest("oauth.invalid_role",
+	// ErrInvalidRole is returned when the IdP or the role policy service returns a role that is not a valid org role.
+	ErrInvalidRole = errutil.BadRequest("oauth.invalid_role",
 		errutil.WithPublicMessage("IdP did not return a valid role attribute, please contact your administrator"))
+
+	// ErrAmbiguousRole is returned with role_attribute_strict = error when role_attribute_path resolves to several
+	// distinct roles. The candidates are listed in the error message.
+	ErrAmbiguousRole = errutil.BadRequest("oauth.ambiguous_role",
+		errutil.WithPublicMessage("IdP returned several roles, please contact your administrator"))
+
+	// ErrNoneRoleDenied is returned with none_role_behavior = deny when role_attribute_path resolves to the None role.
+	ErrNoneRoleDenied = errutil.Forbidden("oauth.none_role_denied",
+		errutil.WithPublicMessage("You are not allowed to sign in, please contact your administrator"))
+
+	// ErrUserInfoFetch is returned when the user info could not be fetched from the IdP.
+	ErrUserInfoFetch = errutil.BadGateway("oauth.user_info_fetch_failed",
+		errutil.WithPublicMessage("Failed to get user info from the IdP, please try again later"))
+
+	// ErrMissingAccessToken is returned when the user info must be fetched but the token has no access token.
+	ErrMissingAccessToken = errutil.Unauthorized("oauth.missing_access_token",
+		errutil.WithPublicMessage("IdP did not return an access token, please contact your administrator"))
+
+	// ErrUserInfoCircuitOpen is returned without contacting the IdP while the user info circuit breaker is open.
+	ErrUserInfoCircuitOpen = errutil.BadGateway("oauth.user_info_circuit_open",
+		errutil.WithPublicMessage("The IdP is unavailable, please try again later"))
+
+	// ErrIdPUnreachable is returned by CheckHealth when an IdP endpoint can't be reached.
+	ErrIdPUnreachable = errutil.BadGateway("oauth.idp_unreachable",
+		errutil.WithPublicMessage("The IdP could not be reached, please try again later"))
+
+	// ErrAttributePath is returned when an attribute path can't be evaluated against the user info.
+	ErrAttributePath = errutil.BadRequest("oauth.attribute_path_invalid",
+		errutil.WithPublicMessage("An attribute path is misconfigured, please contact your administrator"))
+
+	// ErrInvalidProviderSettings is returned when a provider can't be constructed from its settings. It wraps every
+	// validation failure, which are also listed under "causes" in the public payload.
+	ErrInvalidProviderSettings = errutil.BadRequest("oauth.invalid_provider_settings",
+		errutil.WithPublicMessage("The provider is misconfigured, please contact your administrator"))
+
+	errRoleAttributePathNotScalar = errutil.BadRequest("oauth.role_attribute_path_not_scalar",
+		errutil.WithPublicMessage("Role attribute path is misconfigured, please contact your administrator"))
+
+	errInvalidNestedJWT = errutil.BadRequest("oauth.invalid_nested_jwt",
+		errutil.WithPublicMessage("IdP returned an invalid nested token, please contact your administrator"))
+
+	errInvalidXMLClaim = errutil.BadRequest("oauth.invalid_xml_claim",
+		errutil.WithPublicMessage("IdP returned an invalid XML attribute, please contact your administrator"))
+
+	errEmptyUserInfo = errutil.BadRequest("oauth.empty_user_info",
+		errutil.WithPublicMessage("IdP returned an empty user info response, please contact your administrator"))
+
+	errEmailNotAllowed = errutil.Forbidden("oauth.email_not_allowed",
+		errutil.WithPublicMessage("Your email is not allowed to sign in, please contact your administrator"))
+
+	errEmailDomainNotAllowed = errutil.Forbidden("oauth.email_domain_not_allowed",
+		errutil.WithPublicMessage("Your email domain is not allowed to sign in, please contact your administrator"))
+
+	errIssuerNotAllowed = errutil.Forbidden("oauth.issuer_not_allowed",
+		errutil.WithPublicMessage("IdP token issuer is not allowed, please contact your administrator"))
+
+	errAudienceNotAllowed = errutil.Forbidden("oauth.audience_not_allowed",
+		errutil.WithPublicMessage("IdP token audience is not allowed, please contact your administrator"))
+
+	// ErrInvalidIDToken is returned when the id_token is malformed or fails verification.
+	ErrInvalidIDToken = errutil.Unauthorized("oauth.invalid_id_token",
+		errutil.WithPublicMessage("IdP returned an invalid id_token, please contact your administrator"))
+
+	errInvalidRefreshedToken = errutil.Unauthorized("oauth.invalid_refreshed_token",
+		errutil.WithPublicMessage("Your session could not be refreshed, please sign in again"))
+
+	errRolePolicy = errutil.BadGateway("oauth.role_policy_failed",
+		errutil.WithPublicMessage("Role policy service failed, please contact your administrator"))
+
+	// ErrStepUpRequired is returned when the acr of the id_token does not satisfy required_acr.
+	// The required acr is available in the public payload under "requiredAcr" so the
+	// login handler can redirect the user to re-authenticate.
+	ErrStepUpRequired = errutil.Unauthorized("oauth.step_up_required").MustTemplate(
+		"id_token acr {{ .Private.acr }} does not satisfy required acr {{ .Public.requiredAcr }}",
+		errutil.WithPublic("Additional authentication is required, please sign in again"),
+	)
 )
+
+// DenialReason is a machine-readable code describing why a connector denied a login.
+type DenialReason string
+
+const (
+	DenialReasonMissingEmail           DenialReason = "missing_email"
+	DenialReasonEmailNotAllowed        DenialReason = "email_not_allowed"
+	DenialReasonGroupMembership        DenialReason = "group_membership"
+	DenialReasonTeamMembership         DenialReason = "team_membership"
+	DenialReasonOrganizationMembership DenialReason = "organization_membership"
+	DenialReasonIssuerNotAllowed       DenialReason = "issuer_not_allowed"
+	DenialReasonAudienceNotAllowed     DenialReason = "audience_not_allowed"
+	DenialReasonStepUpRequired         DenialReason = "step_up_required"
+	DenialReasonInvalidRole            DenialReason = "invalid_role"
+	DenialReasonAmbiguousRole          DenialReason = "ambiguous_role"
+	DenialReasonNoneRole               DenialReason = "none_role"
+	DenialReasonMissingRole            DenialReason = "missing_role"
+	DenialReasonInvalidIDToken         DenialReason = "invalid_id_token"
+)
+
+var denialReasons = []struct {
+	err    error
+	reason DenialReason
+}{
+	{ErrEmailNotFound, DenialReasonMissingEmail},
+	{errEmailNotAllowed, DenialReasonEmailNotAllowed},
+	{errEmailDomainNotAllowed, DenialReasonEmailNotAllowed},
+	{errMissingGroupMembership, DenialReasonGroupMembership},
+	{ErrMissingTeamMembership, DenialReasonTeamMembership},
+	{ErrMissingOrganizationMembership, DenialReasonOrganizationMembership},
+	{errIssuerNotAllowed, DenialReasonIssuerNotAllowed},
+	{errAudienceNotAllowed, DenialReasonAudienceNotAllowed},
+	{ErrStepUpRequired, DenialReasonStepUpRequired},
+	{ErrInvalidRole, DenialReasonInvalidRole},
+	{ErrAmbiguousRole, DenialReasonAmbiguousRole},
+	{ErrNoneRoleDenied, DenialReasonNoneRole},
+	{errRoleAttributeStrictViolation, DenialReasonMissingRole},
+	{ErrInvalidIDToken, DenialReasonInvalidIDToken},
+}
+
+// GetDenialReason returns the reason a login was denied, or false if err is not a login denial.
+func GetDenialReason(err error) (DenialReason, bool) {
+	for _, d := range denialReasons {
+		if errors.Is(err, d.err) {
+			return d.reason, true
+		}
+	}
+	return "", false
+}
+
+// newInvalidProviderSettingsError aggregates the validation failures of a provider's settings into a single
+// ErrInvalidProviderSettings so that all of them can be fixed at once.
+func newInvalidProviderSettingsError(provider string, errs []error) error {
+	causes := make([]string, 0, len(errs))
+	for _, err := range errs {
+		causes = append(causes, err.Error())
+	}
+
+	err := ErrInvalidProviderSettings.Errorf("invalid %s settings: %w", provider, errors.Join(errs...))
+	err.PublicPayload = map[string]any{"causes": causes}
+	return err
+}
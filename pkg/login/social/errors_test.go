@@ -0,0 +1,212 @@
+package social
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+func TestGetDenialReason(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedReason DenialReason
+		expectedOK     bool
+	}{
+		{
+			name:           "missing email",
+			err:            ErrEmailNotFound,
+			expectedReason: DenialReasonMissingEmail,
+			expectedOK:     true,
+		},
+		{
+			name:           "email not allowed",
+			err:            errEmailNotAllowed.Errorf("email %q is not allowed", "john.doe@example.com"),
+			expectedReason: DenialReasonEmailNotAllowed,
+			expectedOK:     true,
+		},
+		{
+			name:           "allowed groups",
+			err:            errMissingGroupMembership,
+			expectedReason: DenialReasonGroupMembership,
+			expectedOK:     true,
+		},
+		{
+			name:           "team membership",
+			err:            ErrMissingTeamMembership.Errorf("user not a member of one of the required teams"),
+			expectedReason: DenialReasonTeamMembership,
+			expectedOK:     true,
+		},
+		{
+			name:           "organization membership",
+			err:            ErrMissingOrganizationMembership.Errorf("user not a member of one of the required organizations"),
+			expectedReason: DenialReasonOrganizationMembership,
+			expectedOK:     true,
+		},
+		{
+			name:           "issuer not allowed",
+			err:            errIssuerNotAllowed.Errorf("id_token issuer is not in allowed_issuers"),
+			expectedReason: DenialReasonIssuerNotAllowed,
+			expectedOK:     true,
+		},
+		{
+			name:           "audience not allowed",
+			err:            errAudienceNotAllowed.Errorf("id_token audience is not in allowed_audiences"),
+			expectedReason: DenialReasonAudienceNotAllowed,
+			expectedOK:     true,
+		},
+		{
+			name: "acr step-up required",
+			err: ErrStepUpRequired.Build(errutil.TemplateData{
+				Private: map[string]any{"acr": "urn:low"},
+				Public:  map[string]any{"requiredAcr": "urn:high"},
+			}),
+			expectedReason: DenialReasonStepUpRequired,
+			expectedOK:     true,
+		},
+		{
+			name:           "invalid role",
+			err:            ErrInvalidRole.Errorf("invalid role: Superuser"),
+			expectedReason: DenialReasonInvalidRole,
+			expectedOK:     true,
+		},
+		{
+			name:           "ambiguous role",
+			err:            ErrAmbiguousRole.Errorf("role_attribute_path resolved to several roles"),
+			expectedReason: DenialReasonAmbiguousRole,
+			expectedOK:     true,
+		},
+		{
+			name:           "none role denied",
+			err:            ErrNoneRoleDenied.Errorf("role_attribute_path resolved to the None role"),
+			expectedReason: DenialReasonNoneRole,
+			expectedOK:     true,
+		},
+		{
+			name:           "missing role with role_attribute_strict",
+			err:            errRoleAttributeStrictViolation.Errorf("idP did not return a role attribute"),
+			expectedReason: DenialReasonMissingRole,
+			expectedOK:     true,
+		},
+		{
+			name:           "wrapped denial",
+			err:            fmt.Errorf("failed to get user info: %w", errMissingGroupMembership),
+			expectedReason: DenialReasonGroupMembership,
+			expectedOK:     true,
+		},
+		{
+			name: "not a denial",
+			err:  errors.New("connection refused"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, ok := GetDenialReason(tt.err)
+			require.Equal(t, tt.expectedOK, ok)
+			require.Equal(t, tt.expectedReason, reason)
+		})
+	}
+}
+
+func TestPolicyDenials_Status(t *testing.T) {
+	for _, base := range []errutil.Base{errEmailNotAllowed, errEmailDomainNotAllowed, errIssuerNotAllowed, errAudienceNotAllowed} {
+		var err errutil.Error
+		require.ErrorAs(t, base.Errorf("denied"), &err)
+		require.Equal(t, http.StatusForbidden, err.Public().StatusCode, err.MessageID)
+	}
+}
+
+func TestGetDenialReason_UserInfo(t *testing.T) {
+	t.Run("should report the issuer denial from the generic connector", func(t *testing.T) {
+		provider, err := NewGenericOAuthProvider(map[string]any{
+			"allowed_issuers": "https://idp.example.com",
+		}, &setting.Cfg{}, featuremgmt.WithFeatures())
+		require.NoError(t, err)
+
+		token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, map[string]any{
+			"email": "john.doe@example.com",
+			"iss":   "https://evil.example.com",
+		})})
+		_, err = provider.UserInfo(context.Background(), http.DefaultClient, token)
+
+		reason, ok := GetDenialReason(err)
+		require.True(t, ok)
+		require.Equal(t, DenialReasonIssuerNotAllowed, reason)
+	})
+
+	t.Run("should report the missing email denial from the okta connector", func(t *testing.T) {
+		provider, err := NewOktaProvider(map[string]any{}, &setting.Cfg{}, featuremgmt.WithFeatures())
+		require.NoError(t, err)
+
+		token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, map[string]any{"sub": "okta-user"})})
+		_, err = provider.UserInfo(context.Background(), http.DefaultClient, token)
+
+		reason, ok := GetDenialReason(err)
+		require.True(t, ok)
+		require.Equal(t, DenialReasonMissingEmail, reason)
+	})
+}
+
+func TestUserInfo_TypedErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		_, err := writer.Write([]byte(`{ "email": "okta-octopus@grafana.com", "role": "Superuser" }`))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	tests := []struct {
+		name                  string
+		settings              map[string]any
+		expectedErr           errutil.Base
+		expectedPublicMessage string
+	}{
+		{
+			name:                  "should report an unreachable IdP",
+			settings:              map[string]any{"api_url": unreachable.URL + "/user"},
+			expectedErr:           ErrUserInfoFetch,
+			expectedPublicMessage: "Failed to get user info from the IdP, please try again later",
+		},
+		{
+			name:                  "should report an invalid attribute path",
+			settings:              map[string]any{"api_url": server.URL + "/user", "role_attribute_path": "[role"},
+			expectedErr:           ErrAttributePath,
+			expectedPublicMessage: "An attribute path is misconfigured, please contact your administrator",
+		},
+		{
+			name:                  "should report an invalid role",
+			settings:              map[string]any{"api_url": server.URL + "/user", "role_attribute_path": "role"},
+			expectedErr:           ErrInvalidRole,
+			expectedPublicMessage: "IdP did not return a valid role attribute, please contact your administrator",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := NewOktaProvider(tt.settings, &setting.Cfg{}, featuremgmt.WithFeatures())
+			require.NoError(t, err)
+
+			token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, map[string]any{"email": "okto.octopus@test.com"})})
+			_, err = provider.UserInfo(context.Background(), server.Client(), token)
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			var errWithPublic errutil.Error
+			require.ErrorAs(t, err, &errWithPublic)
+			require.Equal(t, tt.expectedPublicMessage, errWithPublic.Public().Message)
+		})
+	}
+}
@@ -8,7 +8,10 @@ import (
 	"fmt"
 	"net/http"
 	"net/mail"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 
 	"golang.org/x/oauth2"
 
@@ -17,9 +20,23 @@ import (
 	"github.com/grafana/grafana/pkg/util"
 )
 
-const genericOAuthProviderName = "generic_oauth"
+const (
+	genericOAuthProviderName = "generic_oauth"
+	promptParamName          = "prompt"
+)
+
+// promptValues are the values of the prompt parameter, as in OpenID Connect Core 1.0 section 3.1.2.1
+var promptValues = []string{"none", "login", "consent", "select_account"}
 
+// SocialGenericOAuth is the generic OAuth connector, whose settings can be swapped with Reload.
 type SocialGenericOAuth struct {
+	genericOAuthState
+	// reloadMu guards genericOAuthState, which Reload swaps
+	reloadMu sync.RWMutex
+}
+
+// genericOAuthState holds the settings of the generic OAuth connector and the state built from them.
+type genericOAuthState struct {
 	*SocialBase
 	allowedOrganizations []string
 	apiUrl               string
@@ -34,6 +51,16 @@ type SocialGenericOAuth struct {
 	teamIds              []string
 	allowedGroups        []string
 	skipOrgRoleSync      bool
+	authOnly             bool
+	keycloakRoles        bool
+	keycloakClientID     string
+	roleFromHeader       string
+	useIDToken           bool
+	secondaryApiUrl      string
+	allowSecondaryFail   bool
+	userInfoRootPath     string
+	accessTokenClaims    bool
+	prompt               string
 }
 
 func NewGenericOAuthProvider(settings map[string]any, cfg *setting.Cfg, features *featuremgmt.FeatureManager) (*SocialGenericOAuth, error) {
@@ -42,8 +69,24 @@ func NewGenericOAuthProvider(settings map[string]any, cfg *setting.Cfg, features
 		return nil, err
 	}
 
+	if _, err := newTLSClientConfig(info); err != nil {
+		return nil, err
+	}
+
+	if _, err := parseTransportSettings(info); err != nil {
+		return nil, err
+	}
+
+	if err := validateScopes(settings, info); err != nil {
+		return nil, err
+	}
+
+	if err := discoverOIDCEndpoints(info); err != nil {
+		return nil, err
+	}
+
 	config := createOAuthConfig(info, cfg, genericOAuthProviderName)
-	provider := &SocialGenericOAuth{
+	provider := &SocialGenericOAuth{genericOAuthState: genericOAuthState{
 		SocialBase:           newSocialBase(genericOAuthProviderName, config, info, cfg.AutoAssignOrgRole, cfg.OAuthSkipOrgRoleUpdateSync, *features),
 		apiUrl:               info.ApiUrl,
 		teamsUrl:             info.TeamsUrl,
@@ -60,11 +103,106 @@ func NewGenericOAuthProvider(settings map[string]any, cfg *setting.Cfg, features
 		skipOrgRoleSync:      cfg.GenericOAuthSkipOrgRoleSync,
 		// FIXME: Move skipOrgRoleSync to OAuthInfo
 		// skipOrgRoleSync: info.SkipOrgRoleSync
+		authOnly:           mustBool(info.Extra["auth_only"], false),
+		keycloakRoles:      mustBool(info.Extra["keycloak_roles"], false),
+		keycloakClientID:   info.Extra["keycloak_client_id"],
+		roleFromHeader:     info.Extra["role_from_header"],
+		useIDToken:         mustBool(info.Extra["use_id_token"], true),
+		secondaryApiUrl:    info.Extra["secondary_api_url"],
+		allowSecondaryFail: mustBool(info.Extra["allow_secondary_failure"], false),
+		userInfoRootPath:   info.Extra["userinfo_root_path"],
+		accessTokenClaims:  mustBool(info.Extra["use_access_token_claims"], false),
+		prompt:             strings.Join(strings.Fields(info.Extra["prompt"]), " "),
+	}}
+
+	if provider.authOnly {
+		// the role, Grafana admin and team settings are ignored, the users are managed in Grafana only
+		provider.skipOrgRoleSync = true
+	}
+
+	if provider.keycloakRoles {
+		if provider.groupsAttributePath != "" {
+			return nil, fmt.Errorf("keycloak_roles can't be combined with groups_attribute_path")
+		}
+		provider.groupsAttributePath = keycloakRolesAttributePath(provider.keycloakClientID)
+	}
+
+	if err := provider.validateEmptyUserInfoAction(); err != nil {
+		return nil, err
+	}
+
+	if !provider.useIDToken && provider.emptyUserInfoAction == emptyUserInfoActionIDToken {
+		return nil, fmt.Errorf("empty_userinfo_action %q can't be combined with use_id_token = false", emptyUserInfoActionIDToken)
+	}
+
+	if err := validatePrompt(provider.prompt); err != nil {
+		return nil, err
+	}
+
+	if provider.userInfoRootPath != "" {
+		if _, err := provider.compileAttributePath(provider.userInfoRootPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := provider.compileUserInfoRequest(); err != nil {
+		return nil, err
+	}
+
+	if err := provider.compileEmailAllowedRegex(); err != nil {
+		return nil, err
+	}
+
+	if err := provider.validateNullRoleFallback(); err != nil {
+		return nil, err
+	}
+
+	if err := provider.loadIDTokenDecryptKey(); err != nil {
+		return nil, err
+	}
+
+	if err := provider.compileAttributePaths(); err != nil {
+		return nil, err
 	}
 
 	return provider, nil
 }
 
+// keycloakRolesAttributePath returns the path reading the Keycloak realm roles from realm_access.roles and,
+// when clientID is set, the roles of that client from resource_access.<clientID>.roles, as one list.
+func keycloakRolesAttributePath(clientID string) string {
+	realmRoles := "realm_access.roles || `[]`"
+	if clientID == "" {
+		return realmRoles
+	}
+
+	// the client ID is quoted, since Keycloak client IDs often contain dashes or are URLs
+	quotedClientID, _ := json.Marshal(clientID)
+	return fmt.Sprintf("[%s, resource_access.%s.roles || `[]`][]", realmRoles, quotedClientID)
+}
+
+// validatePrompt returns an error if prompt holds an unknown value, or combines none with other values.
+func validatePrompt(prompt string) error {
+	values := strings.Fields(prompt)
+	for _, value := range values {
+		if !slices.Contains(promptValues, value) {
+			return fmt.Errorf("invalid prompt %q, must be a space separated list of %q", value, promptValues)
+		}
+		if value == "none" && len(values) > 1 {
+			return fmt.Errorf("prompt %q can't be combined with other values", value)
+		}
+	}
+	return nil
+}
+
+// authCodeURL adds the configured prompt parameter to the auth URL. The caller supplies the login_hint, see LoginHint.
+func (s *SocialGenericOAuth) authCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	if s.prompt != "" {
+		opts = append(opts, oauth2.SetAuthURLParam(promptParamName, s.prompt))
+	}
+	return s.Config.AuthCodeURL(state, opts...)
+}
+
 // TODOD: remove this in the next PR and use the isGroupMember from social.go
 func (s *SocialGenericOAuth) IsGroupMember(groups []string) bool {
 	if len(s.allowedGroups) == 0 {
@@ -132,9 +270,14 @@ type UserInfoJson struct {
 	Username    string              `json:"username"`
 	Email       string              `json:"email"`
 	Upn         string              `json:"upn"`
+	Acr         string              `json:"acr"`
+	Iss         string              `json:"iss"`
+	Aud         audience            `json:"aud"`
 	Attributes  map[string][]string `json:"attributes"`
 	rawJSON     []byte
 	source      string
+	headerRole  string
+	warnings    []string
 }
 
 func (info *UserInfoJson) String() string {
@@ -143,18 +286,108 @@ func (info *UserInfoJson) String() string {
 		info.Name, info.DisplayName, info.Login, info.Username, info.Email, info.Upn, info.Attributes)
 }
 
-func (s *SocialGenericOAuth) UserInfo(ctx context.Context, client *http.Client, token *oauth2.Token) (*BasicUserInfo, error) {
+func (s *SocialGenericOAuth) userInfo(ctx context.Context, client *http.Client, token *oauth2.Token) (*BasicUserInfo, error) {
 	s.log.Debug("Getting user info")
 	toCheck := make([]*UserInfoJson, 0, 2)
 
-	if tokenData := s.extractFromToken(token); tokenData != nil {
-		toCheck = append(toCheck, tokenData)
+	var acr, iss string
+	var aud audience
+	tokenData, err := s.extractFromToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if tokenData != nil {
+		// the issuer and acr are still checked when the id_token claims aren't trusted for the user info
+		if s.useIDToken {
+			toCheck = append(toCheck, tokenData)
+		}
+		acr = tokenData.Acr
+		iss = tokenData.Iss
+		aud = tokenData.Aud
+	}
+
+	if err := s.checkIssuer(iss); err != nil {
+		return nil, err
 	}
-	if apiData := s.extractFromAPI(ctx, client); apiData != nil {
+
+	if err := s.checkAudience(aud); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkACR(acr); err != nil {
+		return nil, err
+	}
+
+	// fail fast instead of getting the user info request rejected by the IdP
+	if s.apiUrl != "" && len(toCheck) == 0 && (token == nil || token.AccessToken == "") {
+		return nil, ErrMissingAccessToken.Errorf("no access token to fetch the user info from %s and no id_token claims", s.apiUrl)
+	}
+
+	var warnings []string
+	useDefaultRole := false
+	accessTokenData := s.extractFromAccessToken(token)
+	apiData, err := s.extractFromAPI(ctx, client, token)
+	switch {
+	case errors.Is(err, ErrUserInfoFetch) || errors.Is(err, ErrUserInfoCircuitOpen):
+		// the token claims are used when the user info can't be fetched, the error is only returned without them
+		if len(toCheck) == 0 && accessTokenData == nil {
+			return nil, err
+		}
+		s.log.Warn("Failed to get the user info, using the token claims", "url", s.apiUrl, "error", err)
+		warnings = append(warnings, fmt.Sprintf("failed to get the user info, used the token claims instead: %v", err))
+	case errors.Is(err, errEmptyUserInfo):
+		switch s.emptyUserInfoAction {
+		case emptyUserInfoActionError:
+			return nil, err
+		case emptyUserInfoActionDefaults:
+			s.log.Debug("Empty user info response, proceeding with the default role")
+			useDefaultRole = true
+		default:
+			if !s.useIDToken {
+				return nil, err
+			}
+			s.log.Debug("Empty user info response, using id_token claims")
+		}
+	case err != nil:
+		return nil, err
+	case apiData != nil:
 		toCheck = append(toCheck, apiData)
+		warnings = append(warnings, apiData.warnings...)
+	}
+
+	if accessTokenData != nil {
+		for _, data := range toCheck {
+			merged, err := mergeMissingClaims(data.rawJSON, accessTokenData.rawJSON)
+			if err != nil {
+				s.log.Warn("Failed to merge access_token claims", "source", data.source, "error", err)
+				warnings = append(warnings, fmt.Sprintf("failed to merge the access_token claims into the %s user info: %v", data.source, err))
+				continue
+			}
+			data.rawJSON = merged
+		}
+		if len(toCheck) == 0 {
+			toCheck = append(toCheck, accessTokenData)
+		}
+	}
+
+	var graphGroups []string
+	if !s.authOnly {
+		graphGroups = s.graphGroups(ctx, client)
+	}
+
+	userInfo := &BasicUserInfo{Warnings: warnings}
+	if apiData != nil && apiData.headerRole != "" && !s.skipOrgRoleSync && s.rolePolicyURL == "" {
+		// the role header takes precedence over role_attribute_path for both the id_token and the user info
+		role, grafanaAdmin := getRoleFromSearch(apiData.headerRole)
+		if !role.IsValid() {
+			return nil, ErrInvalidRole.Errorf("invalid role %q in user info header %s", apiData.headerRole, s.roleFromHeader)
+		}
+		userInfo.Role = role
+		if s.allowAssignGrafanaAdmin {
+			userInfo.IsGrafanaAdmin = &grafanaAdmin
+		}
 	}
 
-	userInfo := &BasicUserInfo{}
 	for _, data := range toCheck {
 		s.log.Debug("Processing external user info", "source", data.source, "data", data)
 
@@ -177,10 +410,21 @@ func (s *SocialGenericOAuth) UserInfo(ctx context.Context, client *http.Client,
 			}
 		}
 
-		if userInfo.Role == "" && !s.skipOrgRoleSync {
-			role, grafanaAdmin, err := s.extractRoleAndAdminOptional(data.rawJSON, []string{})
+		groups, errGroups := s.extractGroups(data)
+
+		if userInfo.Role == "" && !s.skipOrgRoleSync && !useDefaultRole && s.rolePolicyURL == "" {
+			roleGroups := make([]string, 0, len(groups)+len(graphGroups))
+			roleGroups = append(append(roleGroups, groups...), graphGroups...)
+			_, span := s.startSpan(ctx, "social.evaluateRole")
+			role, grafanaAdmin, err := s.extractRoleAndAdminOptional(data.rawJSON, roleGroups)
+			endSpan(span, err)
+			if errors.Is(err, ErrAmbiguousRole) || errors.Is(err, ErrNoneRoleDenied) {
+				// falling back to the default role would hide the misconfiguration or the denied login
+				return nil, err
+			}
 			if err != nil {
 				s.log.Warn("Failed to extract role", "err", err)
+				userInfo.Warnings = append(userInfo.Warnings, fmt.Sprintf("failed to extract the role from the %s user info: %v", data.source, err))
 			} else {
 				userInfo.Role = role
 				if s.allowAssignGrafanaAdmin {
@@ -190,9 +434,9 @@ func (s *SocialGenericOAuth) UserInfo(ctx context.Context, client *http.Client,
 		}
 
 		if len(userInfo.Groups) == 0 {
-			groups, err := s.extractGroups(data)
-			if err != nil {
-				s.log.Warn("Failed to extract groups", "err", err)
+			if errGroups != nil {
+				s.log.Warn("Failed to extract groups", "err", errGroups)
+				userInfo.Warnings = append(userInfo.Warnings, fmt.Sprintf("failed to extract the groups from the %s user info: %v", data.source, errGroups))
 			} else if len(groups) > 0 {
 				s.log.Debug("Setting user info groups from extracted groups")
 				userInfo.Groups = groups
@@ -200,6 +444,21 @@ func (s *SocialGenericOAuth) UserInfo(ctx context.Context, client *http.Client,
 		}
 	}
 
+	if s.rolePolicyURL != "" && !s.skipOrgRoleSync && !useDefaultRole && len(toCheck) > 0 {
+		// the policy service gets the user info response when available, falling back to the id_token claims
+		data := toCheck[len(toCheck)-1]
+		roleGroups := make([]string, 0, len(userInfo.Groups)+len(graphGroups))
+		roleGroups = append(append(roleGroups, userInfo.Groups...), graphGroups...)
+		role, grafanaAdmin, err := s.searchRolePolicy(ctx, data.rawJSON, roleGroups)
+		if err != nil {
+			return nil, err
+		}
+		userInfo.Role = role
+		if s.allowAssignGrafanaAdmin {
+			userInfo.IsGrafanaAdmin = &grafanaAdmin
+		}
+	}
+
 	if userInfo.Role == "" && !s.skipOrgRoleSync {
 		if s.roleAttributeStrict {
 			return nil, errRoleAttributeStrictViolation.Errorf("idP did not return a role attribute")
@@ -220,32 +479,53 @@ func (s *SocialGenericOAuth) UserInfo(ctx context.Context, client *http.Client,
 		s.log.Debug("Setting email from fetched private email", "email", userInfo.Email)
 	}
 
+	if err := s.checkEmailAllowedRegex(userInfo.Email); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkEmailDomain(userInfo.Email); err != nil {
+		return nil, err
+	}
+
+	s.capRoleByDomain(userInfo)
+
 	if userInfo.Login == "" {
 		s.log.Debug("Defaulting to using email for user info login", "email", userInfo.Email)
 		userInfo.Login = userInfo.Email
 	}
 
 	if !s.IsTeamMember(ctx, client) {
-		return nil, errors.New("user not a member of one of the required teams")
+		return nil, ErrMissingTeamMembership.Errorf("user not a member of one of the required teams")
 	}
 
 	if !s.IsOrganizationMember(ctx, client) {
-		return nil, errors.New("user not a member of one of the required organizations")
+		return nil, ErrMissingOrganizationMembership.Errorf("user not a member of one of the required organizations")
 	}
 
 	if !s.IsGroupMember(userInfo.Groups) {
 		return nil, errMissingGroupMembership
 	}
 
+	if s.authOnly {
+		// the groups are only checked against allowed_groups, they aren't synced to teams
+		userInfo.Groups = nil
+	}
+
+	s.setProviderIdentity(userInfo)
+
 	s.log.Debug("User info result", "result", userInfo)
 	return userInfo, nil
 }
 
 func (s *SocialGenericOAuth) GetOAuthInfo() *OAuthInfo {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
 	return s.info
 }
 
-func (s *SocialGenericOAuth) extractFromToken(token *oauth2.Token) *UserInfoJson {
+// extractFromToken returns the user info from the id_token. A malformed id_token is reported as
+// ErrInvalidIDToken, while claims that don't decode into the user info are logged and result in nil user info.
+func (s *SocialGenericOAuth) extractFromToken(token *oauth2.Token) (*UserInfoJson, error) {
 	s.log.Debug("Extracting user info from OAuth token")
 
 	idTokenAttribute := "id_token"
@@ -257,52 +537,166 @@ func (s *SocialGenericOAuth) extractFromToken(token *oauth2.Token) *UserInfoJson
 	idToken := token.Extra(idTokenAttribute)
 	if idToken == nil {
 		s.log.Debug("No id_token found", "token", token)
-		return nil
+		return nil, nil
 	}
 
 	rawJSON, err := s.retrieveRawIDToken(idToken)
 	if err != nil {
-		s.log.Warn("Error retrieving id_token", "error", err, "token", fmt.Sprintf("%+v", token))
-		return nil
+		s.log.Warn("Error retrieving id_token", "error", err)
+		return nil, err
 	}
 
 	var data UserInfoJson
 	if err := json.Unmarshal(rawJSON, &data); err != nil {
 		s.log.Error("Error decoding id_token JSON", "raw_json", string(rawJSON), "error", err)
-		return nil
+		return nil, nil
 	}
 
 	data.rawJSON = rawJSON
 	data.source = "token"
 	s.log.Debug("Received id_token", "raw_json", string(data.rawJSON), "data", data.String())
+	return &data, nil
+}
+
+// extractFromAccessToken returns the claims of the access_token when use_access_token_claims is set and the
+// access_token is a JWT. Other access tokens are opaque to Grafana and result in nil user info.
+func (s *SocialGenericOAuth) extractFromAccessToken(token *oauth2.Token) *UserInfoJson {
+	if !s.accessTokenClaims || token == nil || strings.Count(token.AccessToken, ".") != 2 {
+		return nil
+	}
+
+	rawJSON, err := s.retrieveRawIDToken(token.AccessToken)
+	if err != nil {
+		s.log.Debug("Ignoring access_token that is not a JWT", "error", err)
+		return nil
+	}
+
+	var data UserInfoJson
+	if err := json.Unmarshal(rawJSON, &data); err != nil {
+		s.log.Debug("Ignoring access_token claims that can't be decoded", "error", err)
+		return nil
+	}
+
+	data.rawJSON = rawJSON
+	data.source = "access_token"
 	return &data
 }
 
-func (s *SocialGenericOAuth) extractFromAPI(ctx context.Context, client *http.Client) *UserInfoJson {
+// extractFromAPI returns the user info from the API, merged with the secondary_api_url response and narrowed to
+// userinfo_root_path. A failed request is reported as ErrUserInfoFetch or ErrUserInfoCircuitOpen, an empty response
+// body as errEmptyUserInfo and a secondary_api_url failure unless allow_secondary_failure is set. Other failures are
+// logged and result in nil user info.
+func (s *SocialGenericOAuth) extractFromAPI(ctx context.Context, client *http.Client, token *oauth2.Token) (*UserInfoJson, error) {
 	s.log.Debug("Getting user info from API")
 	if s.apiUrl == "" {
 		s.log.Debug("No api url configured")
-		return nil
+		return nil, nil
 	}
 
-	rawUserInfoResponse, err := s.httpGet(ctx, client, s.apiUrl)
+	rawUserInfoResponse, err := s.userInfoGet(ctx, client, token, s.apiUrl)
 	if err != nil {
-		s.log.Debug("Error getting user info from API", "url", s.apiUrl, "error", err)
-		return nil
+		return nil, err
 	}
 
 	rawJSON := rawUserInfoResponse.Body
+	if isEmptyBody(rawJSON) {
+		return nil, errEmptyUserInfo.Errorf("user info endpoint %s returned an empty response", s.apiUrl)
+	}
+
+	var warnings []string
+	if s.secondaryApiUrl != "" {
+		merged, err := s.mergeSecondaryUserInfo(ctx, client, token, rawJSON)
+		switch {
+		case err == nil:
+			rawJSON = merged
+		case s.allowSecondaryFail:
+			s.log.Warn("Ignoring the secondary user info", "url", s.secondaryApiUrl, "error", err)
+			warnings = append(warnings, fmt.Sprintf("ignored the secondary user info: %v", err))
+		default:
+			return nil, err
+		}
+	}
+
+	if s.userInfoRootPath != "" {
+		selected, err := s.selectUserInfoRoot(rawJSON)
+		if err != nil {
+			s.log.Error("Error selecting the user info root", "userinfo_root_path", s.userInfoRootPath, "error", err)
+			return nil, nil
+		}
+		rawJSON = selected
+	}
 
 	var data UserInfoJson
 	if err := json.Unmarshal(rawJSON, &data); err != nil {
 		s.log.Error("Error decoding user info response", "raw_json", rawJSON, "error", err)
-		return nil
+		return nil, nil
 	}
 
 	data.rawJSON = rawJSON
 	data.source = "API"
+	data.warnings = warnings
+	if s.roleFromHeader != "" {
+		data.headerRole = rawUserInfoResponse.Headers.Get(s.roleFromHeader)
+	}
 	s.log.Debug("Received user info response from API", "raw_json", string(rawJSON), "data", data.String())
-	return &data
+	return &data, nil
+}
+
+// mergeSecondaryUserInfo fetches secondary_api_url with the same token and deep merges its response into rawJSON.
+// The primary user info wins when both responses have the same key.
+func (s *SocialGenericOAuth) mergeSecondaryUserInfo(ctx context.Context, client *http.Client, token *oauth2.Token, rawJSON []byte) ([]byte, error) {
+	response, err := s.userInfoGet(ctx, client, token, s.secondaryApiUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the secondary user info from %s: %w", s.secondaryApiUrl, err)
+	}
+
+	var secondary map[string]any
+	if err := json.Unmarshal(response.Body, &secondary); err != nil {
+		return nil, fmt.Errorf("failed to decode the secondary user info from %s: %w", s.secondaryApiUrl, err)
+	}
+
+	var primary map[string]any
+	if err := json.Unmarshal(rawJSON, &primary); err != nil {
+		return nil, fmt.Errorf("failed to decode the user info: %w", err)
+	}
+
+	return json.Marshal(deepMergeJSON(primary, secondary))
+}
+
+// selectUserInfoRoot returns the object selected by userinfo_root_path in rawJSON, which the attribute paths
+// are then evaluated against.
+func (s *SocialGenericOAuth) selectUserInfoRoot(rawJSON []byte) ([]byte, error) {
+	root, err := s.searchJSONForAttr(s.userInfoRootPath, rawJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := root.(map[string]any); !ok {
+		return nil, fmt.Errorf("userinfo_root_path %q doesn't select an object", s.userInfoRootPath)
+	}
+	return json.Marshal(root)
+}
+
+// deepMergeJSON adds the keys of secondary missing from primary, recursing into the objects present in both.
+func deepMergeJSON(primary, secondary map[string]any) map[string]any {
+	if primary == nil {
+		primary = make(map[string]any, len(secondary))
+	}
+
+	for key, secondaryValue := range secondary {
+		primaryValue, exists := primary[key]
+		if !exists {
+			primary[key] = secondaryValue
+			continue
+		}
+
+		primaryObject, primaryIsObject := primaryValue.(map[string]any)
+		secondaryObject, secondaryIsObject := secondaryValue.(map[string]any)
+		if primaryIsObject && secondaryIsObject {
+			primary[key] = deepMergeJSON(primaryObject, secondaryObject)
+		}
+	}
+	return primary
 }
 
 func (s *SocialGenericOAuth) extractEmail(data *UserInfoJson) string {
@@ -310,13 +704,19 @@ func (s *SocialGenericOAuth) extractEmail(data *UserInfoJson) string {
 		return data.Email
 	}
 
-	if s.emailAttributePath != "" {
-		email, err := s.searchJSONForStringAttr(s.emailAttributePath, data.rawJSON)
+	// email_attribute_path is a list of paths tried in order, the first one holding an email address is used
+	for _, path := range splitAttributePaths(s.emailAttributePath) {
+		email, err := s.searchJSONForStringAttr(path, data.rawJSON)
 		if err != nil {
 			s.log.Error("Failed to search JSON for attribute", "error", err)
-		} else if email != "" {
+			continue
+		}
+		if strings.Contains(email, "@") {
 			return email
 		}
+		if email != "" {
+			s.log.Debug("Skipping email attribute path not holding an email address", "emailAttributePath", path)
+		}
 	}
 
 	emails, ok := data.Attributes[s.emailAttributeName]
@@ -530,15 +930,26 @@ func (s *SocialGenericOAuth) FetchOrganizations(ctx context.Context, client *htt
 	return logins, true
 }
 
-func (s *SocialGenericOAuth) SupportBundleContent(bf *bytes.Buffer) error {
+func (s *SocialGenericOAuth) supportBundleContent(bf *bytes.Buffer) error {
 	bf.WriteString("## GenericOAuth specific configuration\n\n")
 	bf.WriteString("```ini\n")
 	bf.WriteString(fmt.Sprintf("name_attribute_path = %s\n", s.nameAttributePath))
 	bf.WriteString(fmt.Sprintf("login_attribute_path = %s\n", s.loginAttributePath))
 	bf.WriteString(fmt.Sprintf("id_token_attribute_name = %s\n", s.idTokenAttributeName))
+	bf.WriteString(fmt.Sprintf("use_id_token = %v\n", s.useIDToken))
+	bf.WriteString(fmt.Sprintf("issuer = %s\n", s.info.Extra["issuer"]))
 	bf.WriteString(fmt.Sprintf("team_ids_attribute_path = %s\n", s.teamIdsAttributePath))
 	bf.WriteString(fmt.Sprintf("team_ids = %v\n", s.teamIds))
 	bf.WriteString(fmt.Sprintf("allowed_organizations = %v\n", s.allowedOrganizations))
+	bf.WriteString(fmt.Sprintf("keycloak_roles = %v\n", s.keycloakRoles))
+	bf.WriteString(fmt.Sprintf("keycloak_client_id = %s\n", s.keycloakClientID))
+	bf.WriteString(fmt.Sprintf("role_from_header = %s\n", s.roleFromHeader))
+	bf.WriteString(fmt.Sprintf("secondary_api_url = %s\n", s.secondaryApiUrl))
+	bf.WriteString(fmt.Sprintf("allow_secondary_failure = %v\n", s.allowSecondaryFail))
+	bf.WriteString(fmt.Sprintf("userinfo_root_path = %s\n", s.userInfoRootPath))
+	bf.WriteString(fmt.Sprintf("use_access_token_claims = %v\n", s.accessTokenClaims))
+	bf.WriteString(fmt.Sprintf("prompt = %s\n", s.prompt))
+	bf.WriteString(fmt.Sprintf("auth_only = %v\n", s.authOnly))
 	bf.WriteString("```\n\n")
 
 	return s.SocialBase.SupportBundleContent(bf)
@@ -0,0 +1,113 @@
+package social
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	"golang.org/x/oauth2"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// Reload validates settings like NewGenericOAuthProvider and swaps them in, along with the compiled attribute
+// paths, the endpoints and the caches built from them. The OpenID Connect discovery document of the issuer is
+// fetched again. Calls in flight complete with the previous settings, and the provider is left unchanged when
+// the settings are invalid.
+func (s *SocialGenericOAuth) Reload(settings map[string]any, cfg *setting.Cfg) error {
+	s.reloadMu.RLock()
+	features := s.features
+	oidcDiscoveryCache.Delete(normalizeIssuer(s.info.Extra["issuer"]))
+	s.reloadMu.RUnlock()
+	if issuer, ok := settings["issuer"].(string); ok {
+		oidcDiscoveryCache.Delete(normalizeIssuer(issuer))
+	}
+
+	reloaded, err := NewGenericOAuthProvider(settings, cfg, &features)
+	if err != nil {
+		return err
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	s.genericOAuthState = reloaded.genericOAuthState
+	return nil
+}
+
+func (s *SocialGenericOAuth) UserInfo(ctx context.Context, client *http.Client, token *oauth2.Token) (*BasicUserInfo, error) {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	ctx, span := s.startSpan(ctx, "social.UserInfo")
+	userInfo, err := s.userInfo(ctx, client, token)
+	endSpan(span, err)
+	return userInfo, err
+}
+
+func (s *SocialGenericOAuth) TeamMemberships(ctx context.Context, client *http.Client, token *oauth2.Token) ([]string, error) {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	return s.SocialBase.TeamMemberships(ctx, client, token)
+}
+
+func (s *SocialGenericOAuth) PreviewMapping(ctx context.Context, rawJSON []byte) (MappingResult, error) {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	return s.SocialBase.PreviewMapping(ctx, rawJSON)
+}
+
+func (s *SocialGenericOAuth) CheckHealth(ctx context.Context, client *http.Client) error {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	return s.SocialBase.CheckHealth(ctx, client)
+}
+
+func (s *SocialGenericOAuth) IsEmailAllowed(email string) bool {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	return s.SocialBase.IsEmailAllowed(email)
+}
+
+func (s *SocialGenericOAuth) IsSignupAllowed() bool {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	return s.SocialBase.IsSignupAllowed()
+}
+
+func (s *SocialGenericOAuth) SupportBundleContent(bf *bytes.Buffer) error {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	return s.supportBundleContent(bf)
+}
+
+func (s *SocialGenericOAuth) RedactedConfig() map[string]any {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	return s.SocialBase.RedactedConfig()
+}
+
+func (s *SocialGenericOAuth) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	return s.authCodeURL(state, opts...)
+}
+
+func (s *SocialGenericOAuth) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	ctx, span := s.startSpan(ctx, "social.Exchange")
+	token, err := s.Config.Exchange(ctx, code, opts...)
+	endSpan(span, err)
+	return token, err
+}
+
+func (s *SocialGenericOAuth) Client(ctx context.Context, t *oauth2.Token) *http.Client {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	return s.Config.Client(ctx, t)
+}
+
+func (s *SocialGenericOAuth) TokenSource(ctx context.Context, t *oauth2.Token) oauth2.TokenSource {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	return s.Config.TokenSource(ctx, t)
+}
@@ -0,0 +1,127 @@
+package social
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestSocialGenericOAuth_Reload(t *testing.T) {
+	cfg := &setting.Cfg{AutoAssignOrgRole: "Viewer"}
+	provider, err := NewGenericOAuthProvider(map[string]any{
+		"role_attribute_path": "role",
+		"auth_url":            "https://idp.example.com/authorize",
+	}, cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	token := (&oauth2.Token{}).WithExtra(map[string]any{
+		"id_token": createTestIDToken(t, map[string]any{"email": "john.doe@example.com", "role": "Editor", "grafana_role": "Admin"}),
+	})
+
+	userInfo, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+	require.NoError(t, err)
+	require.Equal(t, org.RoleEditor, userInfo.Role)
+
+	t.Run("should use the reloaded settings on the next user info", func(t *testing.T) {
+		err := provider.Reload(map[string]any{
+			"role_attribute_path": "grafana_role",
+			"auth_url":            "https://idp-reloaded.example.com/authorize",
+		}, cfg)
+		require.NoError(t, err)
+
+		userInfo, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+		require.NoError(t, err)
+		require.Equal(t, org.RoleAdmin, userInfo.Role)
+		require.Equal(t, "grafana_role", provider.GetOAuthInfo().RoleAttributePath)
+		require.Contains(t, provider.AuthCodeURL("state"), "https://idp-reloaded.example.com/authorize")
+	})
+
+	t.Run("should keep the current settings when the reloaded ones are invalid", func(t *testing.T) {
+		err := provider.Reload(map[string]any{"empty_userinfo_action": "unknown"}, cfg)
+		require.Error(t, err)
+
+		userInfo, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+		require.NoError(t, err)
+		require.Equal(t, org.RoleAdmin, userInfo.Role)
+	})
+
+	t.Run("should reload while user info is requested", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+				require.NoError(t, err)
+			}()
+		}
+		require.NoError(t, provider.Reload(map[string]any{"role_attribute_path": "role"}, cfg))
+		wg.Wait()
+
+		userInfo, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+		require.NoError(t, err)
+		require.Equal(t, org.RoleEditor, userInfo.Role)
+	})
+}
+
+func TestSocialService_ReloadProvider(t *testing.T) {
+	cfg := setting.NewCfg()
+	newService := func(t *testing.T) *SocialService {
+		generic, err := NewGenericOAuthProvider(map[string]any{"client_id": "grafana"}, cfg, featuremgmt.WithFeatures())
+		require.NoError(t, err)
+		okta, err := NewOktaProvider(map[string]any{}, cfg, featuremgmt.WithFeatures())
+		require.NoError(t, err)
+
+		return &SocialService{
+			cfg:       cfg,
+			socialMap: map[string]SocialConnector{"generic_oauth": generic, "okta": okta},
+			oAuthProvider: map[string]*OAuthInfo{
+				"generic_oauth": generic.GetOAuthInfo(),
+				"okta":          okta.GetOAuthInfo(),
+			},
+			log: log.NewNopLogger(),
+		}
+	}
+
+	t.Run("should serve the reloaded settings and HTTP client", func(t *testing.T) {
+		ss := newService(t)
+		client, err := ss.GetOAuthHttpClient("oauth_generic_oauth")
+		require.NoError(t, err)
+		require.Equal(t, 15*time.Second, client.Timeout)
+
+		err = ss.ReloadProvider("oauth_generic_oauth", map[string]any{"client_id": "reloaded", "http_client_timeout": "5s"})
+		require.NoError(t, err)
+
+		require.Equal(t, "reloaded", ss.GetOAuthInfoProvider("generic_oauth").ClientId)
+		require.Equal(t, "reloaded", ss.GetOAuthInfoProviders()["generic_oauth"].ClientId)
+		reloaded, err := ss.GetOAuthHttpClient("oauth_generic_oauth")
+		require.NoError(t, err)
+		require.NotSame(t, client, reloaded)
+		require.Equal(t, 5*time.Second, reloaded.Timeout)
+	})
+
+	t.Run("should keep the settings when the reloaded ones are invalid", func(t *testing.T) {
+		ss := newService(t)
+
+		err := ss.ReloadProvider("generic_oauth", map[string]any{"client_id": "reloaded", "prompt": "sometimes"})
+		require.Error(t, err)
+		require.Equal(t, "grafana", ss.GetOAuthInfoProvider("generic_oauth").ClientId)
+	})
+
+	t.Run("should fail for a provider that can't be reloaded", func(t *testing.T) {
+		ss := newService(t)
+
+		require.ErrorContains(t, ss.ReloadProvider("okta", map[string]any{}), `"okta" can't be reloaded`)
+		require.ErrorContains(t, ss.ReloadProvider("unknown", map[string]any{}), "failed to find oauth provider")
+	})
+}
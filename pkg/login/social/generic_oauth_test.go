@@ -1,10 +1,17 @@
 package social
 
 import (
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -16,6 +23,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/org"
 
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util/errutil"
 )
 
 func TestSearchJSONForEmail(t *testing.T) {
@@ -103,6 +111,45 @@ func TestSearchJSONForEmail(t *testing.T) {
 	})
 }
 
+func TestExtractEmail_AttributePaths(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Data          *UserInfoJson
+		ExpectedEmail string
+	}{
+		{
+			Name:          "should use the first path holding an email",
+			Data:          &UserInfoJson{rawJSON: []byte(`{"mail": "octopus@grafana.com", "upn": "upn@grafana.com"}`)},
+			ExpectedEmail: "octopus@grafana.com",
+		},
+		{
+			Name:          "should skip values that aren't email addresses",
+			Data:          &UserInfoJson{rawJSON: []byte(`{"email": "octopus", "upn": "upn@grafana.com"}`)},
+			ExpectedEmail: "upn@grafana.com",
+		},
+		{
+			Name:          "should prefer the email of the user info",
+			Data:          &UserInfoJson{Email: "token@grafana.com", rawJSON: []byte(`{"mail": "octopus@grafana.com"}`)},
+			ExpectedEmail: "token@grafana.com",
+		},
+		{
+			Name: "should return no email when no path holds one",
+			Data: &UserInfoJson{rawJSON: []byte(`{"name": "octopus"}`)},
+		},
+	}
+
+	provider, err := NewGenericOAuthProvider(map[string]any{
+		"email_attribute_path": "email, mail, upn",
+	}, setting.NewCfg(), featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			require.Equal(t, test.ExpectedEmail, provider.extractEmail(test.Data))
+		})
+	}
+}
+
 func TestSearchJSONForGroups(t *testing.T) {
 	t.Run("Given a generic OAuth provider", func(t *testing.T) {
 		provider, err := NewGenericOAuthProvider(map[string]any{}, &setting.Cfg{}, featuremgmt.WithFeatures())
@@ -206,6 +253,24 @@ func TestSearchJSONForRole(t *testing.T) {
 				RoleAttributePath: "attributes.role",
 				ExpectedResult:    "admin",
 			},
+			{
+				Name:                 "Given a namespaced claim and a quoted JMES path",
+				UserInfoJSONResponse: []byte(`{ "https://grafana/roles": ["Editor", "Viewer"] }`),
+				RoleAttributePath:    `"https://grafana/roles"[0]`,
+				ExpectedResult:       "Editor",
+			},
+			{
+				Name:                 "Given a namespaced claim and a quoted JMES path in an expression",
+				UserInfoJSONResponse: []byte(`{ "https://grafana/roles": ["Editor", "Viewer"] }`),
+				RoleAttributePath:    `contains("https://grafana/roles"[*], 'Editor') && 'Admin'`,
+				ExpectedResult:       "Admin",
+			},
+			{
+				Name:                 "Given a claim with an escaped quote and a quoted JMES path",
+				UserInfoJSONResponse: []byte(`{ "grafana\"roles": ["Editor"] }`),
+				RoleAttributePath:    `"grafana\"roles"[0]`,
+				ExpectedResult:       "Editor",
+			},
 		}
 
 		for _, test := range tests {
@@ -469,7 +534,7 @@ func TestUserInfoSearchesForEmailAndRole(t *testing.T) {
 			}))
 			provider.apiUrl = ts.URL
 			staticToken := oauth2.Token{
-				AccessToken:  "",
+				AccessToken:  "access-token",
 				TokenType:    "",
 				RefreshToken: "",
 				Expiry:       time.Now(),
@@ -568,7 +633,7 @@ func TestUserInfoSearchesForLogin(t *testing.T) {
 				}))
 				provider.apiUrl = ts.URL
 				staticToken := oauth2.Token{
-					AccessToken:  "",
+					AccessToken:  "access-token",
 					TokenType:    "",
 					RefreshToken: "",
 					Expiry:       time.Now(),
@@ -664,7 +729,7 @@ func TestUserInfoSearchesForName(t *testing.T) {
 				}))
 				provider.apiUrl = ts.URL
 				staticToken := oauth2.Token{
-					AccessToken:  "",
+					AccessToken:  "access-token",
 					TokenType:    "",
 					RefreshToken: "",
 					Expiry:       time.Now(),
@@ -733,7 +798,7 @@ func TestUserInfoSearchesForGroup(t *testing.T) {
 				require.NoError(t, err)
 
 				token := &oauth2.Token{
-					AccessToken:  "",
+					AccessToken:  "access-token",
 					TokenType:    "",
 					RefreshToken: "",
 					Expiry:       time.Now(),
@@ -747,6 +812,60 @@ func TestUserInfoSearchesForGroup(t *testing.T) {
 	})
 }
 
+func TestUserInfoAllowedGroups(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		_, err := writer.Write([]byte(`{"email": "john.doe@example.com", "role": "Admin", "info": {"groups": ["devs", "ops"]}}`))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		Name          string
+		AllowedGroups string
+		ExpectedErr   error
+	}{
+		{
+			Name: "Given no allowed groups, allow the user",
+		},
+		{
+			Name:          "Given a user in one of the allowed groups, allow the user",
+			AllowedGroups: "admins, ops",
+		},
+		{
+			Name:          "Given a user in none of the allowed groups, deny the user",
+			AllowedGroups: "admins",
+			ExpectedErr:   errMissingGroupMembership,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			provider, err := NewGenericOAuthProvider(map[string]any{
+				"api_url":               server.URL,
+				"role_attribute_path":   "role",
+				"groups_attribute_path": "info.groups",
+				"allowed_groups":        test.AllowedGroups,
+			}, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+			require.NoError(t, err)
+
+			userInfo, err := provider.UserInfo(context.Background(), server.Client(), &oauth2.Token{AccessToken: "access-token"})
+			if test.ExpectedErr != nil {
+				require.ErrorIs(t, err, test.ExpectedErr)
+				require.Nil(t, userInfo)
+
+				reason, ok := GetDenialReason(err)
+				require.True(t, ok)
+				require.Equal(t, DenialReasonGroupMembership, reason)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, []string{"devs", "ops"}, userInfo.Groups)
+			require.Equal(t, org.RoleAdmin, userInfo.Role)
+		})
+	}
+}
+
 func TestPayloadCompression(t *testing.T) {
 	provider, err := NewGenericOAuthProvider(map[string]any{
 		"email_attribute_path": "email",
@@ -775,7 +894,7 @@ func TestPayloadCompression(t *testing.T) {
 			ExpectedEmail: "john.doe@example.com",
 		},
 		{
-			Name: "Given an invalid DEFLATE compressed id_token, return nil",
+			Name: "Given an invalid DEFLATE compressed id_token, return an error",
 			OAuth2Extra: map[string]any{
 				// { "role": "Admin", "email": "john.doe@example.com" }
 				"id_token": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCIsInppcCI6IkRFRiJ9.00eJyrVkrNTczMUbJSysrPyNNLyU91SK1IzC3ISdVLzs9V0lEqys9JBco6puRm5inVAgCFRw_6.XrV4ZKhw19dTcnviXanBD8lwjeALCYtDiESMmGzC-ho",
@@ -783,13 +902,34 @@ func TestPayloadCompression(t *testing.T) {
 			ExpectedEmail: "",
 		},
 		{
-			Name: "Given an unsupported GZIP compressed id_token, return nil",
+			Name: "Given an unsupported GZIP compressed id_token, return an error",
 			OAuth2Extra: map[string]any{
 				// { "role": "Admin", "email": "john.doe@example.com" }
 				"id_token": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCIsInppcCI6IkdaSVAifQ.H4sIAAAAAAAAAKtWSs1NzMxRslLKys_I00vJT3VIrUjMLchJ1UvOz1XSUSrKz0kFyjqm5GbmKdUCANotxTkvAAAA.85AXm3JOF5qflEA0goDFvlbZl2q3eFvqVcehz860W-o",
 			},
 			ExpectedEmail: "",
 		},
+		{
+			Name: "Given an id_token with two parts, return an error",
+			OAuth2Extra: map[string]any{
+				"id_token": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.eyJlbWFpbCI6ImpvaG4uZG9lQGV4YW1wbGUuY29tIn0",
+			},
+			ExpectedEmail: "",
+		},
+		{
+			Name: "Given an id_token with an invalid base64 payload, return an error",
+			OAuth2Extra: map[string]any{
+				"id_token": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.not*base64.signature",
+			},
+			ExpectedEmail: "",
+		},
+		{
+			Name: "Given an id_token with non-JSON claims, return an error",
+			OAuth2Extra: map[string]any{
+				"id_token": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.bm90IGpzb24.signature",
+			},
+			ExpectedEmail: "",
+		},
 	}
 
 	for _, test := range tests {
@@ -802,11 +942,13 @@ func TestPayloadCompression(t *testing.T) {
 			}
 
 			token := staticToken.WithExtra(test.OAuth2Extra)
-			userInfo := provider.extractFromToken(token)
+			userInfo, err := provider.extractFromToken(token)
 
 			if test.ExpectedEmail == "" {
+				require.ErrorIs(t, err, ErrInvalidIDToken)
 				require.Nil(t, userInfo, "Testing case %q", test.Name)
 			} else {
+				require.NoError(t, err)
 				require.NotNil(t, userInfo, "Testing case %q", test.Name)
 				require.Equal(t, test.ExpectedEmail, userInfo.Email)
 			}
@@ -814,6 +956,64 @@ func TestPayloadCompression(t *testing.T) {
 	}
 }
 
+func TestUserInfoCompressedResponse(t *testing.T) {
+	const body = `{"email": "john.doe@example.com", "role": "Editor"}`
+
+	tests := []struct {
+		Name     string
+		Encoding string
+		Compress func(w io.Writer) io.WriteCloser
+	}{
+		{
+			Name:     "Given a gzip encoded user info response, decompress it",
+			Encoding: "gzip",
+			Compress: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		},
+		{
+			Name:     "Given a deflate encoded user info response, decompress it",
+			Encoding: "deflate",
+			Compress: func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		},
+		{
+			Name: "Given an uncompressed user info response, read it as is",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set("Content-Type", "application/json")
+				if test.Compress == nil {
+					_, err := writer.Write([]byte(body))
+					require.NoError(t, err)
+					return
+				}
+
+				writer.Header().Set("Content-Encoding", test.Encoding)
+				cw := test.Compress(writer)
+				_, err := cw.Write([]byte(body))
+				require.NoError(t, err)
+				require.NoError(t, cw.Close())
+			}))
+			defer server.Close()
+
+			provider, err := NewGenericOAuthProvider(map[string]any{
+				"api_url":             server.URL,
+				"role_attribute_path": "role",
+			}, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+			require.NoError(t, err)
+
+			// the transport doesn't decompress the responses of requests it didn't ask compression for
+			client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+			actualResult, err := provider.UserInfo(context.Background(), client, &oauth2.Token{AccessToken: "access-token"})
+			require.NoError(t, err)
+			require.Equal(t, "john.doe@example.com", actualResult.Email)
+			require.Equal(t, org.RoleEditor, actualResult.Role)
+		})
+	}
+}
+
 func TestSocialGenericOAuth_InitializeExtraFields(t *testing.T) {
 	type settingFields struct {
 		nameAttributePath    string
@@ -907,3 +1107,1261 @@ func TestSocialGenericOAuth_InitializeExtraFields(t *testing.T) {
 		})
 	}
 }
+
+func TestUserInfoRequiredACR(t *testing.T) {
+	provider, err := NewGenericOAuthProvider(map[string]any{
+		"required_acr": "urn:mace:incommon:iap:silver",
+	}, &setting.Cfg{}, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name            string
+		Claims          map[string]any
+		ExpectedStepUp  bool
+		ExpectedEmail   string
+		ExpectedPayload map[string]any
+	}{
+		{
+			Name:          "Given an id_token with the required acr, return userInfo",
+			Claims:        map[string]any{"email": "john.doe@example.com", "acr": "urn:mace:incommon:iap:silver"},
+			ExpectedEmail: "john.doe@example.com",
+		},
+		{
+			Name:            "Given an id_token with an insufficient acr, return step-up error",
+			Claims:          map[string]any{"email": "john.doe@example.com", "acr": "urn:mace:incommon:iap:bronze"},
+			ExpectedStepUp:  true,
+			ExpectedPayload: map[string]any{"requiredAcr": "urn:mace:incommon:iap:silver"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, test.Claims)})
+
+			actualResult, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+			if test.ExpectedStepUp {
+				require.ErrorIs(t, err, ErrStepUpRequired)
+				var errWithPayload errutil.Error
+				require.ErrorAs(t, err, &errWithPayload)
+				require.Equal(t, test.ExpectedPayload, errWithPayload.PublicPayload)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedEmail, actualResult.Email)
+		})
+	}
+}
+
+func TestUserInfoEmptyUserInfoResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		_, err := writer.Write([]byte("  "))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		Name          string
+		Action        string
+		ExpectedRole  org.RoleType
+		ExpectedError error
+	}{
+		{
+			Name:         "Given an empty user info response, use the id_token claims by default",
+			ExpectedRole: "Editor",
+		},
+		{
+			Name:         "Given an empty user info response and action id_token, use the id_token claims",
+			Action:       "id_token",
+			ExpectedRole: "Editor",
+		},
+		{
+			Name:          "Given an empty user info response and action error, return error",
+			Action:        "error",
+			ExpectedError: errEmptyUserInfo,
+		},
+		{
+			Name:         "Given an empty user info response and action defaults, use the default role",
+			Action:       "defaults",
+			ExpectedRole: "Viewer",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			provider, err := NewGenericOAuthProvider(map[string]any{
+				"api_url":               server.URL,
+				"role_attribute_path":   "role",
+				"empty_userinfo_action": test.Action,
+			}, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+			require.NoError(t, err)
+
+			token := (&oauth2.Token{}).WithExtra(map[string]any{
+				"id_token": createTestIDToken(t, map[string]any{"email": "john.doe@example.com", "role": "Editor"}),
+			})
+
+			actualResult, err := provider.UserInfo(context.Background(), server.Client(), token)
+			if test.ExpectedError != nil {
+				require.ErrorIs(t, err, test.ExpectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, "john.doe@example.com", actualResult.Email)
+			require.Equal(t, test.ExpectedRole, actualResult.Role)
+		})
+	}
+}
+
+func TestUserInfoPostRequest(t *testing.T) {
+	const accessToken = "access&token=with+reserved"
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		require.NoError(t, request.ParseForm())
+		if request.PostForm.Get("access_token") != accessToken || request.PostForm.Has("client") && request.PostForm.Get("client") != "grafana" {
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, err := writer.Write([]byte(`{"email": "john.doe@example.com", "role": "Admin"}`))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		Name             string
+		Method           string
+		BodyTemplate     string
+		ExpectedRole     org.RoleType
+		ExpectedSetupErr bool
+	}{
+		{
+			Name:         "Given the default method, the POST only endpoint yields no role",
+			ExpectedRole: "Viewer",
+		},
+		{
+			Name:         "Given method POST, send the access token in the form body",
+			Method:       "post",
+			ExpectedRole: "Admin",
+		},
+		{
+			Name:         "Given a body template, send the rendered form body",
+			Method:       "POST",
+			BodyTemplate: "client=grafana&access_token={{ .AccessToken }}",
+			ExpectedRole: "Admin",
+		},
+		{
+			Name:             "Given an unknown method, fail the setup",
+			Method:           "PUT",
+			ExpectedSetupErr: true,
+		},
+		{
+			Name:             "Given a body template for GET requests, fail the setup",
+			BodyTemplate:     "access_token={{ .AccessToken }}",
+			ExpectedSetupErr: true,
+		},
+		{
+			Name:             "Given an invalid body template, fail the setup",
+			Method:           "POST",
+			BodyTemplate:     "access_token={{ .AccessToken",
+			ExpectedSetupErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			provider, err := NewGenericOAuthProvider(map[string]any{
+				"api_url":                server.URL,
+				"role_attribute_path":    "role",
+				"userinfo_method":        test.Method,
+				"userinfo_body_template": test.BodyTemplate,
+			}, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+			if test.ExpectedSetupErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			token := (&oauth2.Token{AccessToken: accessToken}).WithExtra(map[string]any{
+				"id_token": createTestIDToken(t, map[string]any{"email": "john.doe@example.com"}),
+			})
+
+			actualResult, err := provider.UserInfo(context.Background(), server.Client(), token)
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedRole, actualResult.Role)
+		})
+	}
+}
+
+func TestNewGenericOAuthProvider_Scopes(t *testing.T) {
+	tests := []struct {
+		Name             string
+		Settings         map[string]any
+		ExpectedScopes   []string
+		ExpectedSetupErr bool
+	}{
+		{
+			Name:           "Given custom scopes, request them",
+			Settings:       map[string]any{"scopes": "openid email custom:grafana"},
+			ExpectedScopes: []string{"openid", "email", "custom:grafana"},
+		},
+		{
+			Name:           "Given a list of scopes, request them",
+			Settings:       map[string]any{"scopes": []string{"openid", "custom:grafana"}},
+			ExpectedScopes: []string{"openid", "custom:grafana"},
+		},
+		{
+			Name:           "Given empty_scopes, request no scopes",
+			Settings:       map[string]any{"scopes": "openid", "empty_scopes": "true"},
+			ExpectedScopes: []string{},
+		},
+		{
+			Name:             "Given empty scopes, fail the setup",
+			Settings:         map[string]any{"scopes": " , "},
+			ExpectedSetupErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			settings := map[string]any{"auth_url": "https://idp.example.com/authorize"}
+			for key, value := range test.Settings {
+				settings[key] = value
+			}
+			provider, err := NewGenericOAuthProvider(settings, setting.NewCfg(), featuremgmt.WithFeatures())
+			if test.ExpectedSetupErr {
+				require.ErrorContains(t, err, "scopes must not be empty")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedScopes, provider.Scopes)
+
+			authURL, err := url.Parse(provider.AuthCodeURL("state"))
+			require.NoError(t, err)
+			if len(test.ExpectedScopes) == 0 {
+				require.False(t, authURL.Query().Has("scope"))
+				return
+			}
+			require.Equal(t, strings.Join(test.ExpectedScopes, " "), authURL.Query().Get("scope"))
+		})
+	}
+}
+
+func TestNewGenericOAuthProvider_Prompt(t *testing.T) {
+	tests := []struct {
+		Name             string
+		Prompt           string
+		ExpectedPrompt   string
+		ExpectedSetupErr string
+	}{
+		{Name: "should omit the prompt by default"},
+		{Name: "should add the prompt", Prompt: "login", ExpectedPrompt: "login"},
+		{Name: "should add several prompt values", Prompt: " login  consent ", ExpectedPrompt: "login consent"},
+		{Name: "should fail on an unknown prompt value", Prompt: "login always", ExpectedSetupErr: `invalid prompt "always"`},
+		{Name: "should fail on none combined with other values", Prompt: "none login", ExpectedSetupErr: `prompt "none" can't be combined`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			provider, err := NewGenericOAuthProvider(map[string]any{
+				"auth_url": "https://idp.example.com/authorize",
+				"prompt":   test.Prompt,
+			}, setting.NewCfg(), featuremgmt.WithFeatures())
+			if test.ExpectedSetupErr != "" {
+				require.ErrorContains(t, err, test.ExpectedSetupErr)
+				return
+			}
+			require.NoError(t, err)
+
+			authURL, err := url.Parse(provider.AuthCodeURL("state"))
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedPrompt, authURL.Query().Get("prompt"))
+			require.Equal(t, test.ExpectedPrompt != "", authURL.Query().Has("prompt"))
+			require.False(t, authURL.Query().Has("login_hint"))
+
+			authURL, err = url.Parse(provider.AuthCodeURL("state", LoginHint("octopus@grafana.com")))
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedPrompt, authURL.Query().Get("prompt"))
+			require.Equal(t, "octopus@grafana.com", authURL.Query().Get("login_hint"))
+		})
+	}
+}
+
+func TestUserInfoUseIDToken(t *testing.T) {
+	tests := []struct {
+		Name             string
+		Settings         map[string]any
+		Body             string
+		ExpectedEmail    string
+		ExpectedRole     org.RoleType
+		ExpectedErr      error
+		ExpectedSetupErr bool
+	}{
+		{
+			Name:          "Given the default, prefer the id_token claims",
+			Body:          `{"email": "api@example.com", "role": "Editor"}`,
+			ExpectedEmail: "token@example.com",
+			ExpectedRole:  "Admin",
+		},
+		{
+			Name:          "Given use_id_token false, use the user info only",
+			Settings:      map[string]any{"use_id_token": "false"},
+			Body:          `{"email": "api@example.com", "role": "Editor"}`,
+			ExpectedEmail: "api@example.com",
+			ExpectedRole:  "Editor",
+		},
+		{
+			Name:        "Given use_id_token false, fail on an empty user info response",
+			Settings:    map[string]any{"use_id_token": "false"},
+			ExpectedErr: errEmptyUserInfo,
+		},
+		{
+			Name:             "Given use_id_token false, fail the setup with the id_token empty user info action",
+			Settings:         map[string]any{"use_id_token": "false", "empty_userinfo_action": "id_token"},
+			ExpectedSetupErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set("Content-Type", "application/json")
+				_, err := writer.Write([]byte(test.Body))
+				require.NoError(t, err)
+			}))
+			defer server.Close()
+
+			settings := map[string]any{"api_url": server.URL, "role_attribute_path": "role"}
+			for key, value := range test.Settings {
+				settings[key] = value
+			}
+			provider, err := NewGenericOAuthProvider(settings, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+			if test.ExpectedSetupErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			token := (&oauth2.Token{AccessToken: "access-token"}).WithExtra(map[string]any{
+				"id_token": createTestIDToken(t, map[string]any{"email": "token@example.com", "role": "Admin"}),
+			})
+
+			actualResult, err := provider.UserInfo(context.Background(), server.Client(), token)
+			if test.ExpectedErr != nil {
+				require.ErrorIs(t, err, test.ExpectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedEmail, actualResult.Email)
+			require.Equal(t, test.ExpectedRole, actualResult.Role)
+		})
+	}
+}
+
+func TestUserInfoAmbiguousRole(t *testing.T) {
+	provider, err := NewGenericOAuthProvider(map[string]any{
+		"role_attribute_path":   "roles",
+		"role_attribute_strict": "error",
+	}, setting.NewCfg(), featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, map[string]any{
+		"email": "john.doe@example.com",
+		"roles": []string{"Editor", "Admin"},
+	})})
+	_, err = provider.UserInfo(context.Background(), http.DefaultClient, token)
+	require.ErrorIs(t, err, ErrAmbiguousRole)
+}
+
+func TestUserInfoNoneRoleDenied(t *testing.T) {
+	provider, err := NewGenericOAuthProvider(map[string]any{
+		"role_attribute_path": "role",
+		"none_role_behavior":  "deny",
+	}, setting.NewCfg(), featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, map[string]any{
+		"email": "john.doe@example.com",
+		"role":  "None",
+	})})
+	_, err = provider.UserInfo(context.Background(), http.DefaultClient, token)
+	require.ErrorIs(t, err, ErrNoneRoleDenied)
+}
+
+func TestUserInfoSecondaryAPI(t *testing.T) {
+	tests := []struct {
+		Name                  string
+		SecondaryStatus       int
+		AllowSecondaryFailure bool
+		ExpectedRole          org.RoleType
+		ExpectedError         error
+		ExpectedWarning       string
+	}{
+		{
+			Name:            "Given a role only in the secondary response, use it",
+			SecondaryStatus: http.StatusOK,
+			ExpectedRole:    "Editor",
+		},
+		{
+			Name:            "Given a failing secondary endpoint, fail the login",
+			SecondaryStatus: http.StatusInternalServerError,
+			ExpectedError:   ErrUserInfoFetch,
+		},
+		{
+			Name:                  "Given a failing secondary endpoint and allow_secondary_failure, use the default role",
+			SecondaryStatus:       http.StatusInternalServerError,
+			AllowSecondaryFailure: true,
+			ExpectedRole:          "Viewer",
+			ExpectedWarning:       "ignored the secondary user info",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set("Content-Type", "application/json")
+				body := `{"email": "john.doe@example.com", "org": {"name": "Grafana"}}`
+				if request.URL.Path == "/profile" {
+					writer.WriteHeader(test.SecondaryStatus)
+					body = `{"email": "john@profile.example.com", "org": {"name": "Other", "role": "Editor"}}`
+				}
+				_, err := writer.Write([]byte(body))
+				require.NoError(t, err)
+			}))
+			defer server.Close()
+
+			provider, err := NewGenericOAuthProvider(map[string]any{
+				"api_url":                 server.URL + "/user",
+				"secondary_api_url":       server.URL + "/profile",
+				"allow_secondary_failure": test.AllowSecondaryFailure,
+				"role_attribute_path":     "org.role",
+				"login_attribute_path":    "org.name",
+			}, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+			require.NoError(t, err)
+
+			actualResult, err := provider.UserInfo(context.Background(), server.Client(), &oauth2.Token{AccessToken: "access-token", TokenType: "Bearer"})
+			if test.ExpectedError != nil {
+				require.ErrorIs(t, err, test.ExpectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedRole, actualResult.Role)
+			require.Equal(t, "john.doe@example.com", actualResult.Email, "the primary user info wins on conflicts")
+			require.Equal(t, "Grafana", actualResult.Login, "the primary user info wins on nested conflicts")
+			if test.ExpectedWarning == "" {
+				require.Empty(t, actualResult.Warnings)
+				return
+			}
+			require.Len(t, actualResult.Warnings, 1)
+			require.Contains(t, actualResult.Warnings[0], test.ExpectedWarning)
+		})
+	}
+}
+
+func TestUserInfoRootPath(t *testing.T) {
+	tests := []struct {
+		Name          string
+		RootPath      string
+		ExpectedEmail string
+		ExpectedRole  org.RoleType
+	}{
+		{
+			Name:          "Given no root path, evaluate the paths against the whole response",
+			ExpectedEmail: "",
+			ExpectedRole:  "Viewer",
+		},
+		{
+			Name:          "Given a root path, evaluate the paths against the selected object",
+			RootPath:      "data",
+			ExpectedEmail: "john.doe@example.com",
+			ExpectedRole:  "Editor",
+		},
+		{
+			Name:          "Given a root path not selecting an object, ignore the response",
+			RootPath:      "data.email",
+			ExpectedEmail: "",
+			ExpectedRole:  "Viewer",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set("Content-Type", "application/json")
+				_, err := writer.Write([]byte(`{"data": {"email": "john.doe@example.com", "login": "johndoe", "role": "Editor"}}`))
+				require.NoError(t, err)
+			}))
+			defer server.Close()
+
+			provider, err := NewGenericOAuthProvider(map[string]any{
+				"api_url":             server.URL + "/user",
+				"userinfo_root_path":  test.RootPath,
+				"role_attribute_path": "role",
+			}, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+			require.NoError(t, err)
+
+			token := (&oauth2.Token{AccessToken: "access-token", TokenType: "Bearer"}).WithExtra(map[string]any{"id_token": createTestIDToken(t, map[string]any{"sub": "123"})})
+			actualResult, err := provider.UserInfo(context.Background(), server.Client(), token)
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedEmail, actualResult.Email)
+			require.Equal(t, test.ExpectedRole, actualResult.Role)
+		})
+	}
+
+	t.Run("Given an invalid root path, fail the provider construction", func(t *testing.T) {
+		_, err := NewGenericOAuthProvider(map[string]any{"userinfo_root_path": "data["}, setting.NewCfg(), featuremgmt.WithFeatures())
+		require.ErrorIs(t, err, ErrAttributePath)
+	})
+}
+
+func TestUserInfoAuthOnly(t *testing.T) {
+	provider, err := NewGenericOAuthProvider(map[string]any{
+		"auth_only":                  "true",
+		"role_attribute_path":        "role",
+		"groups_attribute_path":      "groups",
+		"allowed_groups":             "admins",
+		"allow_assign_grafana_admin": "true",
+	}, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, map[string]any{
+		"sub":    "123",
+		"name":   "John Doe",
+		"email":  "john.doe@example.com",
+		"login":  "johndoe",
+		"role":   "GrafanaAdmin",
+		"groups": []string{"admins", "editors"},
+	})})
+	actualResult, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+	require.NoError(t, err)
+	require.Equal(t, &BasicUserInfo{
+		Id:    "123",
+		Name:  "John Doe",
+		Email: "john.doe@example.com",
+		Login: "johndoe",
+	}, actualResult)
+
+	t.Run("should still check allowed_groups", func(t *testing.T) {
+		token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, map[string]any{
+			"email":  "john.doe@example.com",
+			"groups": []string{"editors"},
+		})})
+		_, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+		require.ErrorIs(t, err, errMissingGroupMembership)
+	})
+}
+
+func TestUserInfoAccessTokenClaims(t *testing.T) {
+	jwtAccessToken := createTestIDToken(t, map[string]any{"role": "Editor", "email": "access@example.com"})
+
+	tests := []struct {
+		Name                 string
+		UseAccessTokenClaims bool
+		AccessToken          string
+		ExpectedRole         org.RoleType
+	}{
+		{
+			Name:                 "Given a JWT access token with a role, use the role",
+			UseAccessTokenClaims: true,
+			AccessToken:          jwtAccessToken,
+			ExpectedRole:         "Editor",
+		},
+		{
+			Name:                 "Given an opaque access token, ignore it",
+			UseAccessTokenClaims: true,
+			AccessToken:          "opaque-access-token",
+			ExpectedRole:         "Viewer",
+		},
+		{
+			Name:         "Given use_access_token_claims is not set, ignore the access token claims",
+			AccessToken:  jwtAccessToken,
+			ExpectedRole: "Viewer",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			provider, err := NewGenericOAuthProvider(map[string]any{
+				"role_attribute_path":     "role",
+				"use_access_token_claims": test.UseAccessTokenClaims,
+			}, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+			require.NoError(t, err)
+
+			token := (&oauth2.Token{AccessToken: test.AccessToken}).WithExtra(map[string]any{
+				"id_token": createTestIDToken(t, map[string]any{"email": "john.doe@example.com"}),
+			})
+
+			actualResult, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedRole, actualResult.Role)
+			require.Equal(t, "john.doe@example.com", actualResult.Email, "the id_token claims win on conflicts")
+		})
+	}
+}
+
+func TestUserInfoMissingAccessToken(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests.Add(1)
+		writer.Header().Set("Content-Type", "application/json")
+		_, err := writer.Write([]byte(`{"email": "john.doe@example.com"}`))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	provider, err := NewGenericOAuthProvider(map[string]any{"api_url": server.URL}, &setting.Cfg{}, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	t.Run("should fail fast without an access token and an id_token", func(t *testing.T) {
+		_, err := provider.UserInfo(context.Background(), server.Client(), &oauth2.Token{})
+		require.ErrorIs(t, err, ErrMissingAccessToken)
+		require.Zero(t, requests.Load(), "the user info is not requested")
+	})
+
+	t.Run("should use the id_token claims without an access token", func(t *testing.T) {
+		token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, map[string]any{"email": "john.doe@example.com"})})
+
+		userInfo, err := provider.UserInfo(context.Background(), server.Client(), token)
+		require.NoError(t, err)
+		require.Equal(t, "john.doe@example.com", userInfo.Email)
+	})
+}
+
+func TestUserInfoFetchError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	provider, err := NewGenericOAuthProvider(map[string]any{"api_url": server.URL}, &setting.Cfg{}, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	t.Run("should return ErrUserInfoFetch without token claims", func(t *testing.T) {
+		_, err := provider.UserInfo(context.Background(), server.Client(), &oauth2.Token{AccessToken: "access-token"})
+		require.ErrorIs(t, err, ErrUserInfoFetch)
+	})
+
+	t.Run("should use the id_token claims with a warning when the user info can't be fetched", func(t *testing.T) {
+		token := (&oauth2.Token{AccessToken: "access-token"}).WithExtra(map[string]any{
+			"id_token": createTestIDToken(t, map[string]any{"email": "john.doe@example.com"}),
+		})
+
+		userInfo, err := provider.UserInfo(context.Background(), server.Client(), token)
+		require.NoError(t, err)
+		require.Equal(t, "john.doe@example.com", userInfo.Email)
+		require.Len(t, userInfo.Warnings, 1)
+		require.Contains(t, userInfo.Warnings[0], "failed to get the user info")
+	})
+}
+
+func TestUserInfoRoleFromHeader(t *testing.T) {
+	tests := []struct {
+		Name          string
+		HeaderRole    string
+		TokenRole     string
+		ExpectedRole  org.RoleType
+		ExpectedError error
+	}{
+		{
+			Name:         "Given a role header, use it although the body has no role",
+			HeaderRole:   "editor",
+			ExpectedRole: "Editor",
+		},
+		{
+			Name:         "Given a role header, prefer it over the role attribute path",
+			HeaderRole:   "Admin",
+			TokenRole:    "Viewer",
+			ExpectedRole: "Admin",
+		},
+		{
+			Name:         "Given no role header, fall back to the role attribute path",
+			TokenRole:    "Editor",
+			ExpectedRole: "Editor",
+		},
+		{
+			Name:          "Given an invalid role header, fail the login",
+			HeaderRole:    "Superuser",
+			ExpectedError: ErrInvalidRole,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set("Content-Type", "application/json")
+				if test.HeaderRole != "" {
+					writer.Header().Set("X-Grafana-Role", test.HeaderRole)
+				}
+				_, err := writer.Write([]byte(`{"email": "john.doe@example.com"}`))
+				require.NoError(t, err)
+			}))
+			defer server.Close()
+
+			provider, err := NewGenericOAuthProvider(map[string]any{
+				"api_url":             server.URL,
+				"role_attribute_path": "role",
+				"role_from_header":    "X-Grafana-Role",
+			}, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+			require.NoError(t, err)
+
+			claims := map[string]any{"email": "john.doe@example.com"}
+			if test.TokenRole != "" {
+				claims["role"] = test.TokenRole
+			}
+			token := (&oauth2.Token{AccessToken: "access-token"}).WithExtra(map[string]any{
+				"id_token": createTestIDToken(t, claims),
+			})
+
+			actualResult, err := provider.UserInfo(context.Background(), server.Client(), token)
+			if test.ExpectedError != nil {
+				require.ErrorIs(t, err, test.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedRole, actualResult.Role)
+		})
+	}
+}
+
+func TestUserInfoGraphMemberOfGroups(t *testing.T) {
+	tests := []struct {
+		Name         string
+		Claims       map[string]any
+		GraphFails   bool
+		ExpectedRole org.RoleType
+	}{
+		{
+			Name:         "Given groups on the second Graph page, map the role from the groups",
+			Claims:       map[string]any{"email": "john.doe@example.com"},
+			ExpectedRole: "Admin",
+		},
+		{
+			Name:         "Given a failing Graph call, fall back to the role from the token",
+			Claims:       map[string]any{"email": "john.doe@example.com", "role": "Editor"},
+			GraphFails:   true,
+			ExpectedRole: "Editor",
+		},
+		{
+			Name:         "Given a failing Graph call and no role in the token, use the default role",
+			Claims:       map[string]any{"email": "john.doe@example.com"},
+			GraphFails:   true,
+			ExpectedRole: "Viewer",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var serverURL string
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				if test.GraphFails {
+					writer.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				writer.Header().Set("Content-Type", "application/json")
+				var body string
+				if request.URL.Query().Get("page") == "2" {
+					body = `{"value": [{"id": "admins-id"}]}`
+				} else {
+					body = fmt.Sprintf(`{"value": [{"id": "devs-id"}], "@odata.nextLink": "%s/memberOf?page=2"}`, serverURL)
+				}
+				_, err := writer.Write([]byte(body))
+				require.NoError(t, err)
+			}))
+			defer server.Close()
+			serverURL = server.URL
+
+			provider, err := NewGenericOAuthProvider(map[string]any{
+				"role_attribute_path": "role || contains(groups[*], 'admins-id') && 'Admin'",
+				"use_graph_member_of": "true",
+				"graph_member_of_url": server.URL + "/memberOf",
+			}, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+			require.NoError(t, err)
+
+			token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, test.Claims)})
+
+			actualResult, err := provider.UserInfo(context.Background(), server.Client(), token)
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedRole, actualResult.Role)
+		})
+	}
+}
+
+func TestExtractRoleFromMultipleRoleAttributePaths(t *testing.T) {
+	provider, err := NewGenericOAuthProvider(map[string]any{}, &setting.Cfg{}, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name              string
+		RoleAttributePath string
+		UserInfoJSON      string
+		ExpectedRole      org.RoleType
+		ExpectedError     error
+	}{
+		{
+			Name:              "Given a single path, behave as before",
+			RoleAttributePath: "role",
+			UserInfoJSON:      `{"role": "Editor"}`,
+			ExpectedRole:      "Editor",
+		},
+		{
+			Name:              "Given multiple paths, use the first path with a value",
+			RoleAttributePath: "role, roles[0], custom.grafana_role",
+			UserInfoJSON:      `{"custom": {"grafana_role": "Admin"}, "roles": ["Editor"]}`,
+			ExpectedRole:      "Editor",
+		},
+		{
+			Name:              "Given newline separated paths, use the first path with a value",
+			RoleAttributePath: "role\ncustom.grafana_role",
+			UserInfoJSON:      `{"custom": {"grafana_role": "Admin"}}`,
+			ExpectedRole:      "Admin",
+		},
+		{
+			Name:              "Given a path with function arguments, keep it whole",
+			RoleAttributePath: "contains(groups[*], 'admins') && 'Admin' || 'Viewer', role",
+			UserInfoJSON:      `{"groups": ["admins"], "role": "Editor"}`,
+			ExpectedRole:      "Admin",
+		},
+		{
+			Name:              "Given an invalid role on one path, use a later valid role",
+			RoleAttributePath: "role, custom.grafana_role",
+			UserInfoJSON:      `{"role": "Owner", "custom": {"grafana_role": "Viewer"}}`,
+			ExpectedRole:      "Viewer",
+		},
+		{
+			Name:              "Given only invalid roles, return invalid role error",
+			RoleAttributePath: "role, custom.grafana_role",
+			UserInfoJSON:      `{"role": "Owner"}`,
+			ExpectedError:     ErrInvalidRole,
+		},
+	}
+
+	for _, test := range tests {
+		provider.roleAttributePath = test.RoleAttributePath
+		t.Run(test.Name, func(t *testing.T) {
+			role, _, err := provider.extractRoleAndAdminOptional([]byte(test.UserInfoJSON), []string{})
+			if test.ExpectedError != nil {
+				require.ErrorIs(t, err, test.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedRole, role)
+		})
+	}
+}
+
+func TestExtractRoleFromNonScalarRoleAttributePath(t *testing.T) {
+	provider, err := NewGenericOAuthProvider(map[string]any{}, &setting.Cfg{}, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name              string
+		RoleAttributePath string
+		UserInfoJSON      string
+	}{
+		{
+			Name:              "Given a role attribute path resolving to an array, return configuration error",
+			RoleAttributePath: "orgs",
+			UserInfoJSON:      `{"orgs": ["org1:Admin", "org2:Viewer"]}`,
+		},
+		{
+			Name:              "Given a role attribute path resolving to an object, return configuration error",
+			RoleAttributePath: "orgs",
+			UserInfoJSON:      `{"orgs": {"org1": "Admin", "org2": "Viewer"}}`,
+		},
+		{
+			Name:              "Given a projection resolving to an array, return configuration error",
+			RoleAttributePath: "orgs[*].role",
+			UserInfoJSON:      `{"orgs": [{"role": "Admin"}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		provider.roleAttributePath = test.RoleAttributePath
+		t.Run(test.Name, func(t *testing.T) {
+			_, _, err := provider.extractRoleAndAdminOptional([]byte(test.UserInfoJSON), []string{})
+			require.ErrorIs(t, err, errRoleAttributePathNotScalar)
+		})
+	}
+}
+
+func TestExtractRoleWithNullRoleFallback(t *testing.T) {
+	provider, err := NewGenericOAuthProvider(map[string]any{
+		"role_attribute_path": "role",
+		"null_role_fallback":  "editor",
+	}, &setting.Cfg{AutoAssignOrgRole: string(org.RoleViewer)}, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name         string
+		UserInfoJSON string
+		ExpectedRole org.RoleType
+	}{
+		{
+			Name:         "Given a null role, return the fallback role",
+			UserInfoJSON: `{"role": null}`,
+			ExpectedRole: org.RoleEditor,
+		},
+		{
+			Name:         "Given a missing role, return the fallback role",
+			UserInfoJSON: `{}`,
+			ExpectedRole: org.RoleEditor,
+		},
+		{
+			Name:         "Given a role, return it instead of the fallback role",
+			UserInfoJSON: `{"role": "Admin"}`,
+			ExpectedRole: org.RoleAdmin,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			role, _, err := provider.extractRoleAndAdmin([]byte(test.UserInfoJSON), []string{})
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedRole, role)
+		})
+	}
+
+	t.Run("Given an invalid fallback role, return error on construction", func(t *testing.T) {
+		_, err := NewGenericOAuthProvider(map[string]any{
+			"null_role_fallback": "Superuser",
+		}, &setting.Cfg{}, featuremgmt.WithFeatures())
+		require.ErrorContains(t, err, "invalid null_role_fallback")
+	})
+}
+
+func TestUserInfoEmailAllowedRegex(t *testing.T) {
+	provider, err := NewGenericOAuthProvider(map[string]any{
+		"email_allowed_regex": `^e[0-9]{5}@example\.com$`,
+	}, &setting.Cfg{}, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name          string
+		Email         string
+		ExpectedError error
+	}{
+		{
+			Name:  "Given an email matching the regex, return userInfo",
+			Email: "e12345@example.com",
+		},
+		{
+			Name:          "Given an email not matching the regex, return error",
+			Email:         "john.doe@example.com",
+			ExpectedError: errEmailNotAllowed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, map[string]any{"email": test.Email})})
+
+			actualResult, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+			if test.ExpectedError != nil {
+				require.ErrorIs(t, err, test.ExpectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.Email, actualResult.Email)
+		})
+	}
+
+	t.Run("Given an invalid regex, return error on construction", func(t *testing.T) {
+		_, err := NewGenericOAuthProvider(map[string]any{
+			"email_allowed_regex": `^e[0-9`,
+		}, &setting.Cfg{}, featuremgmt.WithFeatures())
+		require.ErrorContains(t, err, "invalid email_allowed_regex")
+	})
+}
+
+func TestUserInfoEmailDomains(t *testing.T) {
+	tests := []struct {
+		Name           string
+		AllowedDomains string
+		BlockedDomains string
+		Email          string
+		ExpectedError  error
+	}{
+		{
+			Name:  "Given no domains, return userInfo",
+			Email: "john.doe@example.com",
+		},
+		{
+			Name:           "Given an allowed domain, return userInfo",
+			AllowedDomains: "example.com, grafana.com",
+			Email:          "john.doe@Example.com",
+		},
+		{
+			Name:           "Given a domain not in allowed_domains, return error",
+			AllowedDomains: "grafana.com",
+			Email:          "john.doe@example.com",
+			ExpectedError:  errEmailDomainNotAllowed,
+		},
+		{
+			Name:           "Given a domain not in blocked_domains, return userInfo",
+			BlockedDomains: "evil.com",
+			Email:          "john.doe@example.com",
+		},
+		{
+			Name:           "Given a blocked domain, return error",
+			BlockedDomains: "evil.com",
+			Email:          "john.doe@EVIL.com",
+			ExpectedError:  errEmailDomainNotAllowed,
+		},
+		{
+			Name:           "Given a domain both allowed and blocked, return error",
+			AllowedDomains: "example.com",
+			BlockedDomains: "example.com",
+			Email:          "john.doe@example.com",
+			ExpectedError:  errEmailDomainNotAllowed,
+		},
+		{
+			Name:           "Given an allowed domain that is not blocked, return userInfo",
+			AllowedDomains: "example.com evil.com",
+			BlockedDomains: "evil.com",
+			Email:          "john.doe@example.com",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			provider, err := NewGenericOAuthProvider(map[string]any{
+				"allowed_domains": test.AllowedDomains,
+				"blocked_domains": test.BlockedDomains,
+			}, &setting.Cfg{}, featuremgmt.WithFeatures())
+			require.NoError(t, err)
+
+			token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, map[string]any{"email": test.Email})})
+
+			actualResult, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+			if test.ExpectedError != nil {
+				require.ErrorIs(t, err, test.ExpectedError)
+				require.False(t, provider.IsEmailAllowed(test.Email))
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.Email, actualResult.Email)
+			require.True(t, provider.IsEmailAllowed(test.Email))
+		})
+	}
+}
+
+func TestUserInfoAllowedIssuers(t *testing.T) {
+	provider, err := NewGenericOAuthProvider(map[string]any{
+		"allowed_issuers": "https://idp-a.example.com, https://idp-b.example.com/",
+	}, &setting.Cfg{}, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name          string
+		Issuer        string
+		ExpectedError error
+	}{
+		{
+			Name:   "Given an allowed issuer, return userInfo",
+			Issuer: "https://idp-a.example.com",
+		},
+		{
+			Name:   "Given an allowed issuer with a trailing slash, return userInfo",
+			Issuer: "https://idp-a.example.com/",
+		},
+		{
+			Name:   "Given an issuer allowed with a trailing slash, return userInfo",
+			Issuer: "https://idp-b.example.com",
+		},
+		{
+			Name:          "Given a disallowed issuer, return error",
+			Issuer:        "https://evil.example.com",
+			ExpectedError: errIssuerNotAllowed,
+		},
+		{
+			Name:          "Given no issuer, return error",
+			ExpectedError: errIssuerNotAllowed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			claims := map[string]any{"email": "john.doe@example.com"}
+			if test.Issuer != "" {
+				claims["iss"] = test.Issuer
+			}
+			token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, claims)})
+
+			actualResult, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+			if test.ExpectedError != nil {
+				require.ErrorIs(t, err, test.ExpectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, "john.doe@example.com", actualResult.Email)
+		})
+	}
+}
+
+func TestUserInfoAllowedAudiences(t *testing.T) {
+	tests := []struct {
+		Name             string
+		AllowedAudiences string
+		Audience         any
+		ExpectedError    error
+	}{
+		{
+			Name:     "Given no allowed audiences, return userInfo",
+			Audience: "other-grafana",
+		},
+		{
+			Name:             "Given an allowed audience, return userInfo",
+			AllowedAudiences: "grafana-a, grafana-b",
+			Audience:         "grafana-b",
+		},
+		{
+			Name:             "Given an audience list containing an allowed audience, return userInfo",
+			AllowedAudiences: "grafana-a",
+			Audience:         []string{"other-grafana", "grafana-a"},
+		},
+		{
+			Name:             "Given a disallowed audience, return error",
+			AllowedAudiences: "grafana-a",
+			Audience:         "other-grafana",
+			ExpectedError:    errAudienceNotAllowed,
+		},
+		{
+			Name:             "Given an audience list without an allowed audience, return error",
+			AllowedAudiences: "grafana-a",
+			Audience:         []string{"other-grafana", "another-grafana"},
+			ExpectedError:    errAudienceNotAllowed,
+		},
+		{
+			Name:             "Given no audience, return error",
+			AllowedAudiences: "grafana-a",
+			ExpectedError:    errAudienceNotAllowed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			provider, err := NewGenericOAuthProvider(map[string]any{
+				"allowed_audiences": test.AllowedAudiences,
+			}, &setting.Cfg{}, featuremgmt.WithFeatures())
+			require.NoError(t, err)
+
+			claims := map[string]any{"email": "john.doe@example.com"}
+			if test.Audience != nil {
+				claims["aud"] = test.Audience
+			}
+			token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, claims)})
+
+			actualResult, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+			if test.ExpectedError != nil {
+				require.ErrorIs(t, err, test.ExpectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, "john.doe@example.com", actualResult.Email)
+		})
+	}
+}
+
+func TestUserInfoKeycloakRoles(t *testing.T) {
+	claims := map[string]any{
+		"email":        "john.doe@example.com",
+		"realm_access": map[string]any{"roles": []string{"offline_access", "grafana-editor"}},
+		"resource_access": map[string]any{
+			"grafana-client": map[string]any{"roles": []string{"admin"}},
+			"account":        map[string]any{"roles": []string{"manage-account"}},
+		},
+	}
+
+	tests := []struct {
+		Name             string
+		Settings         map[string]any
+		Claims           map[string]any
+		ExpectedGroups   []string
+		ExpectedRole     org.RoleType
+		ExpectedSetupErr bool
+	}{
+		{
+			Name: "Given only realm roles, use them as groups",
+			Settings: map[string]any{
+				"keycloak_roles":     "true",
+				"group_role_mapping": "grafana-editor=Editor",
+			},
+			Claims:         claims,
+			ExpectedGroups: []string{"offline_access", "grafana-editor"},
+			ExpectedRole:   "Editor",
+		},
+		{
+			Name: "Given a client ID, add the client roles to the realm roles",
+			Settings: map[string]any{
+				"keycloak_roles":      "true",
+				"keycloak_client_id":  "grafana-client",
+				"role_attribute_path": "contains(groups[*], 'admin') && 'Admin'",
+			},
+			Claims:         claims,
+			ExpectedGroups: []string{"offline_access", "grafana-editor", "admin"},
+			ExpectedRole:   "Admin",
+		},
+		{
+			Name: "Given a client without roles, use the realm roles",
+			Settings: map[string]any{
+				"keycloak_roles":     "true",
+				"keycloak_client_id": "unknown-client",
+			},
+			Claims:         claims,
+			ExpectedGroups: []string{"offline_access", "grafana-editor"},
+			ExpectedRole:   "Viewer",
+		},
+		{
+			Name: "Given no roles in the token, return no groups",
+			Settings: map[string]any{
+				"keycloak_roles":     "true",
+				"keycloak_client_id": "grafana-client",
+			},
+			Claims:         map[string]any{"email": "john.doe@example.com"},
+			ExpectedGroups: []string{},
+			ExpectedRole:   "Viewer",
+		},
+		{
+			Name: "Given groups_attribute_path, fail the setup",
+			Settings: map[string]any{
+				"keycloak_roles":        "true",
+				"groups_attribute_path": "groups",
+			},
+			ExpectedSetupErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			provider, err := NewGenericOAuthProvider(test.Settings, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+			if test.ExpectedSetupErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, test.Claims)})
+
+			actualResult, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+			require.NoError(t, err)
+			require.ElementsMatch(t, test.ExpectedGroups, actualResult.Groups)
+			require.Equal(t, test.ExpectedRole, actualResult.Role)
+		})
+	}
+}
+
+func TestKeycloakRolesAttributePath(t *testing.T) {
+	require.Equal(t, "realm_access.roles || `[]`", keycloakRolesAttributePath(""))
+	require.Equal(t, "[realm_access.roles || `[]`, resource_access.\"grafana-client\".roles || `[]`][]", keycloakRolesAttributePath("grafana-client"))
+}
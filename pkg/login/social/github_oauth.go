@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"regexp"
 	"strconv"
 	"strings"
 
@@ -55,6 +54,10 @@ func NewGitHubProvider(settings map[string]any, cfg *setting.Cfg, features *feat
 		return nil, err
 	}
 
+	if _, err := newTLSClientConfig(info); err != nil {
+		return nil, err
+	}
+
 	teamIds, err := mustInts(util.SplitString(info.Extra["team_ids"]))
 	if err != nil {
 		return nil, err
@@ -71,6 +74,10 @@ func NewGitHubProvider(settings map[string]any, cfg *setting.Cfg, features *feat
 		// skipOrgRoleSync: info.SkipOrgRoleSync
 	}
 
+	if err := provider.compileAttributePaths(); err != nil {
+		return nil, err
+	}
+
 	return provider, nil
 }
 
@@ -148,76 +155,51 @@ func (s *SocialGithub) FetchPrivateEmail(ctx context.Context, client *http.Clien
 
 func (s *SocialGithub) FetchTeamMemberships(ctx context.Context, client *http.Client) ([]GithubTeam, error) {
 	url := fmt.Sprintf(s.apiUrl + "/teams?per_page=100")
-	hasMore := true
 	teams := make([]GithubTeam, 0)
 
-	for hasMore {
-		response, err := s.httpGet(ctx, client, url)
-		if err != nil {
-			return nil, fmt.Errorf("Error getting team memberships: %s", err)
-		}
-
+	err := s.httpGetPages(ctx, client, url, func(body []byte) error {
 		var records []GithubTeam
-
-		err = json.Unmarshal(response.Body, &records)
-		if err != nil {
-			return nil, fmt.Errorf("Error getting team memberships: %s", err)
+		if err := json.Unmarshal(body, &records); err != nil {
+			return err
 		}
 
 		teams = append(teams, records...)
-
-		url, hasMore = s.HasMoreRecords(response.Headers)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error getting team memberships: %s", err)
 	}
 
 	return teams, nil
 }
 
 func (s *SocialGithub) HasMoreRecords(headers http.Header) (string, bool) {
-	value, exists := headers["Link"]
-	if !exists {
-		return "", false
-	}
-
-	pattern := regexp.MustCompile(`<([^>]+)>; rel="next"`)
-	matches := pattern.FindStringSubmatch(value[0])
-
-	if matches == nil {
-		return "", false
-	}
-
-	url := matches[1]
-
-	return url, true
+	url := nextPageURL(headers)
+	return url, url != ""
 }
 
 func (s *SocialGithub) FetchOrganizations(ctx context.Context, client *http.Client, organizationsUrl string) ([]string, error) {
-	url := organizationsUrl
-	hasMore := true
 	logins := make([]string, 0)
 
 	type Record struct {
 		Login string `json:"login"`
 	}
 
-	for hasMore {
-		response, err := s.httpGet(ctx, client, url)
-		if err != nil {
-			return nil, fmt.Errorf("error getting organizations: %s", err)
-		}
-
+	err := s.httpGetPages(ctx, client, organizationsUrl, func(body []byte) error {
 		var records []Record
-
-		err = json.Unmarshal(response.Body, &records)
-		if err != nil {
-			return nil, fmt.Errorf("error getting organizations: %s", err)
+		if err := json.Unmarshal(body, &records); err != nil {
+			return err
 		}
 
 		for _, record := range records {
 			logins = append(logins, record.Login)
 		}
-
-		url, hasMore = s.HasMoreRecords(response.Headers)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting organizations: %s", err)
 	}
+
 	return logins, nil
 }
 
@@ -231,7 +213,7 @@ func (s *SocialGithub) UserInfo(ctx context.Context, client *http.Client, token
 
 	response, err := s.httpGet(ctx, client, s.apiUrl)
 	if err != nil {
-		return nil, fmt.Errorf("error getting user info: %s", err)
+		return nil, ErrUserInfoFetch.Errorf("error getting user info: %w", err)
 	}
 
 	if err = json.Unmarshal(response.Body, &data); err != nil {
@@ -297,6 +279,8 @@ func (s *SocialGithub) UserInfo(ctx context.Context, client *http.Client, token
 		}
 	}
 
+	s.setProviderIdentity(userInfo)
+
 	return userInfo, nil
 }
 
@@ -2,10 +2,12 @@ package social
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -266,6 +268,54 @@ func TestSocialGitHub_UserInfo(t *testing.T) {
 	}
 }
 
+func TestSocialGitHub_FetchTeamMemberships_Pagination(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests.Add(1)
+		writer.Header().Set("Content-Type", "application/json")
+		switch request.URL.Query().Get("page") {
+		case "":
+			writer.Header().Set("Link", fmt.Sprintf(`<%s/user/teams?per_page=100&page=2>; rel="next", <%s/user/teams?per_page=100&page=2>; rel="last"`, "http://"+request.Host, "http://"+request.Host))
+			_, err := writer.Write([]byte(`[{"id": 1, "slug": "justice-league"}]`))
+			require.NoError(t, err)
+		case "2":
+			writer.Header().Set("Link", fmt.Sprintf(`<%s/user/teams?per_page=100>; rel="first"`, "http://"+request.Host))
+			_, err := writer.Write([]byte(`[{"id": 2, "slug": "avengers"}]`))
+			require.NoError(t, err)
+		default:
+			// links back to itself to check that the max pages guard ends the loop
+			writer.Header().Set("Link", fmt.Sprintf(`<%s/user/teams?page=loop>; rel="next"`, "http://"+request.Host))
+			_, err := writer.Write([]byte(`[]`))
+			require.NoError(t, err)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("should collect the teams of all linked pages", func(t *testing.T) {
+		s, err := NewGitHubProvider(map[string]any{"api_url": server.URL + "/user"}, &setting.Cfg{}, featuremgmt.WithFeatures())
+		require.NoError(t, err)
+
+		teams, err := s.FetchTeamMemberships(context.Background(), server.Client())
+		require.NoError(t, err)
+
+		slugs := make([]string, 0, len(teams))
+		for _, team := range teams {
+			slugs = append(slugs, team.Slug)
+		}
+		require.Equal(t, []string{"justice-league", "avengers"}, slugs)
+	})
+
+	t.Run("should stop after pagination_max_pages pages", func(t *testing.T) {
+		s, err := NewGitHubProvider(map[string]any{"api_url": server.URL + "/user", "pagination_max_pages": "3"}, &setting.Cfg{}, featuremgmt.WithFeatures())
+		require.NoError(t, err)
+
+		before := requests.Load()
+		_, err = s.FetchOrganizations(context.Background(), server.Client(), server.URL+"/user/teams?page=loop")
+		require.ErrorContains(t, err, "too many pages")
+		require.Equal(t, before+3, requests.Load())
+	})
+}
+
 func TestSocialGitHub_InitializeExtraFields(t *testing.T) {
 	type settingFields struct {
 		teamIds              []int
@@ -55,6 +55,10 @@ func NewGitLabProvider(settings map[string]any, cfg *setting.Cfg, features *feat
 		return nil, err
 	}
 
+	if _, err := newTLSClientConfig(info); err != nil {
+		return nil, err
+	}
+
 	config := createOAuthConfig(info, cfg, gitlabProviderName)
 	provider := &SocialGitlab{
 		SocialBase:      newSocialBase(gitlabProviderName, config, info, cfg.AutoAssignOrgRole, cfg.OAuthSkipOrgRoleUpdateSync, *features),
@@ -64,6 +68,10 @@ func NewGitLabProvider(settings map[string]any, cfg *setting.Cfg, features *feat
 		// skipOrgRoleSync: info.SkipOrgRoleSync
 	}
 
+	if err := provider.compileAttributePaths(); err != nil {
+		return nil, err
+	}
+
 	return provider, nil
 }
 
@@ -179,6 +187,8 @@ func (s *SocialGitlab) UserInfo(ctx context.Context, client *http.Client, token
 		s.log.Debug("AllowAssignGrafanaAdmin and skipOrgRoleSync are both set, Grafana Admin role will not be synced, consider setting one or the other")
 	}
 
+	s.setProviderIdentity(userInfo)
+
 	return userInfo, nil
 }
 
@@ -190,7 +200,7 @@ func (s *SocialGitlab) extractFromAPI(ctx context.Context, client *http.Client,
 	apiResp := &apiData{}
 	response, err := s.httpGet(ctx, client, s.apiUrl+"/user")
 	if err != nil {
-		return nil, fmt.Errorf("Error getting user info: %w", err)
+		return nil, ErrUserInfoFetch.Errorf("Error getting user info: %w", err)
 	}
 
 	if err = json.Unmarshal(response.Body, &apiResp); err != nil {
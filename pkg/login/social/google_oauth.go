@@ -42,6 +42,10 @@ func NewGoogleProvider(settings map[string]any, cfg *setting.Cfg, features *feat
 		return nil, err
 	}
 
+	if _, err := newTLSClientConfig(info); err != nil {
+		return nil, err
+	}
+
 	config := createOAuthConfig(info, cfg, googleProviderName)
 	provider := &SocialGoogle{
 		SocialBase:      newSocialBase(googleProviderName, config, info, cfg.AutoAssignOrgRole, cfg.OAuthSkipOrgRoleUpdateSync, *features),
@@ -56,6 +60,10 @@ func NewGoogleProvider(settings map[string]any, cfg *setting.Cfg, features *feat
 		provider.log.Warn("Using legacy Google API URL, please update your configuration")
 	}
 
+	if err := provider.compileAttributePaths(); err != nil {
+		return nil, err
+	}
+
 	return provider, nil
 }
 
@@ -115,6 +123,8 @@ func (s *SocialGoogle) UserInfo(ctx context.Context, client *http.Client, token
 
 	s.log.Debug("Resolved user info", "data", fmt.Sprintf("%+v", userInfo))
 
+	s.setProviderIdentity(userInfo)
+
 	return userInfo, nil
 }
 
@@ -134,7 +144,7 @@ func (s *SocialGoogle) extractFromAPI(ctx context.Context, client *http.Client)
 		data := googleAPIData{}
 		response, err := s.httpGet(ctx, client, s.apiUrl)
 		if err != nil {
-			return nil, fmt.Errorf("error retrieving legacy user info: %s", err)
+			return nil, ErrUserInfoFetch.Errorf("error retrieving legacy user info: %w", err)
 		}
 
 		if err := json.Unmarshal(response.Body, &data); err != nil {
@@ -153,7 +163,7 @@ func (s *SocialGoogle) extractFromAPI(ctx context.Context, client *http.Client)
 	data := googleUserData{}
 	response, err := s.httpGet(ctx, client, s.apiUrl)
 	if err != nil {
-		return nil, fmt.Errorf("error getting user info: %s", err)
+		return nil, ErrUserInfoFetch.Errorf("error getting user info: %w", err)
 	}
 
 	if err := json.Unmarshal(response.Body, &data); err != nil {
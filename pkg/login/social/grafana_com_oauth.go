@@ -34,6 +34,10 @@ func NewGrafanaComProvider(settings map[string]any, cfg *setting.Cfg, features *
 		return nil, err
 	}
 
+	if _, err := newTLSClientConfig(info); err != nil {
+		return nil, err
+	}
+
 	// Override necessary settings
 	info.AuthUrl = cfg.GrafanaComURL + "/oauth2/authorize"
 	info.TokenUrl = cfg.GrafanaComURL + "/api/oauth2/token"
@@ -86,7 +90,7 @@ func (s *SocialGrafanaCom) UserInfo(ctx context.Context, client *http.Client, _
 	response, err := s.httpGet(ctx, client, s.url+"/api/oauth2/user")
 
 	if err != nil {
-		return nil, fmt.Errorf("Error getting user info: %s", err)
+		return nil, ErrUserInfoFetch.Errorf("Error getting user info: %w", err)
 	}
 
 	err = json.Unmarshal(response.Body, &data)
@@ -113,6 +117,8 @@ func (s *SocialGrafanaCom) UserInfo(ctx context.Context, client *http.Client, _
 			s.allowedOrganizations, data.Orgs)
 	}
 
+	s.setProviderIdentity(userInfo)
+
 	return userInfo, nil
 }
 
@@ -0,0 +1,89 @@
+package social
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/grafana/grafana/pkg/infra/localcache"
+)
+
+const oidcDiscoveryPath = "/.well-known/openid-configuration"
+
+// oidcDiscoveryCache caches the discovery documents by issuer, so that reloading the provider settings doesn't
+// fetch them again. Documents expire as instructed by the Cache-Control header of the response.
+var oidcDiscoveryCache = localcache.New(defaultCacheExpiration, 2*defaultCacheExpiration)
+
+// oidcDiscoveryDocument holds the endpoints of an OpenID Connect discovery document.
+type oidcDiscoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// discoverOIDCEndpoints fills the auth_url, token_url and api_url settings left unset from the OpenID Connect
+// discovery document of issuer. Nothing is fetched when issuer is not set.
+func discoverOIDCEndpoints(info *OAuthInfo) error {
+	issuer := normalizeIssuer(info.Extra["issuer"])
+	if issuer == "" || info.AuthUrl != "" && info.TokenUrl != "" && info.ApiUrl != "" {
+		return nil
+	}
+
+	doc, err := fetchOIDCDiscoveryDocument(info, issuer)
+	if err != nil {
+		return fmt.Errorf("failed to discover the OpenID Connect endpoints of issuer %q: %w", issuer, err)
+	}
+
+	if info.AuthUrl == "" {
+		info.AuthUrl = doc.AuthorizationEndpoint
+	}
+	if info.TokenUrl == "" {
+		info.TokenUrl = doc.TokenEndpoint
+	}
+	if info.ApiUrl == "" {
+		info.ApiUrl = doc.UserinfoEndpoint
+	}
+	return nil
+}
+
+// fetchOIDCDiscoveryDocument returns the discovery document of issuer, from the cache when available.
+func fetchOIDCDiscoveryDocument(info *OAuthInfo, issuer string) (*oidcDiscoveryDocument, error) {
+	if cached, ok := oidcDiscoveryCache.Get(issuer); ok {
+		return cached.(*oidcDiscoveryDocument), nil
+	}
+
+	client, err := newHTTPClient(info)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, issuer+oidcDiscoveryPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unsuccessful response status code %d", resp.StatusCode)
+	}
+
+	var doc oidcDiscoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode the discovery document: %w", err)
+	}
+
+	// the issuer of the document must match, as in OpenID Connect Discovery 1.0 section 4.3
+	if normalizeIssuer(doc.Issuer) != issuer {
+		return nil, fmt.Errorf("discovery document has issuer %q", doc.Issuer)
+	}
+
+	oidcDiscoveryCache.Set(issuer, &doc, getCacheExpiration(resp.Header.Get("cache-control")))
+	return &doc, nil
+}
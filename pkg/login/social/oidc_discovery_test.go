@@ -0,0 +1,122 @@
+package social
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestNewGenericOAuthProvider_OIDCDiscovery(t *testing.T) {
+	newIdP := func(t *testing.T, issuer func(serverURL string) string) (*httptest.Server, *atomic.Int32) {
+		var requests atomic.Int32
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if request.URL.Path != oidcDiscoveryPath {
+				writer.WriteHeader(http.StatusNotFound)
+				return
+			}
+			requests.Add(1)
+			writer.Header().Set("Content-Type", "application/json")
+			_, err := writer.Write([]byte(`{
+				"issuer": "` + issuer(server.URL) + `",
+				"authorization_endpoint": "` + server.URL + `/authorize",
+				"token_endpoint": "` + server.URL + `/token",
+				"userinfo_endpoint": "` + server.URL + `/userinfo"
+			}`))
+			require.NoError(t, err)
+		}))
+		t.Cleanup(server.Close)
+		return server, &requests
+	}
+	sameIssuer := func(serverURL string) string { return serverURL }
+
+	t.Run("should fill the endpoints from the discovery document", func(t *testing.T) {
+		server, _ := newIdP(t, sameIssuer)
+
+		provider, err := NewGenericOAuthProvider(map[string]any{"issuer": server.URL + "/"}, setting.NewCfg(), featuremgmt.WithFeatures())
+		require.NoError(t, err)
+		require.Equal(t, server.URL+"/authorize", provider.Endpoint.AuthURL)
+		require.Equal(t, server.URL+"/token", provider.Endpoint.TokenURL)
+		require.Equal(t, server.URL+"/userinfo", provider.apiUrl)
+	})
+
+	t.Run("should report the discovered endpoints in the redacted config", func(t *testing.T) {
+		server, _ := newIdP(t, sameIssuer)
+
+		provider, err := NewGenericOAuthProvider(map[string]any{"issuer": server.URL, "client_secret": "secret"}, setting.NewCfg(), featuremgmt.WithFeatures())
+		require.NoError(t, err)
+
+		config := provider.RedactedConfig()
+		require.Equal(t, server.URL+"/authorize", config["auth_url"])
+		require.Equal(t, server.URL+"/token", config["token_url"])
+		require.Equal(t, server.URL+"/userinfo", config["api_url"])
+		require.Equal(t, "[REDACTED]", config["client_secret"])
+	})
+
+	t.Run("should keep the explicitly set endpoints", func(t *testing.T) {
+		server, _ := newIdP(t, sameIssuer)
+
+		provider, err := NewGenericOAuthProvider(map[string]any{
+			"issuer":    server.URL,
+			"token_url": "https://idp.example.com/token",
+			"api_url":   "https://idp.example.com/me",
+		}, setting.NewCfg(), featuremgmt.WithFeatures())
+		require.NoError(t, err)
+		require.Equal(t, server.URL+"/authorize", provider.Endpoint.AuthURL)
+		require.Equal(t, "https://idp.example.com/token", provider.Endpoint.TokenURL)
+		require.Equal(t, "https://idp.example.com/me", provider.apiUrl)
+	})
+
+	t.Run("should cache the discovery document", func(t *testing.T) {
+		server, requests := newIdP(t, sameIssuer)
+
+		for i := 0; i < 2; i++ {
+			_, err := NewGenericOAuthProvider(map[string]any{"issuer": server.URL}, setting.NewCfg(), featuremgmt.WithFeatures())
+			require.NoError(t, err)
+		}
+		require.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("should not fetch the discovery document when all the endpoints are set", func(t *testing.T) {
+		server, requests := newIdP(t, sameIssuer)
+
+		_, err := NewGenericOAuthProvider(map[string]any{
+			"issuer":    server.URL,
+			"auth_url":  "https://idp.example.com/authorize",
+			"token_url": "https://idp.example.com/token",
+			"api_url":   "https://idp.example.com/me",
+		}, setting.NewCfg(), featuremgmt.WithFeatures())
+		require.NoError(t, err)
+		require.Zero(t, requests.Load())
+	})
+
+	t.Run("should fail on an issuer mismatch", func(t *testing.T) {
+		server, _ := newIdP(t, func(string) string { return "https://other.example.com" })
+
+		_, err := NewGenericOAuthProvider(map[string]any{"issuer": server.URL}, setting.NewCfg(), featuremgmt.WithFeatures())
+		require.ErrorContains(t, err, "failed to discover the OpenID Connect endpoints")
+		require.ErrorContains(t, err, `discovery document has issuer "https://other.example.com"`)
+	})
+
+	t.Run("should fail when the issuer is unreachable", func(t *testing.T) {
+		server, _ := newIdP(t, sameIssuer)
+		issuer := server.URL
+		server.Close()
+
+		_, err := NewGenericOAuthProvider(map[string]any{"issuer": issuer}, setting.NewCfg(), featuremgmt.WithFeatures())
+		require.ErrorContains(t, err, "failed to discover the OpenID Connect endpoints of issuer")
+	})
+
+	t.Run("should fail when the discovery document is missing", func(t *testing.T) {
+		server, _ := newIdP(t, sameIssuer)
+
+		_, err := NewGenericOAuthProvider(map[string]any{"issuer": server.URL + "/tenant"}, setting.NewCfg(), featuremgmt.WithFeatures())
+		require.ErrorContains(t, err, "unsuccessful response status code 404")
+	})
+}
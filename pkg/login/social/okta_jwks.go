@@ -0,0 +1,117 @@
+package social
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+const oktaJWKSCacheKey = "okta_oauth_jwks"
+
+// oktaJWKSRefreshInterval is the minimum interval between refetches of the key set for id_tokens signed with an
+// unknown key, so that such tokens can't make every login hit the keys endpoint.
+const oktaJWKSRefreshInterval = time.Minute
+
+// oktaJWKSURL returns jwk_set_url, defaulting to the keys endpoint of the authorization server of auth_url.
+func oktaJWKSURL(info *OAuthInfo) string {
+	if url := info.Extra["jwk_set_url"]; url != "" {
+		return url
+	}
+
+	if !strings.HasSuffix(info.AuthUrl, "/v1/authorize") {
+		return ""
+	}
+	return strings.TrimSuffix(info.AuthUrl, "/v1/authorize") + "/v1/keys"
+}
+
+// retrieveJWKS returns the key set of the IdP, from the cache unless refresh is set. A refresh returns the last
+// fetched key set when it was fetched less than jwksRefreshInterval ago.
+func (s *SocialOkta) retrieveJWKS(ctx context.Context, client *http.Client, refresh bool) (*keySetJWKS, error) {
+	if !refresh {
+		if val, ok := s.jwksCache.Get(oktaJWKSCacheKey); ok {
+			s.log.Debug("Retrieved cached key set")
+			return val.(*keySetJWKS), nil
+		}
+	}
+
+	s.jwksMu.Lock()
+	defer s.jwksMu.Unlock()
+
+	if refresh && s.jwks != nil && time.Since(s.jwksFetchedAt) < s.jwksRefreshInterval {
+		s.log.Debug("Skipped refetching the key set", "fetchedAt", s.jwksFetchedAt)
+		return s.jwks, nil
+	}
+	// another login may have fetched the key set while this one was waiting
+	if val, ok := s.jwksCache.Get(oktaJWKSCacheKey); ok && !refresh {
+		s.log.Debug("Retrieved cached key set")
+		return val.(*keySetJWKS), nil
+	}
+
+	resp, err := s.httpGet(ctx, client, s.jwkSetURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks keySetJWKS
+	if err := json.NewDecoder(bytes.NewReader(resp.Body)).Decode(&jwks); err != nil {
+		return nil, err
+	}
+
+	cacheExpiration := getCacheExpiration(resp.Headers.Get("cache-control"))
+	s.log.Debug("Retrieved key set", "url", s.jwkSetURL, "cacheExpiration", cacheExpiration)
+	s.jwksCache.Set(oktaJWKSCacheKey, &jwks, cacheExpiration)
+	s.jwks = &jwks
+	s.jwksFetchedAt = time.Now()
+
+	return &jwks, nil
+}
+
+// verifyIDToken verifies the signature of the id_token against the key set of the IdP and rejects expired tokens,
+// then returns its claims.
+func (s *SocialOkta) verifyIDToken(ctx context.Context, client *http.Client, parsedToken *jwt.JSONWebToken) (*OktaClaims, error) {
+	if len(parsedToken.Headers) == 0 {
+		return nil, ErrInvalidIDToken.Errorf("id_token has no header")
+	}
+	keyID := parsedToken.Headers[0].KeyID
+
+	// the key set is fetched again when the key is unknown, in case the IdP rotated its keys
+	for _, refresh := range []bool{false, true} {
+		keyset, err := s.retrieveJWKS(ctx, client, refresh)
+		if err != nil {
+			return nil, ErrInvalidIDToken.Errorf("error retrieving jwks: %w", err)
+		}
+
+		keys := keyset.Key(keyID)
+		if len(keys) == 0 {
+			continue
+		}
+
+		for _, key := range keys {
+			var claims OktaClaims
+			var registered jwt.Claims
+			if err := parsedToken.Claims(key, &claims, &registered); err != nil {
+				s.log.Warn("Failed to verify id_token with key", "kid", key.KeyID, "err", err)
+				continue
+			}
+
+			if registered.Expiry == nil {
+				return nil, ErrInvalidIDToken.Errorf("id_token has no exp claim")
+			}
+			if err := registered.ValidateWithLeeway(jwt.Expected{Time: time.Now()}, jwt.DefaultLeeway); err != nil {
+				return nil, ErrInvalidIDToken.Errorf("id_token is not valid: %w", err)
+			}
+
+			return &claims, nil
+		}
+
+		return nil, ErrInvalidIDToken.Errorf("id_token signature verification failed")
+	}
+
+	s.log.Warn("Signing key not found", "kid", keyID)
+	return nil, ErrInvalidIDToken.Errorf("id_token signing key %q not found", keyID)
+}
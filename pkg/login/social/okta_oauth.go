@@ -1,15 +1,19 @@
 package social
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/go-jose/go-jose/v3/jwt"
 	"golang.org/x/oauth2"
 
+	"github.com/grafana/grafana/pkg/infra/localcache"
 	"github.com/grafana/grafana/pkg/models/roletype"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/setting"
@@ -22,6 +26,21 @@ type SocialOkta struct {
 	apiUrl          string
 	allowedGroups   []string
 	skipOrgRoleSync bool
+	validateIDToken bool
+	jwkSetURL       string
+	jwksCache       *localcache.CacheService
+	// jwksMu serializes the key set fetches. jwks is the last fetched key set, which is refetched for an unknown
+	// key at most once per jwksRefreshInterval since jwksFetchedAt
+	jwksMu              sync.Mutex
+	jwks                *keySetJWKS
+	jwksFetchedAt       time.Time
+	jwksRefreshInterval time.Duration
+	loginAttributePath  string
+	emailAttributePath  string
+	// allowEmptyUserInfo uses the id_token claims when the user info endpoint is missing or returns an empty response
+	allowEmptyUserInfo bool
+	// groupsAttributePath reads the groups returned in the user info, role resolution keeps using the groups claim
+	groupsAttributePath string
 }
 
 type OktaUserInfoJson struct {
@@ -37,10 +56,13 @@ type OktaUserInfoJson struct {
 }
 
 type OktaClaims struct {
-	ID                string `json:"sub"`
-	Email             string `json:"email"`
-	PreferredUsername string `json:"preferred_username"`
-	Name              string `json:"name"`
+	ID                string   `json:"sub"`
+	Email             string   `json:"email"`
+	PreferredUsername string   `json:"preferred_username"`
+	Name              string   `json:"name"`
+	Acr               string   `json:"acr"`
+	Iss               string   `json:"iss"`
+	Aud               audience `json:"aud"`
 }
 
 func NewOktaProvider(settings map[string]any, cfg *setting.Cfg, features *featuremgmt.FeatureManager) (*SocialOkta, error) {
@@ -49,6 +71,15 @@ func NewOktaProvider(settings map[string]any, cfg *setting.Cfg, features *featur
 		return nil, err
 	}
 
+	var errs []error
+	if _, err := newTLSClientConfig(info); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := parseTransportSettings(info); err != nil {
+		errs = append(errs, err)
+	}
+
 	config := createOAuthConfig(info, cfg, oktaProviderName)
 	provider := &SocialOkta{
 		SocialBase:    newSocialBase(oktaProviderName, config, info, cfg.AutoAssignOrgRole, cfg.OAuthSkipOrgRoleUpdateSync, *features),
@@ -56,13 +87,42 @@ func NewOktaProvider(settings map[string]any, cfg *setting.Cfg, features *featur
 		allowedGroups: info.AllowedGroups,
 		// FIXME: Move skipOrgRoleSync to OAuthInfo
 		// skipOrgRoleSync: info.SkipOrgRoleSync
-		skipOrgRoleSync: cfg.OktaSkipOrgRoleSync,
+		skipOrgRoleSync:     cfg.OktaSkipOrgRoleSync,
+		validateIDToken:     mustBool(info.Extra["validate_id_token"], false),
+		jwkSetURL:           oktaJWKSURL(info),
+		jwksCache:           localcache.New(defaultCacheExpiration, 2*defaultCacheExpiration),
+		jwksRefreshInterval: oktaJWKSRefreshInterval,
+		loginAttributePath:  info.Extra["login_attribute_path"],
+		emailAttributePath:  info.EmailAttributePath,
+		groupsAttributePath: info.GroupsAttributePath,
+		allowEmptyUserInfo:  mustBool(info.Extra["allow_empty_userinfo"], false),
+	}
+
+	if provider.validateIDToken && provider.jwkSetURL == "" {
+		errs = append(errs, fmt.Errorf("validate_id_token requires jwk_set_url when auth_url is not an Okta authorization endpoint"))
 	}
 
 	if info.UseRefreshToken && features.IsEnabledGlobally(featuremgmt.FlagAccessTokenExpirationCheck) {
 		appendUniqueScope(config, OfflineAccessScope)
 	}
 
+	for _, validate := range []func() error{
+		provider.validateEmptyUserInfoAction,
+		provider.compileUserInfoRequest,
+		provider.compileEmailAllowedRegex,
+		provider.validateNullRoleFallback,
+		provider.loadIDTokenDecryptKey,
+		provider.compileAttributePaths,
+	} {
+		if err := validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, newInvalidProviderSettingsError(oktaProviderName, errs)
+	}
+
 	return provider, nil
 }
 
@@ -80,37 +140,111 @@ func (s *SocialOkta) UserInfo(ctx context.Context, client *http.Client, token *o
 		return nil, fmt.Errorf("no id_token found")
 	}
 
-	parsedToken, err := jwt.ParseSigned(idToken.(string))
+	tokenString, err := s.decryptIDToken(idToken.(string))
+	if err != nil {
+		return nil, err
+	}
+
+	parsedToken, err := jwt.ParseSigned(tokenString)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing id token: %w", err)
 	}
 
 	var claims OktaClaims
-	if err := parsedToken.UnsafeClaimsWithoutVerification(&claims); err != nil {
+	if s.validateIDToken {
+		verified, err := s.verifyIDToken(ctx, client, parsedToken)
+		if err != nil {
+			return nil, err
+		}
+		claims = *verified
+	} else if err := parsedToken.UnsafeClaimsWithoutVerification(&claims); err != nil {
 		return nil, fmt.Errorf("error getting claims from id token: %w", err)
 	}
 
+	if err := s.checkIssuer(claims.Iss); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkAudience(claims.Aud); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkACR(claims.Acr); err != nil {
+		return nil, err
+	}
+
+	// the email is checked once the user info response is known when it is resolved from email_attribute_path
 	email := claims.extractEmail()
-	if email == "" {
-		return nil, errors.New("error getting user info: no email found in access token")
+	if s.emailAttributePath == "" {
+		if err := s.checkEmail(email); err != nil {
+			return nil, err
+		}
 	}
 
 	var data OktaUserInfoJson
-	err = s.extractAPI(ctx, &data, client)
+	err = s.extractAPI(ctx, &data, client, token)
+	if s.allowEmptyUserInfo && (errors.Is(err, errEmptyUserInfo) || isNotFoundError(err)) {
+		s.log.Debug("Missing or empty user info response, using id_token claims", "err", err)
+		err = s.extractIDTokenClaims(&data, idToken)
+	} else if errors.Is(err, errEmptyUserInfo) {
+		switch s.emptyUserInfoAction {
+		case emptyUserInfoActionIDToken:
+			s.log.Debug("Empty user info response, using id_token claims")
+			err = s.extractIDTokenClaims(&data, idToken)
+		case emptyUserInfoActionDefaults:
+			s.log.Debug("Empty user info response, proceeding with defaults")
+			err = nil
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if s.useIDTokenClaims {
+		rawJSON, err := s.mergeIDTokenClaims(data.rawJSON, idToken)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(rawJSON, &data); err != nil {
+			return nil, fmt.Errorf("error decoding user info merged with id_token claims: %w", err)
+		}
+		data.rawJSON = rawJSON
+	}
+
+	if s.emailAttributePath != "" {
+		email = s.extractEmail(&data, email)
+		if err := s.checkEmail(email); err != nil {
+			return nil, err
+		}
+	}
+
+	// groups_attribute_path only changes the groups returned to the caller and checked against allowed_groups,
+	// roles are still resolved from the groups claim.
 	groups := s.GetGroups(&data)
-	if !s.IsGroupMember(groups) {
+	userGroups := groups
+	if s.groupsAttributePath != "" {
+		userGroups = s.extractGroups(&data)
+	}
+	if !s.IsGroupMember(userGroups) {
 		return nil, errMissingGroupMembership
 	}
 
 	var role roletype.RoleType
+	var rawRole string
 	var isGrafanaAdmin *bool
 	if !s.skipOrgRoleSync {
 		var grafanaAdmin bool
-		role, grafanaAdmin, err = s.extractRoleAndAdmin(data.rawJSON, groups)
+		if s.rolePolicyURL != "" {
+			role, grafanaAdmin, err = s.searchRolePolicy(ctx, data.rawJSON, groups)
+			if err == nil && role == "" {
+				role = s.defaultRole()
+			}
+		} else {
+			role, grafanaAdmin, err = s.extractRoleAndAdmin(data.rawJSON, groups)
+			if err == nil {
+				rawRole = s.searchRawRole(data.rawJSON, groups)
+			}
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -123,28 +257,111 @@ func (s *SocialOkta) UserInfo(ctx context.Context, client *http.Client, token *o
 		s.log.Debug("AllowAssignGrafanaAdmin and skipOrgRoleSync are both set, Grafana Admin role will not be synced, consider setting one or the other")
 	}
 
-	return &BasicUserInfo{
+	userInfo := &BasicUserInfo{
 		Id:             claims.ID,
 		Name:           claims.Name,
 		Email:          email,
-		Login:          email,
+		Login:          s.extractLogin(&data, idToken, email),
 		Role:           role,
+		RawRole:        rawRole,
 		IsGrafanaAdmin: isGrafanaAdmin,
-		Groups:         groups,
-	}, nil
+		Groups:         userGroups,
+	}
+	s.capRoleByDomain(userInfo)
+	s.setProviderIdentity(userInfo)
+
+	return userInfo, nil
+}
+
+// extractEmail evaluates email_attribute_path against the user info response, falling back to the
+// id_token email when it yields nothing.
+func (s *SocialOkta) extractEmail(data *OktaUserInfoJson, idTokenEmail string) string {
+	s.log.Debug("Searching for email among JSON", "emailAttributePath", s.emailAttributePath)
+	email, err := s.searchJSONForStringAttr(s.emailAttributePath, data.rawJSON)
+	if err != nil {
+		s.log.Error("Failed to search user info JSON for email attribute", "error", err)
+	}
+	if email != "" {
+		return email
+	}
+
+	return idTokenEmail
+}
+
+func (s *SocialOkta) checkEmail(email string) error {
+	if email == "" {
+		return ErrEmailNotFound
+	}
+
+	if err := s.checkEmailAllowedRegex(email); err != nil {
+		return err
+	}
+
+	return s.checkEmailDomain(email)
+}
+
+// extractLogin evaluates login_attribute_path against the user info response, then the id_token claims,
+// falling back to the email when it is not set or yields nothing.
+func (s *SocialOkta) extractLogin(data *OktaUserInfoJson, idToken any, email string) string {
+	if s.loginAttributePath == "" {
+		return email
+	}
+
+	s.log.Debug("Searching for login among JSON", "loginAttributePath", s.loginAttributePath)
+	login, err := s.searchJSONForStringAttr(s.loginAttributePath, data.rawJSON)
+	if err != nil {
+		s.log.Error("Failed to search user info JSON for login attribute", "error", err)
+	}
+	if login != "" {
+		return login
+	}
+
+	rawJSON, err := s.retrieveRawIDToken(idToken)
+	if err != nil {
+		s.log.Warn("Error retrieving id_token claims", "error", err)
+		return email
+	}
+
+	login, err = s.searchJSONForStringAttr(s.loginAttributePath, rawJSON)
+	if err != nil {
+		s.log.Error("Failed to search id_token JSON for login attribute", "error", err)
+	}
+	if login != "" {
+		return login
+	}
+
+	return email
+}
+
+func (s *SocialOkta) SupportBundleContent(bf *bytes.Buffer) error {
+	bf.WriteString("## Okta specific configuration\n\n")
+	bf.WriteString("```ini\n")
+	bf.WriteString(fmt.Sprintf("login_attribute_path = %s\n", s.loginAttributePath))
+	bf.WriteString(fmt.Sprintf("email_attribute_path = %s\n", s.emailAttributePath))
+	bf.WriteString(fmt.Sprintf("groups_attribute_path = %s\n", s.groupsAttributePath))
+	bf.WriteString(fmt.Sprintf("validate_id_token = %v\n", s.validateIDToken))
+	bf.WriteString(fmt.Sprintf("allow_empty_userinfo = %v\n", s.allowEmptyUserInfo))
+	bf.WriteString(fmt.Sprintf("jwk_set_url = %s\n", s.jwkSetURL))
+	bf.WriteString("```\n\n")
+
+	return s.SocialBase.SupportBundleContent(bf)
 }
 
 func (s *SocialOkta) GetOAuthInfo() *OAuthInfo {
 	return s.info
 }
 
-func (s *SocialOkta) extractAPI(ctx context.Context, data *OktaUserInfoJson, client *http.Client) error {
-	rawUserInfoResponse, err := s.httpGet(ctx, client, s.apiUrl)
+func (s *SocialOkta) extractAPI(ctx context.Context, data *OktaUserInfoJson, client *http.Client, token *oauth2.Token) error {
+	rawUserInfoResponse, err := s.userInfoGet(ctx, client, token, s.apiUrl)
 	if err != nil {
 		s.log.Debug("Error getting user info response", "url", s.apiUrl, "error", err)
 		return fmt.Errorf("error getting user info response: %w", err)
 	}
 	data.rawJSON = rawUserInfoResponse.Body
+	if isEmptyBody(data.rawJSON) {
+		data.rawJSON = []byte{}
+		return errEmptyUserInfo.Errorf("user info endpoint %s returned an empty response", s.apiUrl)
+	}
 
 	err = json.Unmarshal(data.rawJSON, data)
 	if err != nil {
@@ -157,6 +374,37 @@ func (s *SocialOkta) extractAPI(ctx context.Context, data *OktaUserInfoJson, cli
 	return nil
 }
 
+// extractIDTokenClaims uses the id_token claims in place of the user info response.
+func (s *SocialOkta) extractIDTokenClaims(data *OktaUserInfoJson, idToken any) error {
+	rawJSON, err := s.retrieveRawIDToken(idToken)
+	if err != nil {
+		return fmt.Errorf("error retrieving id_token claims: %w", err)
+	}
+
+	if err := json.Unmarshal(rawJSON, data); err != nil {
+		return fmt.Errorf("error decoding id_token claims: %w", err)
+	}
+	data.rawJSON = rawJSON
+
+	return nil
+}
+
+// extractGroups evaluates groups_attribute_path against the user info response, merged with the id_token claims
+// when id_token claims are used. It returns nil when the path yields no groups.
+func (s *SocialOkta) extractGroups(data *OktaUserInfoJson) []string {
+	s.log.Debug("Searching for groups among JSON", "groupsAttributePath", s.groupsAttributePath)
+	groups, err := s.searchJSONForStringArrayAttr(s.groupsAttributePath, data.rawJSON)
+	if err != nil {
+		s.log.Error("Failed to search user info JSON for groups attribute", "error", err)
+		return nil
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	return groups
+}
+
 func (s *SocialOkta) GetGroups(data *OktaUserInfoJson) []string {
 	groups := make([]string, 0)
 	if len(data.Groups) > 0 {
@@ -0,0 +1,54 @@
+package social
+
+import (
+	"sort"
+
+	"github.com/grafana/grafana/pkg/services/org"
+)
+
+// DiffOrgRoles compares the org roles the IdP grants a user with the user's current org memberships. It returns
+// the memberships to add and the roles to update, keyed by org ID, and the IDs of the orgs to remove the user from,
+// sorted. Orgs missing from desired, or mapped to org.RoleNone, are removed.
+func DiffOrgRoles(current, desired map[int64]org.RoleType) (toAdd, toUpdate map[int64]org.RoleType, toRemove []int64) {
+	toAdd = make(map[int64]org.RoleType)
+	toUpdate = make(map[int64]org.RoleType)
+
+	for orgID, role := range desired {
+		if role == org.RoleNone {
+			continue
+		}
+
+		currentRole, ok := current[orgID]
+		switch {
+		case !ok:
+			toAdd[orgID] = role
+		case currentRole != role:
+			toUpdate[orgID] = role
+		}
+	}
+
+	for orgID := range current {
+		if role, ok := desired[orgID]; !ok || role == org.RoleNone {
+			toRemove = append(toRemove, orgID)
+		}
+	}
+	sort.Slice(toRemove, func(i, j int) bool { return toRemove[i] < toRemove[j] })
+
+	return toAdd, toUpdate, toRemove
+}
+
+// OrgRoleEntry is the role of a user in an org.
+type OrgRoleEntry struct {
+	OrgID int64
+	Role  org.RoleType
+}
+
+// SortedOrgRoles returns the org roles of m sorted by org ID, so that they can be iterated in a deterministic order.
+func SortedOrgRoles(m map[int64]org.RoleType) []OrgRoleEntry {
+	entries := make([]OrgRoleEntry, 0, len(m))
+	for orgID, role := range m {
+		entries = append(entries, OrgRoleEntry{OrgID: orgID, Role: role})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].OrgID < entries[j].OrgID })
+	return entries
+}
@@ -0,0 +1,89 @@
+package social
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/org"
+)
+
+func TestDiffOrgRoles(t *testing.T) {
+	tests := []struct {
+		name             string
+		current          map[int64]org.RoleType
+		desired          map[int64]org.RoleType
+		expectedToAdd    map[int64]org.RoleType
+		expectedToUpdate map[int64]org.RoleType
+		expectedToRemove []int64
+	}{
+		{
+			name:             "should not change matching memberships",
+			current:          map[int64]org.RoleType{1: org.RoleViewer, 2: org.RoleEditor},
+			desired:          map[int64]org.RoleType{1: org.RoleViewer, 2: org.RoleEditor},
+			expectedToAdd:    map[int64]org.RoleType{},
+			expectedToUpdate: map[int64]org.RoleType{},
+		},
+		{
+			name:             "should add new memberships",
+			current:          map[int64]org.RoleType{1: org.RoleViewer},
+			desired:          map[int64]org.RoleType{1: org.RoleViewer, 2: org.RoleEditor},
+			expectedToAdd:    map[int64]org.RoleType{2: org.RoleEditor},
+			expectedToUpdate: map[int64]org.RoleType{},
+		},
+		{
+			name:             "should update changed roles",
+			current:          map[int64]org.RoleType{1: org.RoleViewer, 2: org.RoleAdmin},
+			desired:          map[int64]org.RoleType{1: org.RoleEditor, 2: org.RoleAdmin},
+			expectedToAdd:    map[int64]org.RoleType{},
+			expectedToUpdate: map[int64]org.RoleType{1: org.RoleEditor},
+		},
+		{
+			name:             "should remove memberships missing from the desired roles",
+			current:          map[int64]org.RoleType{1: org.RoleViewer, 3: org.RoleEditor, 2: org.RoleAdmin},
+			desired:          map[int64]org.RoleType{1: org.RoleViewer},
+			expectedToAdd:    map[int64]org.RoleType{},
+			expectedToUpdate: map[int64]org.RoleType{},
+			expectedToRemove: []int64{2, 3},
+		},
+		{
+			name:             "should remove memberships mapped to None",
+			current:          map[int64]org.RoleType{1: org.RoleViewer, 2: org.RoleAdmin},
+			desired:          map[int64]org.RoleType{1: org.RoleNone, 2: org.RoleEditor},
+			expectedToAdd:    map[int64]org.RoleType{},
+			expectedToUpdate: map[int64]org.RoleType{2: org.RoleEditor},
+			expectedToRemove: []int64{1},
+		},
+		{
+			name:             "should not add memberships mapped to None",
+			current:          map[int64]org.RoleType{},
+			desired:          map[int64]org.RoleType{1: org.RoleNone, 2: org.RoleEditor},
+			expectedToAdd:    map[int64]org.RoleType{2: org.RoleEditor},
+			expectedToUpdate: map[int64]org.RoleType{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toAdd, toUpdate, toRemove := DiffOrgRoles(tt.current, tt.desired)
+			require.Equal(t, tt.expectedToAdd, toAdd)
+			require.Equal(t, tt.expectedToUpdate, toUpdate)
+			require.Equal(t, tt.expectedToRemove, toRemove)
+		})
+	}
+}
+
+func TestSortedOrgRoles(t *testing.T) {
+	require.Empty(t, SortedOrgRoles(nil))
+
+	roles := map[int64]org.RoleType{3: org.RoleViewer, 1: org.RoleAdmin, 10: org.RoleNone, 2: org.RoleEditor}
+	expected := []OrgRoleEntry{
+		{OrgID: 1, Role: org.RoleAdmin},
+		{OrgID: 2, Role: org.RoleEditor},
+		{OrgID: 3, Role: org.RoleViewer},
+		{OrgID: 10, Role: org.RoleNone},
+	}
+	for i := 0; i < 20; i++ {
+		require.Equal(t, expected, SortedOrgRoles(roles))
+	}
+}
@@ -8,39 +8,80 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net"
 	"net/http"
 	"os"
 	"regexp"
 	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
+	jose "github.com/go-jose/go-jose/v3"
 	"golang.org/x/oauth2"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
+	"github.com/grafana/grafana/pkg/infra/localcache"
 	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/metrics"
 	"github.com/grafana/grafana/pkg/infra/remotecache"
 	"github.com/grafana/grafana/pkg/infra/usagestats"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/services/org"
 	"github.com/grafana/grafana/pkg/services/supportbundles"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+	"github.com/grafana/grafana/pkg/util/errutil"
 )
 
 const (
 	OfflineAccessScope = "offline_access"
+
+	// Actions for empty_userinfo_action, applied when the user info endpoint returns an empty body
+	emptyUserInfoActionError    = "error"
+	emptyUserInfoActionIDToken  = "id_token"
+	emptyUserInfoActionDefaults = "defaults"
+
+	// defaultUserInfoBodyTemplate is the form body of POST user info requests when userinfo_body_template is not set
+	defaultUserInfoBodyTemplate = "access_token={{ .AccessToken }}"
+
+	// roleAttributeStrictHighest is the role_attribute_strict value that picks the most privileged role of a list
+	roleAttributeStrictHighest = "highest"
+	// roleAttributeStrictError is the role_attribute_strict value that rejects a list of several distinct roles
+	roleAttributeStrictError = "error"
+
+	// Behaviors for none_role_behavior, applied when role_attribute_path resolves to the None role
+	noneRoleBehaviorAssign = "assign"
+	noneRoleBehaviorDeny   = "deny"
 )
 
 type SocialService struct {
 	cfg *setting.Cfg
 
-	socialMap     map[string]SocialConnector
+	socialMap map[string]SocialConnector
+	// providersMu guards oAuthProvider, which ReloadProvider updates
+	providersMu   sync.RWMutex
 	oAuthProvider map[string]*OAuthInfo
 	log           log.Logger
+
+	// httpClients are the provider HTTP clients, shared across logins so that connections to the IdP are reused
+	httpClientsMu sync.Mutex
+	httpClients   map[string]cachedHTTPClient
+}
+
+// cachedHTTPClient is a provider HTTP client along with the transport settings it was built from.
+type cachedHTTPClient struct {
+	key    string
+	client *http.Client
 }
 
 type OAuthInfo struct {
@@ -71,6 +112,8 @@ type OAuthInfo struct {
 	AutoLogin               bool              `mapstructure:"auto_login"`
 	Enabled                 bool              `mapstructure:"enabled"`
 	RoleAttributeStrict     bool              `mapstructure:"role_attribute_strict"`
+	RoleAttributeHighest    bool              `mapstructure:"-"`
+	RoleAttributeAmbiguous  bool              `mapstructure:"-"`
 	TlsSkipVerify           bool              `mapstructure:"tls_skip_verify_insecure"`
 	UsePKCE                 bool              `mapstructure:"use_pkce"`
 	UseRefreshToken         bool              `mapstructure:"use_refresh_token"`
@@ -88,6 +131,7 @@ func ProvideService(cfg *setting.Cfg,
 		oAuthProvider: make(map[string]*OAuthInfo),
 		socialMap:     make(map[string]SocialConnector),
 		log:           log.New("login.social"),
+		httpClients:   make(map[string]cachedHTTPClient),
 	}
 
 	usageStats.RegisterMetricsFunc(ss.getUsageStats)
@@ -113,6 +157,7 @@ func ProvideService(cfg *setting.Cfg,
 		conn, err := ss.createOAuthConnector(name, settingsKVs, cfg, features, cache)
 		if err != nil {
 			ss.log.Error("Failed to create OAuth provider", "error", err, "provider", name)
+			continue
 		}
 
 		ss.socialMap[name] = conn
@@ -132,6 +177,19 @@ type BasicUserInfo struct {
 	Role           org.RoleType
 	IsGrafanaAdmin *bool // nil will avoid overriding user's set server admin setting
 	Groups         []string
+
+	// RawRole is the role as matched by role_attribute_path, before it was normalized into Role, for auditing
+	RawRole string
+
+	// Warnings describe the non-fatal failures while resolving the user info, e.g. an ignored secondary source,
+	// they are logged as well
+	Warnings []string
+
+	// Provider is the name of the provider that authenticated the user, set when EnforceUniqueEmail is enabled
+	Provider string
+	// EnforceUniqueEmail signals that the login service must verify the email
+	// is not already linked to a user from a different external provider
+	EnforceUniqueEmail bool
 }
 
 func (b *BasicUserInfo) String() string {
@@ -152,23 +210,85 @@ type SocialConnector interface {
 	Client(ctx context.Context, t *oauth2.Token) *http.Client
 	TokenSource(ctx context.Context, t *oauth2.Token) oauth2.TokenSource
 	SupportBundleContent(*bytes.Buffer) error
+	// RedactedConfig returns the effective settings of the provider, with the secrets masked.
+	RedactedConfig() map[string]any
+
+	// TeamMemberships returns the external group identifiers used for team sync,
+	// or nil when the provider does not configure teams_attribute_path.
+	TeamMemberships(ctx context.Context, client *http.Client, token *oauth2.Token) ([]string, error)
+
+	// PreviewMapping resolves the role the provider would assign for the given user info response, without logging in.
+	PreviewMapping(ctx context.Context, rawJSON []byte) (MappingResult, error)
+
+	// CheckHealth checks that the IdP endpoints of the provider are reachable with the given client.
+	CheckHealth(ctx context.Context, client *http.Client) error
 }
 
 type SocialBase struct {
 	*oauth2.Config
+	providerName            string
 	info                    *OAuthInfo
 	log                     log.Logger
 	allowSignup             bool
 	allowAssignGrafanaAdmin bool
 	allowedDomains          []string
+	blockedDomains          []string
 	allowedGroups           []string
 
 	roleAttributePath   string
 	roleAttributeStrict bool
+	roleStrictHighest   bool
+	roleStrictError     bool
 	autoAssignOrgRole   string
 	skipOrgRoleSync     bool
 	features            featuremgmt.FeatureManager
 	useRefreshToken     bool
+	enforceUniqueEmail  bool
+	requiredACR         string
+	nestedJWTClaim      string
+	xmlClaim            string
+	emptyUserInfoAction string
+	graphMemberOfURL    string
+	groupRoleMapping    []groupRole
+	userInfoCacheTTL    time.Duration
+	userInfoCacheJitter float64
+	userInfoCache       *localcache.CacheService
+	emailAllowedRegex   *regexp.Regexp
+	grafanaAdminPath    string
+	grafanaAdminRoles   []string
+	roleValueMapping    map[string]string
+	roleLevelMapping    []roleLevel
+	grafanaAdminOrgRole org.RoleType
+	allowedIssuers      []string
+	allowedAudiences    []string
+	teamsAttributePath  string
+	nullRoleFallback    org.RoleType
+	noneRoleDeny        bool
+	rolePolicyURL       string
+	rolePolicyTimeout   time.Duration
+	rolePolicyClient    *http.Client
+	idTokenDecryptKey   any
+	useIDTokenClaims    bool
+	maxRoleByDomain     map[string]org.RoleType
+
+	userInfoMaxRetries     int
+	userInfoRetryBaseDelay time.Duration
+	paginationMaxPages     int
+	traceUserInfoCalls     bool
+	userInfoMethod         string
+	userInfoBreaker        *userInfoBreaker
+	userInfoBodyTemplate   *template.Template
+
+	// compiledPaths caches the compiled JMESPath expressions by attribute path
+	compiledPaths  sync.Map
+	jmespathStrict bool
+}
+
+// groupRole maps a group to a role, as configured with group_role_mapping.
+type groupRole struct {
+	group        string
+	role         org.RoleType
+	grafanaAdmin bool
 }
 
 type Error struct {
@@ -184,10 +304,17 @@ const (
 	RoleGrafanaAdmin = "GrafanaAdmin" // For AzureAD for example this value cannot contain spaces
 )
 
+// role sync outcomes reported by the oauth_role_sync_total metric
+const (
+	roleSyncMatched     = "matched"
+	roleSyncEmpty       = "empty"
+	roleSyncInvalidRole = "invalid_role"
+)
+
 var (
 	SocialBaseUrl = "/login/"
 	SocialMap     = make(map[string]SocialConnector)
-	allOauthes    = []string{"github", "gitlab", "google", "generic_oauth", "grafananet", grafanaCom, "azuread", "okta"}
+	allOauthes    = []string{"github", "gitlab", "google", "generic_oauth", "grafananet", grafanaCom, "azuread", "okta", "discord", "stub"}
 )
 
 type Service interface {
@@ -207,21 +334,319 @@ func newSocialBase(name string,
 ) *SocialBase {
 	logger := log.New("oauth." + name)
 
+	userInfoCacheTTL := parseUserInfoCacheTTL(logger, info.Extra["userinfo_cache_ttl"])
+	var userInfoCache *localcache.CacheService
+	if userInfoCacheTTL > 0 {
+		userInfoCache = localcache.New(userInfoCacheTTL, 2*userInfoCacheTTL)
+	}
+
 	return &SocialBase{
 		Config:                  config,
+		providerName:            name,
 		info:                    info,
 		log:                     logger,
 		allowSignup:             info.AllowSignup,
 		allowAssignGrafanaAdmin: info.AllowAssignGrafanaAdmin,
 		allowedDomains:          info.AllowedDomains,
+		blockedDomains:          util.SplitString(info.Extra["blocked_domains"]),
 		allowedGroups:           info.AllowedGroups,
 		roleAttributePath:       info.RoleAttributePath,
 		roleAttributeStrict:     info.RoleAttributeStrict,
+		roleStrictHighest:       info.RoleAttributeHighest,
+		roleStrictError:         info.RoleAttributeAmbiguous,
 		autoAssignOrgRole:       autoAssignOrgRole,
 		skipOrgRoleSync:         skipOrgRoleSync,
 		features:                features,
 		useRefreshToken:         info.UseRefreshToken,
+		enforceUniqueEmail:      mustBool(info.Extra["enforce_unique_email"], false),
+		requiredACR:             info.Extra["required_acr"],
+		nestedJWTClaim:          info.Extra["nested_jwt_claim"],
+		xmlClaim:                info.Extra["xml_claim"],
+		emptyUserInfoAction:     strings.ToLower(info.Extra["empty_userinfo_action"]),
+		graphMemberOfURL:        graphMemberOfURL(info),
+		groupRoleMapping:        parseGroupRoleMapping(logger, info.Extra["group_role_mapping"]),
+		maxRoleByDomain:         parseMaxRoleByDomain(logger, info.Extra["max_role_by_domain"]),
+		userInfoCacheTTL:        userInfoCacheTTL,
+		userInfoCacheJitter:     parseUserInfoCacheJitter(logger, info.Extra["userinfo_cache_jitter"]),
+		userInfoCache:           userInfoCache,
+		grafanaAdminPath:        info.Extra["grafana_admin_attribute_path"],
+		grafanaAdminRoles:       util.SplitString(info.Extra["role_values_grafana_admin"]),
+		grafanaAdminOrgRole:     parseGrafanaAdminOrgRole(logger, info.Extra["role_values_grafana_admin_org_role"]),
+		roleValueMapping:        parseRoleValueMapping(logger, info.Extra["role_value_mapping"]),
+		roleLevelMapping:        parseRoleLevelMapping(logger, info.Extra["role_level_mapping"]),
+		allowedIssuers:          parseAllowedIssuers(info.Extra["allowed_issuers"]),
+		allowedAudiences:        util.SplitString(info.Extra["allowed_audiences"]),
+		teamsAttributePath:      info.Extra["teams_attribute_path"],
+		nullRoleFallback:        nullRoleFallback(info),
+		noneRoleDeny:            parseNoneRoleBehavior(logger, info.Extra["none_role_behavior"]) == noneRoleBehaviorDeny,
+		rolePolicyURL:           info.Extra["role_policy_url"],
+		rolePolicyTimeout:       parseRolePolicyTimeout(logger, info.Extra["role_policy_timeout"]),
+		rolePolicyClient:        newRolePolicyClient(logger, info),
+		useIDTokenClaims:        mustBool(info.Extra["use_id_token_claims"], false),
+		userInfoMaxRetries:      parseUserInfoMaxRetries(logger, info.Extra["userinfo_max_retries"]),
+		userInfoRetryBaseDelay:  parseUserInfoRetryBaseDelay(logger, info.Extra["userinfo_retry_base_delay"]),
+		jmespathStrict:          mustBool(info.Extra["jmespath_strict"], false),
+		paginationMaxPages:      parsePaginationMaxPages(logger, info.Extra["pagination_max_pages"]),
+		traceUserInfoCalls:      mustBool(info.Extra["trace_userinfo"], false),
+		userInfoMethod:          userInfoMethod(info),
+		userInfoBreaker:         newUserInfoBreaker(logger, info),
+	}
+}
+
+// parseRolePolicyTimeout parses role_policy_timeout, defaulting to defaultRolePolicyTimeout.
+func parseRolePolicyTimeout(logger log.Logger, value string) time.Duration {
+	if value == "" {
+		return defaultRolePolicyTimeout
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		logger.Warn("Invalid role_policy_timeout, using the default", "value", value, "default", defaultRolePolicyTimeout)
+		return defaultRolePolicyTimeout
+	}
+	return timeout
+}
+
+// newRolePolicyClient returns the HTTP client for role_policy_url, with the TLS and transport settings of the
+// provider. It returns nil when no policy service is set or the settings are invalid, failing the role policy
+// requests rather than sending them without the configured TLS settings.
+func newRolePolicyClient(logger log.Logger, info *OAuthInfo) *http.Client {
+	if info.Extra["role_policy_url"] == "" {
+		return nil
+	}
+
+	client, err := newHTTPClient(info)
+	if err != nil {
+		logger.Error("Failed to setup the role policy HTTP client", "error", err)
+		return nil
+	}
+	return client
+}
+
+// parseNoneRoleBehavior parses none_role_behavior, defaulting to assign.
+func parseNoneRoleBehavior(logger log.Logger, value string) string {
+	switch behavior := strings.ToLower(strings.TrimSpace(value)); behavior {
+	case "":
+		return noneRoleBehaviorAssign
+	case noneRoleBehaviorAssign, noneRoleBehaviorDeny:
+		return behavior
+	default:
+		logger.Warn("Invalid none_role_behavior, using the default", "value", value, "default", noneRoleBehaviorAssign)
+		return noneRoleBehaviorAssign
+	}
+}
+
+// parseGrafanaAdminOrgRole parses role_values_grafana_admin_org_role, the org role of the users matching
+// role_values_grafana_admin, defaulting to Admin.
+func parseGrafanaAdminOrgRole(logger log.Logger, value string) org.RoleType {
+	if value == "" {
+		return org.RoleAdmin
+	}
+
+	role := org.RoleType(cases.Title(language.Und).String(value))
+	if !role.IsValid() {
+		logger.Warn("Invalid role_values_grafana_admin_org_role, using the default", "value", value, "default", org.RoleAdmin)
+		return org.RoleAdmin
+	}
+	return role
+}
+
+// parseRoleValueMapping parses role_value_mapping, pairs of raw roles and the roles they map to, such as
+// "lead:Admin member:Editor guest:Viewer". Raw roles are matched case insensitively. Invalid pairs are skipped.
+func parseRoleValueMapping(logger log.Logger, mapping string) map[string]string {
+	var result map[string]string
+	for _, pair := range util.SplitString(mapping) {
+		rawRole, roleName, found := strings.Cut(pair, ":")
+		if !found || rawRole == "" {
+			logger.Warn("Skipping invalid role_value_mapping entry", "entry", pair)
+			continue
+		}
+
+		if role, _ := getRoleFromSearch(roleName); !role.IsValid() {
+			logger.Warn("Skipping role_value_mapping entry with invalid role", "entry", pair)
+			continue
+		}
+
+		if result == nil {
+			result = map[string]string{}
+		}
+		result[strings.ToLower(rawRole)] = roleName
+	}
+	return result
+}
+
+// roleLevel is a role_level_mapping entry, the role of the numeric role values from level up to the next level.
+type roleLevel struct {
+	level float64
+	role  string
+}
+
+// parseRoleLevelMapping parses role_level_mapping, pairs of numeric levels and the roles they map to, such as
+// "1:Viewer 2:Editor 3:Admin". The levels are returned in ascending order. Invalid pairs are skipped.
+func parseRoleLevelMapping(logger log.Logger, mapping string) []roleLevel {
+	var result []roleLevel
+	for _, pair := range util.SplitString(mapping) {
+		levelValue, roleName, found := strings.Cut(pair, ":")
+		level, err := strconv.ParseFloat(levelValue, 64)
+		if !found || err != nil {
+			logger.Warn("Skipping invalid role_level_mapping entry", "entry", pair)
+			continue
+		}
+
+		if role, _ := getRoleFromSearch(roleName); !role.IsValid() {
+			logger.Warn("Skipping role_level_mapping entry with invalid role", "entry", pair)
+			continue
+		}
+
+		result = append(result, roleLevel{level: level, role: roleName})
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].level < result[j].level })
+	return result
+}
+
+// roleFromLevel returns the role of the highest role_level_mapping level not above level, or an empty string
+// when level is below all of them.
+func (s *SocialBase) roleFromLevel(level float64) string {
+	var role string
+	for _, entry := range s.roleLevelMapping {
+		if entry.level > level {
+			break
+		}
+		role = entry.role
+	}
+	return role
+}
+
+// parsePaginationMaxPages parses pagination_max_pages, defaulting to defaultPaginationMaxPages.
+func parsePaginationMaxPages(logger log.Logger, value string) int {
+	if value == "" {
+		return defaultPaginationMaxPages
+	}
+
+	maxPages, err := strconv.Atoi(value)
+	if err != nil || maxPages <= 0 {
+		logger.Warn("Invalid pagination_max_pages, using the default", "value", value, "default", defaultPaginationMaxPages)
+		return defaultPaginationMaxPages
+	}
+	return maxPages
+}
+
+// parseUserInfoMaxRetries parses userinfo_max_retries. User info requests are not retried when it is unset or invalid.
+func parseUserInfoMaxRetries(logger log.Logger, value string) int {
+	if value == "" {
+		return 0
+	}
+
+	retries, err := strconv.Atoi(value)
+	if err != nil || retries < 0 {
+		logger.Warn("Invalid userinfo_max_retries, user info requests will not be retried", "value", value)
+		return 0
+	}
+	return retries
+}
+
+// parseUserInfoRetryBaseDelay parses userinfo_retry_base_delay, defaulting to defaultUserInfoRetryBaseDelay.
+func parseUserInfoRetryBaseDelay(logger log.Logger, value string) time.Duration {
+	if value == "" {
+		return defaultUserInfoRetryBaseDelay
+	}
+
+	delay, err := time.ParseDuration(value)
+	if err != nil || delay <= 0 {
+		logger.Warn("Invalid userinfo_retry_base_delay, using the default", "value", value, "default", defaultUserInfoRetryBaseDelay)
+		return defaultUserInfoRetryBaseDelay
+	}
+	return delay
+}
+
+// parseUserInfoCacheTTL parses userinfo_cache_ttl. The cache is disabled when it is unset or invalid.
+func parseUserInfoCacheTTL(logger log.Logger, value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		logger.Warn("Invalid userinfo_cache_ttl, user info responses will not be cached", "value", value)
+		return 0
+	}
+	return ttl
+}
+
+// parseUserInfoCacheJitter parses userinfo_cache_jitter, the fraction of userinfo_cache_ttl by which the expiration
+// of cached responses is randomly spread. 0 disables the jitter.
+func parseUserInfoCacheJitter(logger log.Logger, value string) float64 {
+	if value == "" {
+		return defaultUserInfoCacheJitter
+	}
+
+	jitter, err := strconv.ParseFloat(value, 64)
+	if err != nil || jitter < 0 || jitter >= 1 {
+		logger.Warn("Invalid userinfo_cache_jitter, using the default", "value", value, "default", defaultUserInfoCacheJitter)
+		return defaultUserInfoCacheJitter
+	}
+	return jitter
+}
+
+// parseGroupRoleMapping parses an ordered list of group=role pairs, such as
+// "admins=Admin, editors=Editor, everyone=Viewer". Invalid pairs are skipped.
+func parseGroupRoleMapping(logger log.Logger, mapping string) []groupRole {
+	var result []groupRole
+	for _, pair := range util.SplitString(mapping) {
+		group, roleName, found := strings.Cut(pair, "=")
+		group = strings.TrimSpace(group)
+		if !found || group == "" {
+			logger.Warn("Skipping invalid group_role_mapping entry", "entry", pair)
+			continue
+		}
+
+		role, grafanaAdmin := getRoleFromSearch(strings.TrimSpace(roleName))
+		if !role.IsValid() {
+			logger.Warn("Skipping group_role_mapping entry with invalid role", "entry", pair)
+			continue
+		}
+
+		result = append(result, groupRole{group: group, role: role, grafanaAdmin: grafanaAdmin})
+	}
+	return result
+}
+
+// parseMaxRoleByDomain parses max_role_by_domain, a list of domain=role pairs, into the role cap by lowercased domain.
+func parseMaxRoleByDomain(logger log.Logger, mapping string) map[string]org.RoleType {
+	var result map[string]org.RoleType
+	for _, pair := range util.SplitString(mapping) {
+		domain, roleName, found := strings.Cut(pair, "=")
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if !found || domain == "" {
+			logger.Warn("Skipping invalid max_role_by_domain entry", "entry", pair)
+			continue
+		}
+
+		role := org.RoleType(cases.Title(language.Und).String(strings.TrimSpace(roleName)))
+		if !role.IsValid() {
+			logger.Warn("Skipping max_role_by_domain entry with invalid role", "entry", pair)
+			continue
+		}
+
+		if result == nil {
+			result = map[string]org.RoleType{}
+		}
+		result[domain] = role
+	}
+	return result
+}
+
+// graphMemberOfURL returns the Microsoft Graph memberOf endpoint to fetch groups from,
+// or an empty string when use_graph_member_of is not set.
+func graphMemberOfURL(info *OAuthInfo) string {
+	if !mustBool(info.Extra["use_graph_member_of"], false) {
+		return ""
+	}
+
+	if url := info.Extra["graph_member_of_url"]; url != "" {
+		return url
 	}
+	return defaultGraphMemberOfURL
 }
 
 type groupStruct struct {
@@ -234,10 +659,49 @@ func (s *SocialBase) SupportBundleContent(bf *bytes.Buffer) error {
 	bf.WriteString(fmt.Sprintf("allow_assign_grafana_admin = %v\n", s.allowAssignGrafanaAdmin))
 	bf.WriteString(fmt.Sprintf("allow_sign_up = %v\n", s.allowSignup))
 	bf.WriteString(fmt.Sprintf("allowed_domains = %v\n", s.allowedDomains))
+	bf.WriteString(fmt.Sprintf("blocked_domains = %v\n", s.blockedDomains))
 	bf.WriteString(fmt.Sprintf("auto_assign_org_role = %v\n", s.autoAssignOrgRole))
 	bf.WriteString(fmt.Sprintf("role_attribute_path = %v\n", s.roleAttributePath))
 	bf.WriteString(fmt.Sprintf("role_attribute_strict = %v\n", s.roleAttributeStrict))
+	bf.WriteString(fmt.Sprintf("role_attribute_strict_highest = %v\n", s.roleStrictHighest))
+	bf.WriteString(fmt.Sprintf("role_attribute_strict_error = %v\n", s.roleStrictError))
+	bf.WriteString(fmt.Sprintf("null_role_fallback = %v\n", s.nullRoleFallback))
+	bf.WriteString(fmt.Sprintf("role_policy_url = %v\n", s.rolePolicyURL))
+	bf.WriteString(fmt.Sprintf("role_policy_timeout = %v\n", s.rolePolicyTimeout))
+	bf.WriteString(fmt.Sprintf("grafana_admin_attribute_path = %v\n", s.grafanaAdminPath))
+	bf.WriteString(fmt.Sprintf("role_values_grafana_admin = %v\n", s.grafanaAdminRoles))
+	bf.WriteString(fmt.Sprintf("role_values_grafana_admin_org_role = %v\n", s.grafanaAdminOrgRole))
+	bf.WriteString(fmt.Sprintf("role_value_mapping = %v\n", s.info.Extra["role_value_mapping"]))
+	bf.WriteString(fmt.Sprintf("none_role_behavior_deny = %v\n", s.noneRoleDeny))
+	bf.WriteString(fmt.Sprintf("role_level_mapping = %v\n", s.info.Extra["role_level_mapping"]))
+	bf.WriteString(fmt.Sprintf("teams_attribute_path = %v\n", s.teamsAttributePath))
 	bf.WriteString(fmt.Sprintf("skip_org_role_sync = %v\n", s.skipOrgRoleSync))
+	bf.WriteString(fmt.Sprintf("enforce_unique_email = %v\n", s.enforceUniqueEmail))
+	bf.WriteString(fmt.Sprintf("required_acr = %v\n", s.requiredACR))
+	bf.WriteString(fmt.Sprintf("allowed_issuers = %v\n", s.allowedIssuers))
+	bf.WriteString(fmt.Sprintf("allowed_audiences = %v\n", s.allowedAudiences))
+	bf.WriteString(fmt.Sprintf("id_token_decrypt_key = %v\n", s.info.Extra["id_token_decrypt_key"]))
+	bf.WriteString(fmt.Sprintf("nested_jwt_claim = %v\n", s.nestedJWTClaim))
+	bf.WriteString(fmt.Sprintf("use_id_token_claims = %v\n", s.useIDTokenClaims))
+	bf.WriteString(fmt.Sprintf("xml_claim = %v\n", s.xmlClaim))
+	bf.WriteString(fmt.Sprintf("jmespath_strict = %v\n", s.jmespathStrict))
+	bf.WriteString(fmt.Sprintf("empty_userinfo_action = %v\n", s.emptyUserInfoAction))
+	bf.WriteString(fmt.Sprintf("graph_member_of_url = %v\n", s.graphMemberOfURL))
+	bf.WriteString(fmt.Sprintf("group_role_mapping = %v\n", s.info.Extra["group_role_mapping"]))
+	bf.WriteString(fmt.Sprintf("max_role_by_domain = %v\n", s.info.Extra["max_role_by_domain"]))
+	bf.WriteString(fmt.Sprintf("userinfo_cache_ttl = %v\n", s.userInfoCacheTTL))
+	bf.WriteString(fmt.Sprintf("correlation_header = %v\n", s.info.Extra["correlation_header"]))
+	bf.WriteString(fmt.Sprintf("userinfo_cache_jitter = %v\n", s.userInfoCacheJitter))
+	bf.WriteString(fmt.Sprintf("userinfo_max_retries = %v\n", s.userInfoMaxRetries))
+	bf.WriteString(fmt.Sprintf("userinfo_retry_base_delay = %v\n", s.userInfoRetryBaseDelay))
+	bf.WriteString(fmt.Sprintf("pagination_max_pages = %v\n", s.paginationMaxPages))
+	bf.WriteString(fmt.Sprintf("trace_userinfo = %v\n", s.traceUserInfoCalls))
+	bf.WriteString(fmt.Sprintf("userinfo_method = %v\n", s.userInfoMethod))
+	bf.WriteString(fmt.Sprintf("userinfo_breaker_failures = %v\n", s.info.Extra["userinfo_breaker_failures"]))
+	bf.WriteString(fmt.Sprintf("userinfo_breaker_window = %v\n", s.info.Extra["userinfo_breaker_window"]))
+	bf.WriteString(fmt.Sprintf("userinfo_breaker_cooldown = %v\n", s.info.Extra["userinfo_breaker_cooldown"]))
+	bf.WriteString(fmt.Sprintf("userinfo_body_template = %v\n", s.info.Extra["userinfo_body_template"]))
+	bf.WriteString(fmt.Sprintf("email_allowed_regex = %v\n", s.info.Extra["email_allowed_regex"]))
 	bf.WriteString(fmt.Sprintf("client_id = %v\n", s.Config.ClientID))
 	bf.WriteString(fmt.Sprintf("client_secret = %v ; issue if empty\n", strings.Repeat("*", len(s.Config.ClientSecret))))
 	bf.WriteString(fmt.Sprintf("auth_url = %v\n", s.Config.Endpoint.AuthURL))
@@ -250,24 +714,100 @@ func (s *SocialBase) SupportBundleContent(bf *bytes.Buffer) error {
 }
 
 func (s *SocialBase) extractRoleAndAdminOptional(rawJSON []byte, groups []string) (org.RoleType, bool, error) {
+	role, gAdmin, err := s.extractRoleAndMagicAdminOptional(rawJSON, groups)
+	if err != nil {
+		return "", false, err
+	}
+
+	if s.grafanaAdminPath != "" {
+		gAdmin = s.searchGrafanaAdmin(rawJSON)
+	}
+
+	return role, gAdmin, nil
+}
+
+// searchGrafanaAdmin evaluates grafana_admin_attribute_path, which must resolve to a boolean.
+func (s *SocialBase) searchGrafanaAdmin(rawJSON []byte) bool {
+	val, err := s.searchJSONForAttr(s.grafanaAdminPath, rawJSON)
+	if err != nil {
+		s.log.Debug("Failed to search grafana_admin_attribute_path", "err", err)
+		return false
+	}
+
+	switch v := val.(type) {
+	case bool:
+		return v
+	case string:
+		grafanaAdmin, err := strconv.ParseBool(v)
+		return err == nil && grafanaAdmin
+	default:
+		return false
+	}
+}
+
+// extractRoleAndMagicAdminOptional extracts the role, flagging the user as Grafana admin when the role is GrafanaAdmin.
+func (s *SocialBase) extractRoleAndMagicAdminOptional(rawJSON []byte, groups []string) (org.RoleType, bool, error) {
+	role, gAdmin, outcome, err := s.evaluateRole(rawJSON, groups)
+	if outcome != "" {
+		s.recordRoleSync(outcome)
+	}
+	return role, gAdmin, err
+}
+
+// evaluateRole resolves the role from group_role_mapping or role_attribute_path without side effects. It also
+// returns the role sync outcome when role_attribute_path was evaluated.
+func (s *SocialBase) evaluateRole(rawJSON []byte, groups []string) (org.RoleType, bool, string, error) {
+	if role, gAdmin, ok := s.roleFromGroupMapping(groups); ok {
+		return role, gAdmin, "", nil
+	}
+
 	if s.roleAttributePath == "" {
 		if s.roleAttributeStrict {
-			return "", false, errRoleAttributePathNotSet.Errorf("role_attribute_path not set and role_attribute_strict is set")
+			return "", false, "", errRoleAttributePathNotSet.Errorf("role_attribute_path not set and role_attribute_strict is set")
 		}
-		return "", false, nil
+		return "", false, "", nil
+	}
+
+	rawJSON, err := s.mergeNestedJWTClaims(rawJSON)
+	if err != nil {
+		return "", false, "", err
+	}
+
+	rawJSON, err = s.expandXMLClaim(rawJSON)
+	if err != nil {
+		return "", false, "", err
+	}
+
+	role, rawRole, gAdmin, err := s.searchRole(rawJSON, groups)
+	if err != nil {
+		return "", false, "", err
+	}
+
+	if role == org.RoleNone && s.noneRoleDeny {
+		return "", false, roleSyncMatched, ErrNoneRoleDenied.Errorf("role_attribute_path resolved to the None role (matched %q) and none_role_behavior is deny", rawRole)
 	}
 
-	if role, gAdmin := s.searchRole(rawJSON, groups); role.IsValid() {
-		return role, gAdmin, nil
+	if role.IsValid() {
+		return role, gAdmin, roleSyncMatched, nil
 	} else if role != "" {
-		return "", false, errInvalidRole.Errorf("invalid role: %s", role)
+		return "", false, roleSyncInvalidRole, ErrInvalidRole.Errorf("invalid role: %s (matched %q)", role, rawRole)
 	}
 
 	if s.roleAttributeStrict {
-		return "", false, errRoleAttributeStrictViolation.Errorf("idP did not return a role attribute, but role_attribute_strict is set")
+		return "", false, roleSyncEmpty, errRoleAttributeStrictViolation.Errorf("idP did not return a role attribute, but role_attribute_strict is set")
+	}
+
+	if s.nullRoleFallback != "" {
+		s.log.Debug("No role found, returning null_role_fallback", "role", s.nullRoleFallback)
+		return s.nullRoleFallback, false, roleSyncEmpty, nil
 	}
 
-	return "", false, nil
+	return "", false, roleSyncEmpty, nil
+}
+
+// recordRoleSync counts the outcome of evaluating role_attribute_path for the provider.
+func (s *SocialBase) recordRoleSync(outcome string) {
+	metrics.MOAuthRoleSync.WithLabelValues(s.providerName, outcome).Inc()
 }
 
 func (s *SocialBase) extractRoleAndAdmin(rawJSON []byte, groups []string) (org.RoleType, bool, error) {
@@ -279,135 +819,1036 @@ func (s *SocialBase) extractRoleAndAdmin(rawJSON []byte, groups []string) (org.R
 	return role, gAdmin, err
 }
 
-func (s *SocialBase) searchRole(rawJSON []byte, groups []string) (org.RoleType, bool) {
-	role, err := s.searchJSONForStringAttr(s.roleAttributePath, rawJSON)
-	if err == nil && role != "" {
-		return getRoleFromSearch(role)
+// MappingResult is the role mapping a provider resolves for a user info response.
+type MappingResult struct {
+	Role           org.RoleType
+	IsGrafanaAdmin bool
+	Groups         []string
+	// Warnings explain where the result differs from what the attribute paths alone would produce,
+	// e.g. when the default role is used or role sync is skipped.
+	Warnings []string
+}
+
+// PreviewMapping runs the group, role and Grafana admin mapping against rawJSON the same way a login would,
+// so that admins can check their configuration. It doesn't record role sync metrics.
+func (s *SocialBase) PreviewMapping(ctx context.Context, rawJSON []byte) (MappingResult, error) {
+	var result MappingResult
+
+	if s.info.GroupsAttributePath != "" {
+		groups, err := s.searchJSONForStringArrayAttr(s.info.GroupsAttributePath, rawJSON)
+		if errors.Is(err, ErrAttributePath) {
+			return MappingResult{}, err
+		}
+		result.Groups = groups
 	}
 
-	if groupBytes, err := json.Marshal(groupStruct{groups}); err == nil {
-		role, err := s.searchJSONForStringAttr(s.roleAttributePath, groupBytes)
-		if err == nil && role != "" {
-			return getRoleFromSearch(role)
+	var role org.RoleType
+	var gAdmin bool
+	var outcome string
+	var err error
+	if s.rolePolicyURL != "" {
+		role, gAdmin, err = s.searchRolePolicy(ctx, rawJSON, result.Groups)
+	} else {
+		role, gAdmin, outcome, err = s.evaluateRole(rawJSON, result.Groups)
+		if err == nil && s.grafanaAdminPath != "" {
+			gAdmin = s.searchGrafanaAdmin(rawJSON)
 		}
 	}
+	if err != nil {
+		return MappingResult{}, err
+	}
 
-	return "", false
-}
+	switch {
+	case role == "":
+		role = s.defaultRole()
+		result.Warnings = append(result.Warnings, fmt.Sprintf("no role found, the default role %q is used", role))
+	case outcome == roleSyncEmpty:
+		result.Warnings = append(result.Warnings, fmt.Sprintf("no role found, null_role_fallback %q is used", role))
+	}
+	result.Role = role
 
-// defaultRole returns the default role for the user based on the autoAssignOrgRole setting
-// if legacy is enabled "" is returned indicating the previous role assignment is used.
-func (s *SocialBase) defaultRole() org.RoleType {
-	if s.autoAssignOrgRole != "" {
-		s.log.Debug("No role found, returning default.")
-		return org.RoleType(s.autoAssignOrgRole)
+	if gAdmin && !s.allowAssignGrafanaAdmin {
+		result.Warnings = append(result.Warnings, "allow_assign_grafana_admin is not set, the Grafana admin flag is not synced")
+		gAdmin = false
 	}
+	result.IsGrafanaAdmin = gAdmin
 
-	// should never happen
-	return org.RoleViewer
+	if s.skipOrgRoleSync {
+		result.Warnings = append(result.Warnings, "skip_org_role_sync is set, the role is not synced at login")
+	}
+
+	return result, nil
 }
 
-// match grafana admin role and translate to org role and bool.
-// treat the JSON search result to ensure correct casing.
-func getRoleFromSearch(role string) (org.RoleType, bool) {
-	if strings.EqualFold(role, RoleGrafanaAdmin) {
-		return org.RoleAdmin, true
+// RefreshUserInfo resolves the user info of a refreshed token with the connector, so that the role and Grafana admin
+// flag of long-lived sessions follow the IdP. It runs UserInfo, so the same checks and role mapping apply as on login
+// and the role is left empty when skip_org_role_sync is set. Cached user info responses are not reused, since the
+// cache is keyed by the access token.
+func RefreshUserInfo(ctx context.Context, connector SocialConnector, client *http.Client, refreshedToken *oauth2.Token) (*BasicUserInfo, error) {
+	if refreshedToken == nil || !refreshedToken.Valid() {
+		return nil, errInvalidRefreshedToken.Errorf("refreshed token is missing or expired")
 	}
 
-	return org.RoleType(cases.Title(language.Und).String(role)), false
+	return connector.UserInfo(ctx, client, refreshedToken)
 }
 
-// GetOAuthProviders returns available oauth providers and if they're enabled or not
-func (ss *SocialService) GetOAuthProviders() map[string]bool {
-	result := map[string]bool{}
+// LoginHint returns the option adding the login_hint parameter to the auth URL, to suggest the account to sign in
+// with, e.g. the email of the user signing in again. Providers ignoring the parameter show their usual login page.
+func LoginHint(hint string) oauth2.AuthCodeOption {
+	return oauth2.SetAuthURLParam("login_hint", hint)
+}
 
-	if ss.cfg == nil || ss.cfg.Raw == nil {
-		return result
-	}
+type rolePolicyRequest struct {
+	Provider string          `json:"provider"`
+	Claims   json.RawMessage `json:"claims"`
+	Groups   []string        `json:"groups"`
+}
 
-	for _, name := range allOauthes {
-		if name == "grafananet" {
-			name = grafanaCom
-		}
+type rolePolicyResponse struct {
+	Role         string `json:"role"`
+	GrafanaAdmin bool   `json:"grafana_admin"`
+}
 
-		sec := ss.cfg.Raw.Section("auth." + name)
-		if sec == nil {
-			continue
-		}
-		result[name] = sec.Key("enabled").MustBool()
+// searchRolePolicy posts the claims and groups to role_policy_url and returns the role assigned by the
+// policy service in place of the local role mapping. An empty role means the policy didn't assign one.
+func (s *SocialBase) searchRolePolicy(ctx context.Context, rawJSON []byte, groups []string) (org.RoleType, bool, error) {
+	if len(rawJSON) == 0 {
+		rawJSON = []byte("{}")
 	}
 
-	return result
-}
-
-func (ss *SocialService) GetOAuthHttpClient(name string) (*http.Client, error) {
-	// The socialMap keys don't have "oauth_" prefix, but everywhere else in the system does
-	name = strings.TrimPrefix(name, "oauth_")
-	info, ok := ss.oAuthProvider[name]
-	if !ok {
-		return nil, fmt.Errorf("could not find %q in OAuth Settings", name)
+	body, err := json.Marshal(rolePolicyRequest{Provider: s.providerName, Claims: rawJSON, Groups: groups})
+	if err != nil {
+		return "", false, errRolePolicy.Errorf("failed to encode role policy request: %w", err)
 	}
 
-	// handle call back
-	tr := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: info.TlsSkipVerify,
-		},
-		DialContext: (&net.Dialer{
-			Timeout:   time.Second * 10,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		TLSHandshakeTimeout:   15 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
+	if s.rolePolicyClient == nil {
+		return "", false, errRolePolicy.Errorf("no HTTP client for the role policy service")
 	}
 
-	oauthClient := &http.Client{
-		Transport: tr,
-		Timeout:   time.Second * 15,
+	// the request ends at the deadline of the login when it comes before role_policy_timeout
+	ctx, cancel := context.WithTimeout(ctx, s.rolePolicyTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.rolePolicyURL, bytes.NewReader(body))
+	if err != nil {
+		return "", false, errRolePolicy.Errorf("failed to create role policy request: %w", err)
 	}
+	req.Header.Set("Content-Type", "application/json")
 
-	if info.TlsClientCert != "" || info.TlsClientKey != "" {
-		cert, err := tls.LoadX509KeyPair(info.TlsClientCert, info.TlsClientKey)
-		if err != nil {
-			ss.log.Error("Failed to setup TlsClientCert", "oauth", name, "error", err)
-			return nil, fmt.Errorf("failed to setup TlsClientCert: %w", err)
+	resp, err := s.rolePolicyClient.Do(req)
+	if err != nil {
+		return "", false, errRolePolicy.Errorf("role policy request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			s.log.Warn("Failed to close role policy response body", "err", err)
 		}
+	}()
 
-		tr.TLSClientConfig.Certificates = append(tr.TLSClientConfig.Certificates, cert)
+	if resp.StatusCode != http.StatusOK {
+		return "", false, errRolePolicy.Errorf("role policy service returned status code %d", resp.StatusCode)
 	}
 
-	if info.TlsClientCa != "" {
-		caCert, err := os.ReadFile(info.TlsClientCa)
-		if err != nil {
-			ss.log.Error("Failed to setup TlsClientCa", "oauth", name, "error", err)
-			return nil, fmt.Errorf("failed to setup TlsClientCa: %w", err)
-		}
-		caCertPool := x509.NewCertPool()
-		caCertPool.AppendCertsFromPEM(caCert)
-		tr.TLSClientConfig.RootCAs = caCertPool
+	var result rolePolicyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", false, errRolePolicy.Errorf("failed to decode role policy response: %w", err)
 	}
-	return oauthClient, nil
-}
 
-func (ss *SocialService) GetConnector(name string) (SocialConnector, error) {
-	// The socialMap keys don't have "oauth_" prefix, but everywhere else in the system does
-	provider := strings.TrimPrefix(name, "oauth_")
-	connector, ok := ss.socialMap[provider]
-	if !ok {
-		return nil, fmt.Errorf("failed to find oauth provider for %q", name)
+	if result.Role == "" {
+		return "", false, nil
 	}
-	return connector, nil
-}
 
-func (ss *SocialService) GetOAuthInfoProvider(name string) *OAuthInfo {
-	return ss.oAuthProvider[name]
+	role, gAdmin := getRoleFromSearch(result.Role)
+	if !role.IsValid() {
+		return "", false, ErrInvalidRole.Errorf("role policy returned invalid role: %s", result.Role)
+	}
+
+	return role, gAdmin || result.GrafanaAdmin, nil
 }
 
-func (ss *SocialService) GetOAuthInfoProviders() map[string]*OAuthInfo {
-	return ss.oAuthProvider
+// searchRole evaluates the role attribute paths in order and returns the first valid role, along with
+// the string the path matched before it was normalized. Paths that don't match are skipped. If no path
+// yields a valid role, the first invalid role matched is returned.
+func (s *SocialBase) searchRole(rawJSON []byte, groups []string) (org.RoleType, string, bool, error) {
+	var invalidRole org.RoleType
+	var invalidRawRole string
+	for _, path := range splitAttributePaths(s.roleAttributePath) {
+		role, rawRole, gAdmin, err := s.searchRoleAttributePath(path, rawJSON, groups)
+		if err != nil {
+			return "", "", false, err
+		}
+		if role.IsValid() {
+			return role, rawRole, gAdmin, nil
+		}
+		if role != "" && invalidRole == "" {
+			invalidRole, invalidRawRole = role, rawRole
+		}
+	}
+
+	return invalidRole, invalidRawRole, false, nil
+}
+
+func (s *SocialBase) searchRoleAttributePath(path string, rawJSON []byte, groups []string) (org.RoleType, string, bool, error) {
+	rawRole, err := s.searchRoleAttr(path, rawJSON)
+	if err != nil {
+		return "", "", false, err
+	}
+	if rawRole != "" {
+		role, gAdmin := s.roleFromRawRole(rawRole)
+		return role, rawRole, gAdmin, nil
+	}
+
+	if groupBytes, err := json.Marshal(groupStruct{groups}); err == nil {
+		rawRole, err := s.searchRoleAttr(path, groupBytes)
+		if err != nil {
+			return "", "", false, err
+		}
+		if rawRole != "" {
+			role, gAdmin := s.roleFromRawRole(rawRole)
+			return role, rawRole, gAdmin, nil
+		}
+	}
+
+	return "", "", false, nil
+}
+
+// searchRawRole returns the string role_attribute_path matched before it was normalized into a role, or an
+// empty string when the role doesn't come from role_attribute_path.
+func (s *SocialBase) searchRawRole(rawJSON []byte, groups []string) string {
+	if s.roleAttributePath == "" {
+		return ""
+	}
+
+	if _, _, ok := s.roleFromGroupMapping(groups); ok {
+		return ""
+	}
+
+	rawJSON, err := s.mergeNestedJWTClaims(rawJSON)
+	if err != nil {
+		return ""
+	}
+
+	rawJSON, err = s.expandXMLClaim(rawJSON)
+	if err != nil {
+		return ""
+	}
+
+	_, rawRole, _, err := s.searchRole(rawJSON, groups)
+	if err != nil {
+		return ""
+	}
+
+	return rawRole
+}
+
+// searchRoleAttr returns the role found at path. Missing or undecodable data is treated as no role, but a path
+// that can't be evaluated or resolves to an object is reported as a configuration error. A list is resolved with
+// role_attribute_strict = highest or error. When neither is set, a list is a configuration error too instead of its
+// first role being picked, as such a path is usually misused to map the roles of several orgs.
+func (s *SocialBase) searchRoleAttr(path string, data []byte) (string, error) {
+	val, err := s.searchJSONForAttr(path, data)
+	if errors.Is(err, ErrAttributePath) {
+		return "", err
+	}
+	if err != nil {
+		return "", nil
+	}
+
+	switch v := val.(type) {
+	case string:
+		return v, nil
+	case float64:
+		// numeric role values are only mapped with role_level_mapping
+		if len(s.roleLevelMapping) == 0 {
+			return "", nil
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []any:
+		if s.roleStrictHighest {
+			return s.highestRole(path, v)
+		}
+		if s.roleStrictError {
+			return s.singleRole(path, v)
+		}
+		return "", errRoleAttributePathNotScalar.Errorf("role_attribute_path %q resolved to %T instead of a single role name, "+
+			"it can't be used to map roles for several orgs", path, v)
+	case map[string]any:
+		return "", errRoleAttributePathNotScalar.Errorf("role_attribute_path %q resolved to %T instead of a single role name, "+
+			"it can't be used to map roles for several orgs", path, v)
+	default:
+		return "", nil
+	}
+}
+
+// singleRole returns the valid role of roles, for role_attribute_strict = error. Roles listed several times count
+// once and invalid entries are ignored, but several distinct valid roles are rejected with ErrAmbiguousRole.
+func (s *SocialBase) singleRole(path string, roles []any) (string, error) {
+	var candidates []string
+	var candidateRoles []org.RoleType
+	var candidateAdmins []bool
+	for _, value := range roles {
+		rawRole, ok := value.(string)
+		if !ok {
+			continue
+		}
+		role, gAdmin := s.roleFromRawRole(rawRole)
+		if !role.IsValid() {
+			continue
+		}
+
+		duplicate := false
+		for i := range candidates {
+			if candidateRoles[i] == role && candidateAdmins[i] == gAdmin {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			candidates = append(candidates, rawRole)
+			candidateRoles = append(candidateRoles, role)
+			candidateAdmins = append(candidateAdmins, gAdmin)
+		}
+	}
+
+	switch {
+	case len(candidates) == 0 && len(roles) > 0:
+		return "", ErrInvalidRole.Errorf("role_attribute_path %q resolved to %v without any valid role", path, roles)
+	case len(candidates) > 1:
+		return "", ErrAmbiguousRole.Errorf("role_attribute_path %q resolved to the distinct roles %q, "+
+			"set role_attribute_strict = highest or narrow down the path", path, candidates)
+	case len(candidates) == 1:
+		return candidates[0], nil
+	default:
+		return "", nil
+	}
+}
+
+// highestRole returns the most privileged valid role of roles, for role_attribute_strict = highest. Invalid entries
+// are ignored, unless none of the entries is a valid role.
+func (s *SocialBase) highestRole(path string, roles []any) (string, error) {
+	var highest string
+	var highestRole org.RoleType
+	var highestAdmin bool
+	for _, value := range roles {
+		rawRole, ok := value.(string)
+		if !ok {
+			continue
+		}
+		role, gAdmin := s.roleFromRawRole(rawRole)
+		if !role.IsValid() {
+			continue
+		}
+		if highest == "" || !highestRole.Includes(role) || role == highestRole && gAdmin && !highestAdmin {
+			highest, highestRole, highestAdmin = rawRole, role, gAdmin
+		}
+	}
+
+	if highest == "" && len(roles) > 0 {
+		return "", ErrInvalidRole.Errorf("role_attribute_path %q resolved to %v without any valid role", path, roles)
+	}
+	return highest, nil
+}
+
+// roleFromGroupMapping returns the role of the first group_role_mapping entry, in configured
+// order, whose group the user is a member of.
+func (s *SocialBase) roleFromGroupMapping(groups []string) (org.RoleType, bool, bool) {
+	for _, mapping := range s.groupRoleMapping {
+		if slices.Contains(groups, mapping.group) {
+			return mapping.role, mapping.grafanaAdmin, true
+		}
+	}
+	return "", false, false
+}
+
+// setProviderIdentity flags the user info for the downstream unique email check
+// when enforce_unique_email is set for the provider.
+func (s *SocialBase) setProviderIdentity(userInfo *BasicUserInfo) {
+	if !s.enforceUniqueEmail {
+		return
+	}
+
+	userInfo.Provider = s.providerName
+	userInfo.EnforceUniqueEmail = true
+}
+
+// mergeNestedJWTClaims decodes the claim configured with nested_jwt_claim as a JWT
+// and merges its claims into the top level of rawJSON. Claims already present in
+// rawJSON take precedence over the nested ones.
+func (s *SocialBase) mergeNestedJWTClaims(rawJSON []byte) ([]byte, error) {
+	if s.nestedJWTClaim == "" || len(rawJSON) == 0 {
+		return rawJSON, nil
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal(rawJSON, &claims); err != nil {
+		return nil, errInvalidNestedJWT.Errorf("failed to unmarshal claims: %w", err)
+	}
+
+	nested, ok := claims[s.nestedJWTClaim]
+	if !ok {
+		s.log.Debug("Nested JWT claim not found", "claim", s.nestedJWTClaim)
+		return rawJSON, nil
+	}
+
+	nestedJSON, err := s.retrieveRawIDToken(nested)
+	if err != nil {
+		return nil, errInvalidNestedJWT.Errorf("failed to decode nested JWT claim %q: %w", s.nestedJWTClaim, err)
+	}
+
+	var nestedClaims map[string]any
+	if err := json.Unmarshal(nestedJSON, &nestedClaims); err != nil {
+		return nil, errInvalidNestedJWT.Errorf("failed to unmarshal nested JWT claim %q: %w", s.nestedJWTClaim, err)
+	}
+
+	for k, v := range nestedClaims {
+		if _, exists := claims[k]; !exists {
+			claims[k] = v
+		}
+	}
+
+	return json.Marshal(claims)
+}
+
+// mergeIDTokenClaims adds the claims of the id_token missing from the user info response, so that attribute paths
+// can use claims the IdP only puts in the id_token.
+func (s *SocialBase) mergeIDTokenClaims(rawJSON []byte, idToken any) ([]byte, error) {
+	idTokenJSON, err := s.retrieveRawIDToken(idToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode id_token claims: %w", err)
+	}
+
+	merged, err := mergeMissingClaims(rawJSON, idTokenJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge id_token claims: %w", err)
+	}
+	return merged, nil
+}
+
+// mergeMissingClaims adds the top level claims of claimsJSON missing from rawJSON.
+func mergeMissingClaims(rawJSON, claimsJSON []byte) ([]byte, error) {
+	var extraClaims map[string]any
+	if err := json.Unmarshal(claimsJSON, &extraClaims); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal claims: %w", err)
+	}
+
+	claims := map[string]any{}
+	if len(rawJSON) > 0 {
+		if err := json.Unmarshal(rawJSON, &claims); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal user info: %w", err)
+		}
+	}
+
+	for k, v := range extraClaims {
+		if _, exists := claims[k]; !exists {
+			claims[k] = v
+		}
+	}
+
+	return json.Marshal(claims)
+}
+
+// expandXMLClaim replaces the claim configured with xml_claim, which carries an XML fragment,
+// with its generic map representation so it can be searched with JMESPath.
+func (s *SocialBase) expandXMLClaim(rawJSON []byte) ([]byte, error) {
+	if s.xmlClaim == "" || len(rawJSON) == 0 {
+		return rawJSON, nil
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal(rawJSON, &claims); err != nil {
+		return nil, errInvalidXMLClaim.Errorf("failed to unmarshal claims: %w", err)
+	}
+
+	value, ok := claims[s.xmlClaim]
+	if !ok {
+		s.log.Debug("XML claim not found", "claim", s.xmlClaim)
+		return rawJSON, nil
+	}
+
+	fragment, ok := value.(string)
+	if !ok {
+		return nil, errInvalidXMLClaim.Errorf("XML claim %q is not a string", s.xmlClaim)
+	}
+
+	parsed, err := parseXMLToMap([]byte(fragment))
+	if err != nil {
+		return nil, errInvalidXMLClaim.Errorf("failed to parse XML claim %q: %w", s.xmlClaim, err)
+	}
+	claims[s.xmlClaim] = parsed
+
+	return json.Marshal(claims)
+}
+
+// compileEmailAllowedRegex compiles the email_allowed_regex setting, if set.
+func (s *SocialBase) compileEmailAllowedRegex() error {
+	expr := s.info.Extra["email_allowed_regex"]
+	if expr == "" {
+		return nil
+	}
+
+	emailAllowedRegex, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("invalid email_allowed_regex %q: %w", expr, err)
+	}
+	s.emailAllowedRegex = emailAllowedRegex
+
+	return nil
+}
+
+// checkEmailAllowedRegex returns errEmailNotAllowed when email does not match email_allowed_regex.
+func (s *SocialBase) checkEmailAllowedRegex(email string) error {
+	if s.emailAllowedRegex == nil || s.emailAllowedRegex.MatchString(email) {
+		return nil
+	}
+
+	return errEmailNotAllowed.Errorf("email %q does not match email_allowed_regex", email)
+}
+
+// checkEmailDomain returns errEmailDomainNotAllowed when the domain of email is in blocked_domains, or
+// allowed_domains is set and does not contain it. blocked_domains takes precedence over allowed_domains.
+func (s *SocialBase) checkEmailDomain(email string) error {
+	if isEmailBlocked(email, s.blockedDomains) {
+		return errEmailDomainNotAllowed.Errorf("email %q has a domain in blocked_domains", email)
+	}
+
+	if !isEmailAllowed(email, s.allowedDomains) {
+		return errEmailDomainNotAllowed.Errorf("email %q does not have a domain in allowed_domains", email)
+	}
+
+	return nil
+}
+
+// capRoleByDomain lowers the role of userInfo to the max_role_by_domain cap of its email domain, revoking the
+// Grafana admin flag along with it. It never raises the role.
+func (s *SocialBase) capRoleByDomain(userInfo *BasicUserInfo) {
+	if len(s.maxRoleByDomain) == 0 || userInfo.Role == "" {
+		return
+	}
+
+	_, domain, found := strings.Cut(userInfo.Email, "@")
+	if !found {
+		return
+	}
+
+	maxRole, ok := s.maxRoleByDomain[strings.ToLower(domain)]
+	if !ok || maxRole.Includes(userInfo.Role) {
+		return
+	}
+
+	s.log.Debug("Capping role by email domain", "domain", domain, "role", userInfo.Role, "max_role", maxRole)
+	userInfo.Role = maxRole
+	if userInfo.IsGrafanaAdmin != nil && *userInfo.IsGrafanaAdmin {
+		grafanaAdmin := false
+		userInfo.IsGrafanaAdmin = &grafanaAdmin
+	}
+}
+
+// validateEmptyUserInfoAction returns an error if empty_userinfo_action is set to an unknown action.
+func (s *SocialBase) validateEmptyUserInfoAction() error {
+	switch s.emptyUserInfoAction {
+	case "", emptyUserInfoActionError, emptyUserInfoActionIDToken, emptyUserInfoActionDefaults:
+		return nil
+	default:
+		return fmt.Errorf("invalid empty_userinfo_action %q, must be one of %q, %q or %q", s.emptyUserInfoAction,
+			emptyUserInfoActionError, emptyUserInfoActionIDToken, emptyUserInfoActionDefaults)
+	}
+}
+
+// userInfoMethod returns the HTTP method of user info requests configured with userinfo_method, defaulting to GET.
+func userInfoMethod(info *OAuthInfo) string {
+	method := strings.ToUpper(strings.TrimSpace(info.Extra["userinfo_method"]))
+	if method == "" {
+		return http.MethodGet
+	}
+	return method
+}
+
+// compileUserInfoRequest validates userinfo_method and compiles userinfo_body_template, which is only allowed for
+// POST requests.
+func (s *SocialBase) compileUserInfoRequest() error {
+	bodyTemplate := s.info.Extra["userinfo_body_template"]
+	switch s.userInfoMethod {
+	case http.MethodGet:
+		if bodyTemplate != "" {
+			return fmt.Errorf("userinfo_body_template requires userinfo_method %q", http.MethodPost)
+		}
+		return nil
+	case http.MethodPost:
+	default:
+		return fmt.Errorf("invalid userinfo_method %q, must be %q or %q", s.userInfoMethod, http.MethodGet, http.MethodPost)
+	}
+
+	if bodyTemplate == "" {
+		bodyTemplate = defaultUserInfoBodyTemplate
+	}
+	tmpl, err := template.New("userinfo_body_template").Option("missingkey=error").Parse(bodyTemplate)
+	if err != nil {
+		return fmt.Errorf("invalid userinfo_body_template: %w", err)
+	}
+	s.userInfoBodyTemplate = tmpl
+
+	return nil
+}
+
+// nullRoleFallback returns the role applied when role_attribute_path yields no role, configured with
+// null_role_fallback or its alias role_attribute_default.
+func nullRoleFallback(info *OAuthInfo) org.RoleType {
+	role := info.Extra["null_role_fallback"]
+	if role == "" {
+		role = info.Extra["role_attribute_default"]
+	}
+	return org.RoleType(cases.Title(language.Und).String(role))
+}
+
+// validateNullRoleFallback returns an error when null_role_fallback or role_attribute_default is not a valid role.
+func (s *SocialBase) validateNullRoleFallback() error {
+	if s.nullRoleFallback != "" && !s.nullRoleFallback.IsValid() {
+		return fmt.Errorf("invalid null_role_fallback or role_attribute_default %q, must be a valid role", s.nullRoleFallback)
+	}
+	return nil
+}
+
+// checkACR returns ErrStepUpRequired when acr does not satisfy the required_acr setting.
+func (s *SocialBase) checkACR(acr string) error {
+	if s.requiredACR == "" || acr == s.requiredACR {
+		return nil
+	}
+
+	s.log.Debug("Insufficient acr, step-up authentication required", "acr", acr, "required_acr", s.requiredACR)
+	return ErrStepUpRequired.Build(errutil.TemplateData{
+		Private: map[string]any{"acr": acr},
+		Public:  map[string]any{"requiredAcr": s.requiredACR},
+	})
+}
+
+// parseAllowedIssuers parses allowed_issuers into normalized issuers.
+func parseAllowedIssuers(value string) []string {
+	issuers := util.SplitString(value)
+	for i, issuer := range issuers {
+		issuers[i] = normalizeIssuer(issuer)
+	}
+	return issuers
+}
+
+// normalizeIssuer strips trailing slashes so that issuers differing only by them are treated as equal.
+func normalizeIssuer(issuer string) string {
+	return strings.TrimRight(issuer, "/")
+}
+
+// checkIssuer returns errIssuerNotAllowed when allowed_issuers is set and iss is not one of them.
+func (s *SocialBase) checkIssuer(iss string) error {
+	if len(s.allowedIssuers) == 0 || slices.Contains(s.allowedIssuers, normalizeIssuer(iss)) {
+		return nil
+	}
+
+	s.log.Debug("id_token issuer is not allowed", "iss", iss, "allowed_issuers", s.allowedIssuers)
+	return errIssuerNotAllowed.Errorf("id_token issuer %q is not in allowed_issuers", iss)
+}
+
+// audience is the aud claim of an id_token, which is either a single string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("aud claim must be a string or an array of strings: %w", err)
+	}
+	*a = multiple
+	return nil
+}
+
+// checkAudience returns errAudienceNotAllowed when allowed_audiences is set and none of aud is one of them.
+func (s *SocialBase) checkAudience(aud audience) error {
+	if len(s.allowedAudiences) == 0 || slices.ContainsFunc(aud, func(a string) bool { return slices.Contains(s.allowedAudiences, a) }) {
+		return nil
+	}
+
+	s.log.Debug("id_token audience is not allowed", "aud", aud, "allowed_audiences", s.allowedAudiences)
+	return errAudienceNotAllowed.Errorf("id_token audience %q is not in allowed_audiences", []string(aud))
+}
+
+// loadIDTokenDecryptKey loads the PEM encoded private key at id_token_decrypt_key, used to decrypt encrypted id_tokens.
+func (s *SocialBase) loadIDTokenDecryptKey() error {
+	path := s.info.Extra["id_token_decrypt_key"]
+	if path == "" {
+		return nil
+	}
+
+	keyPEM, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read id_token_decrypt_key: %w", err)
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return fmt.Errorf("failed to parse id_token_decrypt_key: no PEM block found in %s", path)
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		s.idTokenDecryptKey = key
+		return nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		s.idTokenDecryptKey = key
+		return nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		s.idTokenDecryptKey = key
+		return nil
+	}
+
+	return fmt.Errorf("failed to parse id_token_decrypt_key: unsupported private key in %s", path)
+}
+
+// decryptIDToken returns the signed JWT nested in an encrypted (JWE) id_token. Other id_tokens are returned unchanged.
+func (s *SocialBase) decryptIDToken(tokenString string) (string, error) {
+	// a JWE in compact serialization has five parts, a JWS three
+	if strings.Count(tokenString, ".") != 4 {
+		return tokenString, nil
+	}
+
+	if s.idTokenDecryptKey == nil {
+		return "", fmt.Errorf("id_token is encrypted but id_token_decrypt_key is not configured")
+	}
+
+	encrypted, err := jose.ParseEncrypted(tokenString)
+	if err != nil {
+		return "", fmt.Errorf("error parsing encrypted id_token: %w", err)
+	}
+
+	decrypted, err := encrypted.Decrypt(s.idTokenDecryptKey)
+	if err != nil {
+		return "", fmt.Errorf("error decrypting id_token with id_token_decrypt_key: %w", err)
+	}
+
+	return string(decrypted), nil
+}
+
+// defaultRole returns the default role for the user based on the autoAssignOrgRole setting
+// if legacy is enabled "" is returned indicating the previous role assignment is used.
+func (s *SocialBase) defaultRole() org.RoleType {
+	if s.autoAssignOrgRole != "" {
+		s.log.Debug("No role found, returning default.")
+		return org.RoleType(s.autoAssignOrgRole)
+	}
+
+	// should never happen
+	return org.RoleViewer
+}
+
+// roleFromRawRole returns the role and Grafana admin flag of a role matched by role_attribute_path. The raw roles
+// listed in role_values_grafana_admin map to role_values_grafana_admin_org_role with the Grafana admin flag, and
+// the ones listed in role_value_mapping are replaced by the role they map to. Numeric raw roles map to the role of
+// their level in role_level_mapping, when set. Other raw roles are used as is.
+func (s *SocialBase) roleFromRawRole(rawRole string) (org.RoleType, bool) {
+	if role, ok := s.roleValueMapping[strings.ToLower(rawRole)]; ok {
+		return getRoleFromSearch(role)
+	}
+
+	if len(s.roleLevelMapping) > 0 {
+		if level, err := strconv.ParseFloat(rawRole, 64); err == nil {
+			return getRoleFromSearch(s.roleFromLevel(level))
+		}
+	}
+
+	for _, grafanaAdminRole := range s.grafanaAdminRoles {
+		if strings.EqualFold(rawRole, grafanaAdminRole) {
+			return s.grafanaAdminOrgRole, true
+		}
+	}
+	return getRoleFromSearch(rawRole)
+}
+
+// match grafana admin role and translate to org role and bool.
+// treat the JSON search result to ensure correct casing.
+func getRoleFromSearch(role string) (org.RoleType, bool) {
+	if strings.EqualFold(role, RoleGrafanaAdmin) {
+		return org.RoleAdmin, true
+	}
+
+	return org.RoleType(cases.Title(language.Und).String(role)), false
+}
+
+// GetOAuthProviders returns available oauth providers and if they're enabled or not
+func (ss *SocialService) GetOAuthProviders() map[string]bool {
+	result := map[string]bool{}
+
+	if ss.cfg == nil || ss.cfg.Raw == nil {
+		return result
+	}
+
+	for _, name := range allOauthes {
+		if name == "grafananet" {
+			name = grafanaCom
+		}
+
+		sec := ss.cfg.Raw.Section("auth." + name)
+		if sec == nil {
+			continue
+		}
+		result[name] = sec.Key("enabled").MustBool()
+	}
+
+	return result
+}
+
+func (ss *SocialService) GetOAuthHttpClient(name string) (*http.Client, error) {
+	// The socialMap keys don't have "oauth_" prefix, but everywhere else in the system does
+	name = strings.TrimPrefix(name, "oauth_")
+	info := ss.GetOAuthInfoProvider(name)
+	if info == nil {
+		return nil, fmt.Errorf("could not find %q in OAuth Settings", name)
+	}
+
+	ss.httpClientsMu.Lock()
+	defer ss.httpClientsMu.Unlock()
+
+	// the client is built again when the transport settings of the provider change
+	key := httpClientCacheKey(info)
+	cached, ok := ss.httpClients[name]
+	if ok && cached.key == key {
+		return cached.client, nil
+	}
+
+	client, err := ss.newOAuthHttpClient(name, info)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		cached.client.CloseIdleConnections()
+	}
+	if ss.httpClients == nil {
+		ss.httpClients = make(map[string]cachedHTTPClient)
+	}
+	ss.httpClients[name] = cachedHTTPClient{key: key, client: client}
+
+	return client, nil
+}
+
+// transportSettingKeys are the provider settings, besides the TLS ones, that newHTTPClient builds the client from.
+var transportSettingKeys = []string{
+	"http_client_timeout",
+	"max_idle_conns",
+	"max_idle_conns_per_host",
+	"idle_conn_timeout",
+	"keep_alive",
+	"correlation_header",
+}
+
+// httpClientCacheKey identifies the settings a provider HTTP client is built from.
+func httpClientCacheKey(info *OAuthInfo) string {
+	values := []string{
+		strconv.FormatBool(info.TlsSkipVerify),
+		info.TlsClientCert,
+		info.TlsClientKey,
+		info.TlsClientCa,
+	}
+	for _, key := range transportSettingKeys {
+		values = append(values, info.Extra[key])
+	}
+
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, strconv.Quote(value))
+	}
+	return strings.Join(quoted, ",")
+}
+
+// transportSettings configures the timeout and the connection pool of a provider HTTP client.
+type transportSettings struct {
+	clientTimeout       time.Duration
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	keepAlive           time.Duration
+}
+
+// parseTransportSettings reads the http_client_timeout, max_idle_conns, max_idle_conns_per_host, idle_conn_timeout
+// and keep_alive provider settings, falling back to the defaults for unset values. Providers call it at construction
+// so that invalid values are reported early.
+func parseTransportSettings(info *OAuthInfo) (transportSettings, error) {
+	settings := transportSettings{
+		clientTimeout:   15 * time.Second,
+		maxIdleConns:    100,
+		idleConnTimeout: 90 * time.Second,
+		keepAlive:       30 * time.Second,
+	}
+
+	var err error
+	if value := info.Extra["http_client_timeout"]; value != "" {
+		if settings.clientTimeout, err = time.ParseDuration(value); err != nil {
+			return settings, fmt.Errorf("invalid http_client_timeout %q: %w", value, err)
+		}
+		if settings.clientTimeout <= 0 {
+			return settings, fmt.Errorf("invalid http_client_timeout %q: must be positive", value)
+		}
+	}
+	if value := info.Extra["max_idle_conns"]; value != "" {
+		if settings.maxIdleConns, err = strconv.Atoi(value); err != nil {
+			return settings, fmt.Errorf("invalid max_idle_conns %q: %w", value, err)
+		}
+		if settings.maxIdleConns < 0 {
+			return settings, fmt.Errorf("invalid max_idle_conns %q: must not be negative", value)
+		}
+	}
+	if value := info.Extra["max_idle_conns_per_host"]; value != "" {
+		if settings.maxIdleConnsPerHost, err = strconv.Atoi(value); err != nil {
+			return settings, fmt.Errorf("invalid max_idle_conns_per_host %q: %w", value, err)
+		}
+	}
+	if value := info.Extra["idle_conn_timeout"]; value != "" {
+		if settings.idleConnTimeout, err = time.ParseDuration(value); err != nil {
+			return settings, fmt.Errorf("invalid idle_conn_timeout %q: %w", value, err)
+		}
+		if settings.idleConnTimeout < 0 {
+			return settings, fmt.Errorf("invalid idle_conn_timeout %q: must not be negative", value)
+		}
+	}
+	if value := info.Extra["keep_alive"]; value != "" {
+		if settings.keepAlive, err = time.ParseDuration(value); err != nil {
+			return settings, fmt.Errorf("invalid keep_alive %q: %w", value, err)
+		}
+	}
+
+	return settings, nil
+}
+
+func (ss *SocialService) newOAuthHttpClient(name string, info *OAuthInfo) (*http.Client, error) {
+	client, err := newHTTPClient(info)
+	if err != nil {
+		ss.log.Error("Failed to setup HTTP client", "oauth", name, "error", err)
+		return nil, err
+	}
+	return client, nil
+}
+
+// newHTTPClient returns an HTTP client configured with the transport and TLS settings of the provider.
+// When correlation_header is set, the requests carry a correlation ID in that header.
+func newHTTPClient(info *OAuthInfo) (*http.Client, error) {
+	settings, err := parseTransportSettings(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup HTTP client transport: %w", err)
+	}
+
+	tlsConfig, err := newTLSClientConfig(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup HTTP client TLS config: %w", err)
+	}
+
+	// handle call back
+	tr := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+		DialContext: (&net.Dialer{
+			Timeout:   time.Second * 10,
+			KeepAlive: settings.keepAlive,
+		}).DialContext,
+		TLSHandshakeTimeout:   15 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConns:          settings.maxIdleConns,
+		MaxIdleConnsPerHost:   settings.maxIdleConnsPerHost,
+		IdleConnTimeout:       settings.idleConnTimeout,
+	}
+
+	oauthClient := &http.Client{
+		Transport: tr,
+		Timeout:   settings.clientTimeout,
+	}
+	if header := info.Extra["correlation_header"]; header != "" {
+		oauthClient.Transport = &correlationTransport{header: http.CanonicalHeaderKey(header), next: tr}
+	}
+
+	return oauthClient, nil
+}
+
+// newTLSClientConfig builds the TLS config presenting tls_client_cert and trusting tls_client_ca.
+// Providers call it at construction so that malformed certificates are reported early.
+func newTLSClientConfig(info *OAuthInfo) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: info.TlsSkipVerify,
+	}
+
+	if info.TlsClientCert != "" || info.TlsClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(info.TlsClientCert, info.TlsClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup TlsClientCert: %w", err)
+		}
+
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	if info.TlsClientCa != "" {
+		caCert, err := os.ReadFile(info.TlsClientCa)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup TlsClientCa: %w", err)
+		}
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to setup TlsClientCa: no certificates found in %s", info.TlsClientCa)
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	return tlsConfig, nil
+}
+
+func (ss *SocialService) GetConnector(name string) (SocialConnector, error) {
+	// The socialMap keys don't have "oauth_" prefix, but everywhere else in the system does
+	provider := strings.TrimPrefix(name, "oauth_")
+	connector, ok := ss.socialMap[provider]
+	if !ok {
+		return nil, fmt.Errorf("failed to find oauth provider for %q", name)
+	}
+	return connector, nil
+}
+
+func (ss *SocialService) GetOAuthInfoProvider(name string) *OAuthInfo {
+	ss.providersMu.RLock()
+	defer ss.providersMu.RUnlock()
+	return ss.oAuthProvider[name]
+}
+
+func (ss *SocialService) GetOAuthInfoProviders() map[string]*OAuthInfo {
+	ss.providersMu.RLock()
+	defer ss.providersMu.RUnlock()
+	return maps.Clone(ss.oAuthProvider)
+}
+
+// reloadableConnector is implemented by the connectors whose settings can be swapped without a restart.
+type reloadableConnector interface {
+	Reload(settings map[string]any, cfg *setting.Cfg) error
+}
+
+// ReloadProvider validates settings and swaps them in for the connector of provider name, along with the
+// OAuthInfo served by the service. The provider HTTP client is built again on next use when the reloaded TLS or
+// transport settings differ. Connectors that don't support being reloaded fail with an error.
+func (ss *SocialService) ReloadProvider(name string, settings map[string]any) error {
+	name = strings.TrimPrefix(name, "oauth_")
+	connector, ok := ss.socialMap[name]
+	if !ok {
+		return fmt.Errorf("failed to find oauth provider for %q", name)
+	}
+
+	reloadable, ok := connector.(reloadableConnector)
+	if !ok {
+		return fmt.Errorf("oauth provider %q can't be reloaded", name)
+	}
+
+	if err := reloadable.Reload(settings, ss.cfg); err != nil {
+		return err
+	}
+
+	ss.providersMu.Lock()
+	defer ss.providersMu.Unlock()
+	ss.oAuthProvider[name] = connector.GetOAuthInfo()
+	return nil
 }
 
 func (ss *SocialService) getUsageStats(ctx context.Context) (map[string]any, error) {
@@ -446,46 +1887,88 @@ func (s *SocialBase) isGroupMember(groups []string) bool {
 	return false
 }
 
+// TeamMemberships evaluates teams_attribute_path against the id_token claims and, when they
+// don't yield any team, against the user info response.
+func (s *SocialBase) TeamMemberships(ctx context.Context, client *http.Client, token *oauth2.Token) ([]string, error) {
+	if s.teamsAttributePath == "" {
+		return nil, nil
+	}
+	if token == nil {
+		return nil, ErrMissingAccessToken.Errorf("token is missing")
+	}
+
+	if idToken := token.Extra("id_token"); idToken != nil {
+		rawJSON, err := s.retrieveRawIDToken(idToken)
+		if err != nil {
+			s.log.Debug("Error retrieving id_token for team memberships", "error", err)
+		} else {
+			teams, err := s.searchJSONForStringArrayAttr(s.teamsAttributePath, rawJSON)
+			if err != nil {
+				return nil, err
+			}
+			if len(teams) > 0 {
+				return teams, nil
+			}
+		}
+	}
+
+	if s.info.ApiUrl == "" {
+		return []string{}, nil
+	}
+
+	response, err := s.userInfoGet(ctx, client, token, s.info.ApiUrl)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user info response: %w", err)
+	}
+
+	return s.searchJSONForStringArrayAttr(s.teamsAttributePath, response.Body)
+}
+
 func (s *SocialBase) retrieveRawIDToken(idToken any) ([]byte, error) {
 	tokenString, ok := idToken.(string)
 	if !ok {
-		return nil, fmt.Errorf("id_token is not a string: %v", idToken)
+		return nil, ErrInvalidIDToken.Errorf("id_token is not a string: %T", idToken)
+	}
+
+	tokenString, err := s.decryptIDToken(tokenString)
+	if err != nil {
+		return nil, ErrInvalidIDToken.Errorf("%w", err)
 	}
 
 	jwtRegexp := regexp.MustCompile("^([-_a-zA-Z0-9=]+)[.]([-_a-zA-Z0-9=]+)[.]([-_a-zA-Z0-9=]+)$")
 	matched := jwtRegexp.FindStringSubmatch(tokenString)
 	if matched == nil {
-		return nil, fmt.Errorf("id_token is not in JWT format: %s", tokenString)
+		return nil, ErrInvalidIDToken.Errorf("id_token is not in JWT format, it has %d parts instead of 3", strings.Count(tokenString, ".")+1)
 	}
 
 	rawJSON, err := base64.RawURLEncoding.DecodeString(matched[2])
 	if err != nil {
-		return nil, fmt.Errorf("error base64 decoding id_token: %w", err)
+		return nil, ErrInvalidIDToken.Errorf("error base64 decoding id_token: %w", err)
 	}
 
 	headerBytes, err := base64.RawURLEncoding.DecodeString(matched[1])
 	if err != nil {
-		return nil, fmt.Errorf("error base64 decoding header: %w", err)
+		return nil, ErrInvalidIDToken.Errorf("error base64 decoding header: %w", err)
 	}
 
 	var header map[string]any
 	if err := json.Unmarshal(headerBytes, &header); err != nil {
-		return nil, fmt.Errorf("error deserializing header: %w", err)
+		return nil, ErrInvalidIDToken.Errorf("error deserializing header: %w", err)
 	}
 
 	if compressionVal, exists := header["zip"]; exists {
 		compression, ok := compressionVal.(string)
 		if !ok {
-			return nil, fmt.Errorf("unrecognized compression header: %v", compressionVal)
+			return nil, ErrInvalidIDToken.Errorf("unrecognized compression header: %v", compressionVal)
 		}
 
 		if compression != "DEF" {
-			return nil, fmt.Errorf("unknown compression algorithm: %s", compression)
+			return nil, ErrInvalidIDToken.Errorf("unknown compression algorithm: %s", compression)
 		}
 
 		fr, err := zlib.NewReader(bytes.NewReader(rawJSON))
 		if err != nil {
-			return nil, fmt.Errorf("error creating zlib reader: %w", err)
+			return nil, ErrInvalidIDToken.Errorf("error creating zlib reader: %w", err)
 		}
 		defer func() {
 			if err := fr.Close(); err != nil {
@@ -495,10 +1978,14 @@ func (s *SocialBase) retrieveRawIDToken(idToken any) ([]byte, error) {
 
 		rawJSON, err = io.ReadAll(fr)
 		if err != nil {
-			return nil, fmt.Errorf("error decompressing payload: %w", err)
+			return nil, ErrInvalidIDToken.Errorf("error decompressing payload: %w", err)
 		}
 	}
 
+	if !json.Valid(rawJSON) || !bytes.HasPrefix(bytes.TrimSpace(rawJSON), []byte("{")) {
+		return nil, ErrInvalidIDToken.Errorf("id_token claims are not a JSON object")
+	}
+
 	return rawJSON, nil
 }
 
@@ -518,6 +2005,10 @@ func (ss *SocialService) createOAuthConnector(name string, settings map[string]a
 		return NewGrafanaComProvider(settings, cfg, features)
 	case oktaProviderName:
 		return NewOktaProvider(settings, cfg, features)
+	case discordProviderName:
+		return NewDiscordProvider(settings, cfg, features)
+	case stubProviderName:
+		return NewStubProvider(settings, cfg, features)
 	default:
 		return nil, fmt.Errorf("unknown oauth provider: %s", name)
 	}
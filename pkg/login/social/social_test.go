@@ -0,0 +1,614 @@
+package social
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/metrics"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestSocialService_GetOAuthHttpClient(t *testing.T) {
+	newService := func(extra map[string]string) *SocialService {
+		return &SocialService{
+			oAuthProvider: map[string]*OAuthInfo{"generic_oauth": {Extra: extra}},
+			log:           log.NewNopLogger(),
+		}
+	}
+
+	t.Run("should use the default connection pool", func(t *testing.T) {
+		client, err := newService(map[string]string{}).GetOAuthHttpClient("oauth_generic_oauth")
+		require.NoError(t, err)
+
+		require.Equal(t, 15*time.Second, client.Timeout)
+		tr := client.Transport.(*http.Transport)
+		require.Equal(t, 100, tr.MaxIdleConns)
+		require.Equal(t, 0, tr.MaxIdleConnsPerHost)
+		require.Equal(t, 90*time.Second, tr.IdleConnTimeout)
+	})
+
+	t.Run("should use the configured connection pool", func(t *testing.T) {
+		client, err := newService(map[string]string{
+			"http_client_timeout":     "5s",
+			"max_idle_conns":          "200",
+			"max_idle_conns_per_host": "50",
+			"idle_conn_timeout":       "2m",
+			"keep_alive":              "1m",
+		}).GetOAuthHttpClient("oauth_generic_oauth")
+		require.NoError(t, err)
+
+		require.Equal(t, 5*time.Second, client.Timeout)
+		tr := client.Transport.(*http.Transport)
+		require.Equal(t, 200, tr.MaxIdleConns)
+		require.Equal(t, 50, tr.MaxIdleConnsPerHost)
+		require.Equal(t, 2*time.Minute, tr.IdleConnTimeout)
+	})
+
+	t.Run("should reuse the client across calls", func(t *testing.T) {
+		ss := newService(map[string]string{})
+
+		first, err := ss.GetOAuthHttpClient("oauth_generic_oauth")
+		require.NoError(t, err)
+		second, err := ss.GetOAuthHttpClient("generic_oauth")
+		require.NoError(t, err)
+		require.Same(t, first, second)
+	})
+
+	t.Run("should build the client again when the transport settings change", func(t *testing.T) {
+		ss := newService(map[string]string{})
+
+		first, err := ss.GetOAuthHttpClient("generic_oauth")
+		require.NoError(t, err)
+
+		ss.oAuthProvider["generic_oauth"] = &OAuthInfo{Extra: map[string]string{"http_client_timeout": "5s"}}
+		second, err := ss.GetOAuthHttpClient("generic_oauth")
+		require.NoError(t, err)
+		require.NotSame(t, first, second)
+		require.Equal(t, 5*time.Second, second.Timeout)
+
+		ss.oAuthProvider["generic_oauth"] = &OAuthInfo{ClientId: "other", Extra: map[string]string{"http_client_timeout": "5s"}}
+		third, err := ss.GetOAuthHttpClient("generic_oauth")
+		require.NoError(t, err)
+		require.Same(t, second, third, "settings unrelated to the transport keep the client")
+	})
+
+	t.Run("should fail on an invalid setting", func(t *testing.T) {
+		_, err := newService(map[string]string{"max_idle_conns_per_host": "many"}).GetOAuthHttpClient("generic_oauth")
+		require.ErrorContains(t, err, "invalid max_idle_conns_per_host")
+	})
+}
+
+func TestSocialBase_RoleSyncMetrics(t *testing.T) {
+	tests := []struct {
+		name            string
+		rawJSON         string
+		expectedOutcome string
+		wantErr         bool
+	}{
+		{
+			name:            "should count a matched role",
+			rawJSON:         `{"role": "Editor"}`,
+			expectedOutcome: roleSyncMatched,
+		},
+		{
+			name:            "should count an empty match",
+			rawJSON:         `{}`,
+			expectedOutcome: roleSyncEmpty,
+		},
+		{
+			name:            "should count an invalid role",
+			rawJSON:         `{"role": "Superuser"}`,
+			expectedOutcome: roleSyncInvalidRole,
+			wantErr:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newSocialBase("role_sync_"+tt.expectedOutcome, &oauth2.Config{}, &OAuthInfo{}, string(org.RoleViewer), false, *featuremgmt.WithFeatures())
+			provider.roleAttributePath = "role"
+
+			counter := metrics.MOAuthRoleSync.WithLabelValues(provider.providerName, tt.expectedOutcome)
+			before := testutil.ToFloat64(counter)
+
+			_, _, err := provider.extractRoleAndAdmin([]byte(tt.rawJSON), nil)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, before+1, testutil.ToFloat64(counter))
+		})
+	}
+}
+
+func TestSocialBase_PreviewMapping(t *testing.T) {
+	tests := []struct {
+		name        string
+		configure   func(s *SocialBase)
+		rawJSON     string
+		expected    MappingResult
+		expectedErr error
+	}{
+		{
+			name:      "should resolve the role and groups",
+			configure: func(s *SocialBase) { s.roleAttributePath = "role" },
+			rawJSON:   `{"role": "Editor", "groups": ["devs"]}`,
+			expected:  MappingResult{Role: org.RoleEditor, Groups: []string{"devs"}},
+		},
+		{
+			name: "should resolve the Grafana admin flag",
+			configure: func(s *SocialBase) {
+				s.roleAttributePath = "role"
+				s.grafanaAdminPath = "admin"
+				s.allowAssignGrafanaAdmin = true
+			},
+			rawJSON:  `{"role": "Admin", "admin": true}`,
+			expected: MappingResult{Role: org.RoleAdmin, IsGrafanaAdmin: true},
+		},
+		{
+			name: "should warn when the Grafana admin flag is not synced",
+			configure: func(s *SocialBase) {
+				s.roleAttributePath = "role"
+				s.grafanaAdminPath = "admin"
+			},
+			rawJSON: `{"role": "Admin", "admin": true}`,
+			expected: MappingResult{Role: org.RoleAdmin, Warnings: []string{
+				"allow_assign_grafana_admin is not set, the Grafana admin flag is not synced",
+			}},
+		},
+		{
+			name:      "should warn when the default role is used",
+			configure: func(s *SocialBase) { s.roleAttributePath = "role" },
+			rawJSON:   `{}`,
+			expected:  MappingResult{Role: org.RoleViewer, Warnings: []string{`no role found, the default role "Viewer" is used`}},
+		},
+		{
+			name: "should warn when null_role_fallback is used",
+			configure: func(s *SocialBase) {
+				s.roleAttributePath = "role"
+				s.nullRoleFallback = org.RoleEditor
+			},
+			rawJSON:  `{}`,
+			expected: MappingResult{Role: org.RoleEditor, Warnings: []string{`no role found, null_role_fallback "Editor" is used`}},
+		},
+		{
+			name: "should warn when role sync is skipped",
+			configure: func(s *SocialBase) {
+				s.roleAttributePath = "role"
+				s.skipOrgRoleSync = true
+			},
+			rawJSON:  `{"role": "Editor"}`,
+			expected: MappingResult{Role: org.RoleEditor, Warnings: []string{"skip_org_role_sync is set, the role is not synced at login"}},
+		},
+		{
+			name:        "should fail on an invalid role",
+			configure:   func(s *SocialBase) { s.roleAttributePath = "role" },
+			rawJSON:     `{"role": "Superuser"}`,
+			expectedErr: ErrInvalidRole,
+		},
+		{
+			name:        "should fail on an invalid attribute path",
+			configure:   func(s *SocialBase) { s.roleAttributePath = "[" },
+			rawJSON:     `{"role": "Editor"}`,
+			expectedErr: ErrAttributePath,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newSocialBase("preview_mapping", &oauth2.Config{}, &OAuthInfo{GroupsAttributePath: "groups"}, string(org.RoleViewer), false, *featuremgmt.WithFeatures())
+			tt.configure(provider)
+
+			counter := metrics.MOAuthRoleSync.WithLabelValues(provider.providerName, roleSyncMatched)
+			before := testutil.ToFloat64(counter)
+
+			result, err := provider.PreviewMapping(context.Background(), []byte(tt.rawJSON))
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected.Role, result.Role)
+			require.Equal(t, tt.expected.IsGrafanaAdmin, result.IsGrafanaAdmin)
+			require.ElementsMatch(t, tt.expected.Groups, result.Groups)
+			require.Equal(t, tt.expected.Warnings, result.Warnings)
+			require.Equal(t, before, testutil.ToFloat64(counter))
+		})
+	}
+}
+
+func TestRefreshUserInfo(t *testing.T) {
+	newProvider := func(t *testing.T, skipOrgRoleSync bool) *SocialGenericOAuth {
+		provider, err := NewGenericOAuthProvider(map[string]any{
+			"role_attribute_path": "role",
+		}, &setting.Cfg{AutoAssignOrgRole: "Viewer", GenericOAuthSkipOrgRoleSync: skipOrgRoleSync}, featuremgmt.WithFeatures())
+		require.NoError(t, err)
+		return provider
+	}
+	newToken := func(t *testing.T, role string) *oauth2.Token {
+		token := &oauth2.Token{AccessToken: "access-token", Expiry: time.Now().Add(time.Hour)}
+		return token.WithExtra(map[string]any{"id_token": createTestIDToken(t, map[string]any{"email": "john.doe@example.com", "role": role})})
+	}
+
+	t.Run("should resolve the role of the refreshed token", func(t *testing.T) {
+		provider := newProvider(t, false)
+
+		userInfo, err := provider.UserInfo(context.Background(), http.DefaultClient, newToken(t, "Viewer"))
+		require.NoError(t, err)
+		require.Equal(t, org.RoleViewer, userInfo.Role)
+
+		refreshed, err := RefreshUserInfo(context.Background(), provider, http.DefaultClient, newToken(t, "Admin"))
+		require.NoError(t, err)
+		require.Equal(t, org.RoleAdmin, refreshed.Role)
+		require.Equal(t, "john.doe@example.com", refreshed.Email)
+	})
+
+	t.Run("should not resolve the role when skip_org_role_sync is set", func(t *testing.T) {
+		refreshed, err := RefreshUserInfo(context.Background(), newProvider(t, true), http.DefaultClient, newToken(t, "Admin"))
+		require.NoError(t, err)
+		require.Empty(t, refreshed.Role)
+	})
+
+	t.Run("should fail on a missing or expired token", func(t *testing.T) {
+		expired := newToken(t, "Admin")
+		expired.Expiry = time.Now().Add(-time.Minute)
+		_, err := RefreshUserInfo(context.Background(), newProvider(t, false), http.DefaultClient, expired)
+		require.ErrorIs(t, err, errInvalidRefreshedToken)
+
+		_, err = RefreshUserInfo(context.Background(), newProvider(t, false), http.DefaultClient, nil)
+		require.ErrorIs(t, err, errInvalidRefreshedToken)
+	})
+}
+
+func TestSocialBase_RoleCasing(t *testing.T) {
+	tests := []struct {
+		name                 string
+		rawRole              string
+		expectedRole         org.RoleType
+		expectedGrafanaAdmin bool
+		expectedErr          error
+	}{
+		{name: "should accept a lowercase role", rawRole: "admin", expectedRole: org.RoleAdmin},
+		{name: "should accept an uppercase role", rawRole: "EDITOR", expectedRole: org.RoleEditor},
+		{name: "should accept a mixed case role", rawRole: "vIeWeR", expectedRole: org.RoleViewer},
+		{name: "should accept an uppercase None role", rawRole: "NONE", expectedRole: org.RoleNone},
+		{name: "should accept a lowercase GrafanaAdmin role", rawRole: "grafanaadmin", expectedRole: org.RoleAdmin, expectedGrafanaAdmin: true},
+		{name: "should reject an invalid role whatever its casing", rawRole: "SUPERUSER", expectedErr: ErrInvalidRole},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &OAuthInfo{RoleAttributePath: "role", RoleAttributeStrict: true, AllowAssignGrafanaAdmin: true}
+			provider := newSocialBase("role_casing", &oauth2.Config{}, info, string(org.RoleViewer), false, *featuremgmt.WithFeatures())
+
+			role, grafanaAdmin, err := provider.extractRoleAndAdmin([]byte(`{"role": "`+tt.rawRole+`"}`), nil)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedRole, role)
+			require.Equal(t, tt.expectedGrafanaAdmin, grafanaAdmin)
+
+			mapping := newSocialBase("role_casing", &oauth2.Config{}, &OAuthInfo{Extra: map[string]string{
+				"group_role_mapping": "devs=" + tt.rawRole,
+			}}, string(org.RoleViewer), false, *featuremgmt.WithFeatures())
+			role, grafanaAdmin, err = mapping.extractRoleAndAdmin([]byte(`{}`), []string{"devs"})
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedRole, role)
+			require.Equal(t, tt.expectedGrafanaAdmin, grafanaAdmin)
+		})
+	}
+}
+
+func TestSocialBase_RoleAttributeStrictHighest(t *testing.T) {
+	tests := []struct {
+		name                 string
+		strict               string
+		roles                string
+		expectedRole         org.RoleType
+		expectedGrafanaAdmin bool
+		expectedErr          error
+	}{
+		{name: "should pick the most privileged role", strict: "highest", roles: `["Viewer", "Admin", "Editor"]`, expectedRole: org.RoleAdmin},
+		{name: "should ignore invalid roles", strict: "highest", roles: `["superuser", "viewer", 42, "Editor"]`, expectedRole: org.RoleEditor},
+		{name: "should prefer GrafanaAdmin over Admin", strict: "highest", roles: `["Admin", "GrafanaAdmin"]`, expectedRole: org.RoleAdmin, expectedGrafanaAdmin: true},
+		{name: "should fail when no role is valid", strict: "highest", roles: `["superuser", "owner"]`, expectedErr: ErrInvalidRole},
+		{name: "should stay strict when there are no roles", strict: "highest", roles: `[]`, expectedErr: errRoleAttributeStrictViolation},
+		{name: "should reject a list of roles without highest", strict: "true", roles: `["Viewer", "Admin"]`, expectedErr: errRoleAttributePathNotScalar},
+		{name: "should reject distinct roles with error", strict: "error", roles: `["Editor", "Admin"]`, expectedErr: ErrAmbiguousRole},
+		{name: "should use the single distinct role with error", strict: "error", roles: `["editor", "superuser", "Editor"]`, expectedRole: org.RoleEditor},
+		{name: "should fail when no role is valid with error", strict: "error", roles: `["superuser"]`, expectedErr: ErrInvalidRole},
+		{name: "should stay strict when there are no roles with error", strict: "error", roles: `[]`, expectedErr: errRoleAttributeStrictViolation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := createOAuthInfoFromKeyValues(map[string]any{
+				"role_attribute_path":        "roles",
+				"role_attribute_strict":      tt.strict,
+				"allow_assign_grafana_admin": true,
+			})
+			require.NoError(t, err)
+			require.True(t, info.RoleAttributeStrict)
+			provider := newSocialBase("role_highest", &oauth2.Config{}, info, string(org.RoleViewer), false, *featuremgmt.WithFeatures())
+
+			role, grafanaAdmin, err := provider.extractRoleAndAdmin([]byte(`{"roles": `+tt.roles+`}`), nil)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedRole, role)
+			require.Equal(t, tt.expectedGrafanaAdmin, grafanaAdmin)
+		})
+	}
+
+	t.Run("should list the candidates of an ambiguous role", func(t *testing.T) {
+		info, err := createOAuthInfoFromKeyValues(map[string]any{"role_attribute_path": "roles", "role_attribute_strict": "error"})
+		require.NoError(t, err)
+		provider := newSocialBase("role_error", &oauth2.Config{}, info, string(org.RoleViewer), false, *featuremgmt.WithFeatures())
+
+		_, _, err = provider.extractRoleAndAdmin([]byte(`{"roles": ["Editor", "Admin", "Editor"]}`), nil)
+		require.ErrorIs(t, err, ErrAmbiguousRole)
+		require.ErrorContains(t, err, `["Editor" "Admin"]`)
+	})
+}
+
+func TestSocialBase_MaxRoleByDomain(t *testing.T) {
+	tests := []struct {
+		name                 string
+		email                string
+		role                 string
+		expectedRole         org.RoleType
+		expectedGrafanaAdmin bool
+	}{
+		{name: "should cap the role of a capped domain", email: "jane@contractor.com", role: "Editor", expectedRole: org.RoleViewer},
+		{name: "should match the domain case insensitively", email: "jane@Contractor.COM", role: "Admin", expectedRole: org.RoleViewer},
+		{name: "should revoke Grafana admin when capping", email: "jane@contractor.com", role: "GrafanaAdmin", expectedRole: org.RoleViewer},
+		{name: "should keep a role below the cap", email: "jane@partner.com", role: "Viewer", expectedRole: org.RoleViewer},
+		{name: "should keep a role at the cap", email: "jane@partner.com", role: "Editor", expectedRole: org.RoleEditor},
+		{name: "should not cap other domains", email: "jane@grafana.com", role: "GrafanaAdmin", expectedRole: org.RoleAdmin, expectedGrafanaAdmin: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := NewGenericOAuthProvider(map[string]any{
+				"role_attribute_path":        "role",
+				"allow_assign_grafana_admin": "true",
+				"max_role_by_domain":         "contractor.com=Viewer, @partner.com=editor, invalid.com=Superuser",
+			}, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+			require.NoError(t, err)
+
+			token := (&oauth2.Token{}).WithExtra(map[string]any{
+				"id_token": createTestIDToken(t, map[string]any{"email": tt.email, "role": tt.role}),
+			})
+			userInfo, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedRole, userInfo.Role)
+			require.NotNil(t, userInfo.IsGrafanaAdmin)
+			require.Equal(t, tt.expectedGrafanaAdmin, *userInfo.IsGrafanaAdmin)
+		})
+	}
+
+	t.Run("should skip invalid entries", func(t *testing.T) {
+		require.Equal(t, map[string]org.RoleType{"partner.com": org.RoleEditor},
+			parseMaxRoleByDomain(log.NewNopLogger(), "partner.com=Editor, invalid.com=Superuser, =Viewer, missing"))
+	})
+}
+
+func TestSocialBase_RoleValueMapping(t *testing.T) {
+	tests := []struct {
+		name                 string
+		rawRole              string
+		expectedRole         org.RoleType
+		expectedGrafanaAdmin bool
+		expectedErr          error
+	}{
+		{name: "should map a raw role", rawRole: "lead", expectedRole: org.RoleAdmin},
+		{name: "should match the raw role case insensitively", rawRole: "Member", expectedRole: org.RoleEditor},
+		{name: "should map a raw role to Grafana admin", rawRole: "owner", expectedRole: org.RoleAdmin, expectedGrafanaAdmin: true},
+		{name: "should use an unmapped valid raw role as is", rawRole: "viewer", expectedRole: org.RoleViewer},
+		{name: "should reject an unmapped invalid raw role", rawRole: "contractor", expectedErr: ErrInvalidRole},
+		{name: "should skip entries mapping to an invalid role", rawRole: "guest", expectedErr: ErrInvalidRole},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &OAuthInfo{RoleAttributePath: "role", AllowAssignGrafanaAdmin: true, Extra: map[string]string{
+				"role_value_mapping": "lead:Admin member:editor owner:GrafanaAdmin guest:Superuser",
+			}}
+			provider := newSocialBase("role_value_mapping", &oauth2.Config{}, info, string(org.RoleViewer), false, *featuremgmt.WithFeatures())
+
+			role, grafanaAdmin, err := provider.extractRoleAndAdmin([]byte(`{"role": "`+tt.rawRole+`"}`), nil)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedRole, role)
+			require.Equal(t, tt.expectedGrafanaAdmin, grafanaAdmin)
+		})
+	}
+}
+
+func TestSocialBase_NoneRoleBehavior(t *testing.T) {
+	tests := []struct {
+		name         string
+		behavior     string
+		rawJSON      string
+		expectedRole org.RoleType
+		expectedErr  error
+	}{
+		{name: "should assign the None role by default", rawJSON: `{"role": "None"}`, expectedRole: org.RoleNone},
+		{name: "should assign the None role with assign", behavior: "assign", rawJSON: `{"role": "None"}`, expectedRole: org.RoleNone},
+		{name: "should assign the None role with an invalid behavior", behavior: "reject", rawJSON: `{"role": "None"}`, expectedRole: org.RoleNone},
+		{name: "should deny the None role with deny", behavior: "deny", rawJSON: `{"role": "none"}`, expectedErr: ErrNoneRoleDenied},
+		{name: "should assign other roles with deny", behavior: "Deny", rawJSON: `{"role": "Editor"}`, expectedRole: org.RoleEditor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &OAuthInfo{RoleAttributePath: "role", Extra: map[string]string{"none_role_behavior": tt.behavior}}
+			provider := newSocialBase("none_role_behavior", &oauth2.Config{}, info, string(org.RoleViewer), false, *featuremgmt.WithFeatures())
+
+			role, _, err := provider.extractRoleAndAdmin([]byte(tt.rawJSON), nil)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedRole, role)
+		})
+	}
+}
+
+func TestSocialBase_RoleLevelMapping(t *testing.T) {
+	tests := []struct {
+		name         string
+		rawJSON      string
+		expectedRole org.RoleType
+	}{
+		{name: "should map the lowest level", rawJSON: `{"access_level": 1}`, expectedRole: org.RoleViewer},
+		{name: "should map the highest level", rawJSON: `{"access_level": 3}`, expectedRole: org.RoleAdmin},
+		{name: "should map a value between levels to the lower level", rawJSON: `{"access_level": 2.5}`, expectedRole: org.RoleEditor},
+		{name: "should map a value above all levels to the highest level", rawJSON: `{"access_level": 10}`, expectedRole: org.RoleAdmin},
+		{name: "should map a numeric string", rawJSON: `{"access_level": "2"}`, expectedRole: org.RoleEditor},
+		{name: "should use the default role for a value below all levels", rawJSON: `{"access_level": 0}`, expectedRole: org.RoleViewer},
+		{name: "should use the default role for a non numeric value", rawJSON: `{"access_level": true}`, expectedRole: org.RoleViewer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &OAuthInfo{RoleAttributePath: "access_level", Extra: map[string]string{
+				"role_level_mapping": "3:Admin 1:Viewer 2:Editor 4:Superuser",
+			}}
+			provider := newSocialBase("role_level_mapping", &oauth2.Config{}, info, string(org.RoleViewer), false, *featuremgmt.WithFeatures())
+
+			role, _, err := provider.extractRoleAndAdmin([]byte(tt.rawJSON), nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedRole, role)
+		})
+	}
+
+	t.Run("should ignore numeric values without role_level_mapping", func(t *testing.T) {
+		provider := newSocialBase("role_level_mapping", &oauth2.Config{}, &OAuthInfo{RoleAttributePath: "access_level"}, string(org.RoleEditor), false, *featuremgmt.WithFeatures())
+
+		role, _, err := provider.extractRoleAndAdmin([]byte(`{"access_level": 3}`), nil)
+		require.NoError(t, err)
+		require.Equal(t, org.RoleEditor, role)
+	})
+}
+
+func TestSocialBase_RoleValuesGrafanaAdmin(t *testing.T) {
+	tests := []struct {
+		name                 string
+		extra                map[string]string
+		rawRole              string
+		expectedRole         org.RoleType
+		expectedGrafanaAdmin bool
+	}{
+		{
+			name:                 "should map a listed raw role to Admin and Grafana admin",
+			extra:                map[string]string{"role_values_grafana_admin": "superadmin, root"},
+			rawRole:              "superadmin",
+			expectedRole:         org.RoleAdmin,
+			expectedGrafanaAdmin: true,
+		},
+		{
+			name:                 "should match the raw role case insensitively",
+			extra:                map[string]string{"role_values_grafana_admin": "superadmin"},
+			rawRole:              "SuperAdmin",
+			expectedRole:         org.RoleAdmin,
+			expectedGrafanaAdmin: true,
+		},
+		{
+			name:                 "should use the configured org role",
+			extra:                map[string]string{"role_values_grafana_admin": "superadmin", "role_values_grafana_admin_org_role": "editor"},
+			rawRole:              "superadmin",
+			expectedRole:         org.RoleEditor,
+			expectedGrafanaAdmin: true,
+		},
+		{
+			name:                 "should fall back to Admin on an invalid org role",
+			extra:                map[string]string{"role_values_grafana_admin": "superadmin", "role_values_grafana_admin_org_role": "owner"},
+			rawRole:              "superadmin",
+			expectedRole:         org.RoleAdmin,
+			expectedGrafanaAdmin: true,
+		},
+		{
+			name:         "should not flag other roles",
+			extra:        map[string]string{"role_values_grafana_admin": "superadmin"},
+			rawRole:      "admin",
+			expectedRole: org.RoleAdmin,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &OAuthInfo{RoleAttributePath: "role", AllowAssignGrafanaAdmin: true, Extra: tt.extra}
+			provider := newSocialBase("grafana_admin_roles", &oauth2.Config{}, info, string(org.RoleViewer), false, *featuremgmt.WithFeatures())
+
+			role, grafanaAdmin, err := provider.extractRoleAndAdmin([]byte(`{"role": "`+tt.rawRole+`"}`), nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedRole, role)
+			require.Equal(t, tt.expectedGrafanaAdmin, grafanaAdmin)
+		})
+	}
+
+	t.Run("should not set Grafana admin unless allow_assign_grafana_admin is set", func(t *testing.T) {
+		provider, err := NewGenericOAuthProvider(map[string]any{
+			"role_attribute_path":       "role",
+			"role_values_grafana_admin": "superadmin",
+		}, &setting.Cfg{AutoAssignOrgRole: "Viewer"}, featuremgmt.WithFeatures())
+		require.NoError(t, err)
+
+		token := (&oauth2.Token{}).WithExtra(map[string]any{
+			"id_token": createTestIDToken(t, map[string]any{"email": "jane@grafana.com", "role": "superadmin"}),
+		})
+		userInfo, err := provider.UserInfo(context.Background(), http.DefaultClient, token)
+		require.NoError(t, err)
+		require.Equal(t, org.RoleAdmin, userInfo.Role)
+		require.Nil(t, userInfo.IsGrafanaAdmin)
+	})
+}
+
+func TestSocialBase_RedactedConfig(t *testing.T) {
+	info := &OAuthInfo{
+		ClientId:     "grafana",
+		ClientSecret: "client-secret",
+		TlsClientKey: "/etc/grafana/client.key",
+		AuthUrl:      "https://idp.example.com/authorize",
+		Scopes:       []string{"openid", "email"},
+		Extra: map[string]string{
+			"id_token_decrypt_key": "/etc/grafana/decrypt.pem",
+			"name_attribute_path":  "display_name",
+			"role_policy_api_key":  "",
+		},
+	}
+	provider := newSocialBase("generic_oauth", &oauth2.Config{}, info, string(org.RoleViewer), false, *featuremgmt.WithFeatures())
+
+	config := provider.RedactedConfig()
+	require.Equal(t, "[REDACTED]", config["client_secret"])
+	require.Equal(t, "[REDACTED]", config["tls_client_key"])
+	require.Equal(t, "[REDACTED]", config["id_token_decrypt_key"])
+	require.Equal(t, "", config["role_policy_api_key"], "unset secrets are not masked")
+	require.Equal(t, "", config["tls_client_cert"])
+	require.Equal(t, "grafana", config["client_id"])
+	require.Equal(t, "https://idp.example.com/authorize", config["auth_url"])
+	require.Equal(t, []string{"openid", "email"}, config["scopes"])
+	require.Equal(t, "display_name", config["name_attribute_path"])
+	require.Equal(t, "client-secret", info.ClientSecret, "the settings are not modified")
+}
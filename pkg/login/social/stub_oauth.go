@@ -0,0 +1,138 @@
+package social
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+const (
+	stubProviderName = "stub"
+	// stubAuthCode is the code the stub provider hands back to the login callback instead of an IdP
+	stubAuthCode = "stub"
+)
+
+var _ SocialConnector = (*SocialStub)(nil)
+
+// SocialStub is a no-op provider returning a fixed, config-driven user.
+// It is meant for integration tests and local development without a live IdP:
+// the login redirects straight back to Grafana and the code exchange returns a
+// fixed token, so no auth_url or token_url is needed. It refuses to be created
+// in production unless allow_in_production is set.
+type SocialStub struct {
+	*SocialBase
+	user BasicUserInfo
+}
+
+func NewStubProvider(settings map[string]any, cfg *setting.Cfg, features *featuremgmt.FeatureManager) (*SocialStub, error) {
+	info, err := createOAuthInfoFromKeyValues(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Env == setting.Prod && !mustBool(info.Extra["allow_in_production"], false) {
+		return nil, fmt.Errorf("the %s OAuth provider cannot be enabled in production, set allow_in_production to override", stubProviderName)
+	}
+
+	role := org.RoleType(cases.Title(language.Und).String(strings.TrimSpace(info.Extra["user_role"])))
+	if role != "" && !role.IsValid() {
+		return nil, fmt.Errorf("invalid user_role for the %s OAuth provider: %q", stubProviderName, role)
+	}
+
+	config := createOAuthConfig(info, cfg, stubProviderName)
+	provider := &SocialStub{
+		SocialBase: newSocialBase(stubProviderName, config, info, cfg.AutoAssignOrgRole, cfg.OAuthSkipOrgRoleUpdateSync, *features),
+		user: BasicUserInfo{
+			Id:     info.Extra["user_id"],
+			Name:   info.Extra["user_name"],
+			Email:  info.Extra["user_email"],
+			Login:  info.Extra["user_login"],
+			Role:   role,
+			Groups: util.SplitString(info.Extra["user_groups"]),
+		},
+	}
+
+	if provider.user.Login == "" {
+		provider.user.Login = provider.user.Email
+	}
+
+	if provider.user.Role == "" {
+		provider.user.Role = provider.defaultRole()
+	}
+
+	if provider.allowAssignGrafanaAdmin {
+		grafanaAdmin := mustBool(info.Extra["user_is_grafana_admin"], false)
+		provider.user.IsGrafanaAdmin = &grafanaAdmin
+	}
+
+	return provider, nil
+}
+
+func (s *SocialStub) UserInfo(ctx context.Context, client *http.Client, token *oauth2.Token) (*BasicUserInfo, error) {
+	// copy the configured user so callers can't mutate the provider state
+	userInfo := s.user
+	if s.user.IsGrafanaAdmin != nil {
+		grafanaAdmin := *s.user.IsGrafanaAdmin
+		userInfo.IsGrafanaAdmin = &grafanaAdmin
+	}
+	if len(s.user.Groups) > 0 {
+		userInfo.Groups = make([]string, len(s.user.Groups))
+		copy(userInfo.Groups, s.user.Groups)
+	}
+
+	if !s.isGroupMember(userInfo.Groups) {
+		return nil, errMissingGroupMembership
+	}
+
+	s.setProviderIdentity(&userInfo)
+
+	s.log.Debug("Returning stub user info", "login", userInfo.Login)
+	return &userInfo, nil
+}
+
+// AuthCodeURL returns the login callback of the provider with the fixed code, so that the login completes without
+// an IdP.
+func (s *SocialStub) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	return s.Config.RedirectURL + "?" + url.Values{"code": {stubAuthCode}, "state": {state}}.Encode()
+}
+
+// Exchange returns a fixed token for the code of AuthCodeURL, without contacting token_url.
+func (s *SocialStub) Exchange(_ context.Context, code string, _ ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	if code != stubAuthCode {
+		return nil, fmt.Errorf("invalid code for the %s OAuth provider", stubProviderName)
+	}
+
+	return &oauth2.Token{AccessToken: stubAuthCode, TokenType: "Bearer"}, nil
+}
+
+// TokenSource returns the token as is, since the fixed token never expires.
+func (s *SocialStub) TokenSource(_ context.Context, t *oauth2.Token) oauth2.TokenSource {
+	return oauth2.StaticTokenSource(t)
+}
+
+func (s *SocialStub) GetOAuthInfo() *OAuthInfo {
+	return s.info
+}
+
+func (s *SocialStub) SupportBundleContent(bf *bytes.Buffer) error {
+	bf.WriteString("## Stub specific configuration\n\n")
+	bf.WriteString("```ini\n")
+	bf.WriteString(fmt.Sprintf("user_login = %s\n", s.user.Login))
+	bf.WriteString(fmt.Sprintf("user_role = %s\n", s.user.Role))
+	bf.WriteString(fmt.Sprintf("user_groups = %v\n", s.user.Groups))
+	bf.WriteString("```\n\n")
+
+	return s.SocialBase.SupportBundleContent(bf)
+}
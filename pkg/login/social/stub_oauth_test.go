@@ -0,0 +1,182 @@
+package social
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestSocialStub_UserInfo(t *testing.T) {
+	tests := []struct {
+		name             string
+		settings         map[string]any
+		cfg              *setting.Cfg
+		expectedUserInfo *BasicUserInfo
+		wantErr          bool
+	}{
+		{
+			name: "Should return the configured user",
+			settings: map[string]any{
+				"user_id":     "stub-1",
+				"user_name":   "Stub User",
+				"user_email":  "stub@example.com",
+				"user_login":  "stub",
+				"user_role":   "Editor",
+				"user_groups": "dev, ops",
+			},
+			cfg: &setting.Cfg{Env: setting.Dev},
+			expectedUserInfo: &BasicUserInfo{
+				Id:     "stub-1",
+				Name:   "Stub User",
+				Email:  "stub@example.com",
+				Login:  "stub",
+				Role:   org.RoleEditor,
+				Groups: []string{"dev", "ops"},
+			},
+		},
+		{
+			name: "Should default login to email and role to auto assigned role",
+			settings: map[string]any{
+				"user_email": "stub@example.com",
+			},
+			cfg: &setting.Cfg{Env: setting.Dev, AutoAssignOrgRole: "Viewer"},
+			expectedUserInfo: &BasicUserInfo{
+				Email:  "stub@example.com",
+				Login:  "stub@example.com",
+				Role:   org.RoleViewer,
+				Groups: []string{},
+			},
+		},
+		{
+			name: "Should set grafana admin when allowed",
+			settings: map[string]any{
+				"user_email":                 "stub@example.com",
+				"user_role":                  "Admin",
+				"user_is_grafana_admin":      "true",
+				"allow_assign_grafana_admin": "true",
+			},
+			cfg: &setting.Cfg{Env: setting.Dev},
+			expectedUserInfo: &BasicUserInfo{
+				Email:          "stub@example.com",
+				Login:          "stub@example.com",
+				Role:           org.RoleAdmin,
+				IsGrafanaAdmin: trueBoolPtr(),
+				Groups:         []string{},
+			},
+		},
+		{
+			name: "Should accept a role in any case",
+			settings: map[string]any{
+				"user_email": "stub@example.com",
+				"user_role":  "admin",
+			},
+			cfg: &setting.Cfg{Env: setting.Dev},
+			expectedUserInfo: &BasicUserInfo{
+				Email:  "stub@example.com",
+				Login:  "stub@example.com",
+				Role:   org.RoleAdmin,
+				Groups: []string{},
+			},
+		},
+		{
+			name: "Should allow production when explicitly enabled",
+			settings: map[string]any{
+				"user_email":          "stub@example.com",
+				"user_role":           "Viewer",
+				"allow_in_production": "true",
+			},
+			cfg: &setting.Cfg{Env: setting.Prod},
+			expectedUserInfo: &BasicUserInfo{
+				Email:  "stub@example.com",
+				Login:  "stub@example.com",
+				Role:   org.RoleViewer,
+				Groups: []string{},
+			},
+		},
+		{
+			name: "Should refuse to be created in production by default",
+			settings: map[string]any{
+				"user_email": "stub@example.com",
+			},
+			cfg:     &setting.Cfg{Env: setting.Prod},
+			wantErr: true,
+		},
+		{
+			name: "Should refuse an invalid role",
+			settings: map[string]any{
+				"user_email": "stub@example.com",
+				"user_role":  "Superuser",
+			},
+			cfg:     &setting.Cfg{Env: setting.Dev},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := NewStubProvider(tt.settings, tt.cfg, featuremgmt.WithFeatures())
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := provider.UserInfo(context.Background(), http.DefaultClient, &oauth2.Token{})
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedUserInfo, got)
+
+			// the returned user must not share state with the provider
+			got.Groups = append(got.Groups, "mutated")
+			again, err := provider.UserInfo(context.Background(), http.DefaultClient, &oauth2.Token{})
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedUserInfo, again)
+		})
+	}
+}
+
+func TestSocialStub_LoginFlow(t *testing.T) {
+	idp := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		t.Errorf("unexpected request to the IdP: %s", request.URL.Path)
+		writer.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer idp.Close()
+
+	provider, err := NewStubProvider(map[string]any{
+		"auth_url":   idp.URL + "/auth",
+		"token_url":  idp.URL + "/token",
+		"user_email": "stub@example.com",
+		"user_role":  "Editor",
+	}, &setting.Cfg{Env: setting.Dev, AppURL: "http://grafana.example.com/"}, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	authURL, err := url.Parse(provider.AuthCodeURL("state-1"))
+	require.NoError(t, err)
+	require.Equal(t, "http://grafana.example.com/login/stub", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+	require.Equal(t, "state-1", authURL.Query().Get("state"))
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, idp.Client())
+	token, err := provider.Exchange(ctx, authURL.Query().Get("code"))
+	require.NoError(t, err)
+	require.True(t, token.Valid())
+
+	refreshed, err := provider.TokenSource(ctx, token).Token()
+	require.NoError(t, err)
+	require.Equal(t, token, refreshed)
+
+	got, err := provider.UserInfo(ctx, provider.Client(ctx, token), token)
+	require.NoError(t, err)
+	require.Equal(t, "stub@example.com", got.Email)
+	require.Equal(t, org.RoleEditor, got.Role)
+
+	_, err = provider.Exchange(ctx, "other")
+	require.Error(t, err)
+}
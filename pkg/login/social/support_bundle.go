@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"reflect"
 	"strings"
 
 	"github.com/BurntSushi/toml"
@@ -20,12 +21,12 @@ func (ss *SocialService) registerSupportBundleCollectors(bundleRegistry supportb
 			Description:       "OAuth configuration and healthchecks for " + name,
 			IncludedByDefault: false,
 			Default:           false,
-			Fn:                ss.supportBundleCollectorFn(name, ss.socialMap[name], ss.oAuthProvider[name]),
+			Fn:                ss.supportBundleCollectorFn(name, ss.socialMap[name]),
 		})
 	}
 }
 
-func (ss *SocialService) supportBundleCollectorFn(name string, sc SocialConnector, oinfo *OAuthInfo) func(context.Context) (*supportbundles.SupportItem, error) {
+func (ss *SocialService) supportBundleCollectorFn(name string, sc SocialConnector) func(context.Context) (*supportbundles.SupportItem, error) {
 	return func(ctx context.Context) (*supportbundles.SupportItem, error) {
 		bWriter := bytes.NewBuffer(nil)
 
@@ -38,7 +39,7 @@ func (ss *SocialService) supportBundleCollectorFn(name string, sc SocialConnecto
 		}
 
 		bWriter.WriteString("```toml\n")
-		errM := toml.NewEncoder(bWriter).Encode(oinfo)
+		errM := toml.NewEncoder(bWriter).Encode(sc.RedactedConfig())
 		if errM != nil {
 			bWriter.WriteString(
 				fmt.Sprintf("Unable to encode OAuth configuration  \n Err: %s", errM))
@@ -49,7 +50,7 @@ func (ss *SocialService) supportBundleCollectorFn(name string, sc SocialConnecto
 			return nil, err
 		}
 
-		ss.healthCheckSocialConnector(ctx, name, oinfo, bWriter)
+		ss.healthCheckSocialConnector(ctx, name, ss.GetOAuthInfoProvider(name), bWriter)
 
 		return &supportbundles.SupportItem{
 			Filename:  "oauth-" + name + ".md",
@@ -58,6 +59,45 @@ func (ss *SocialService) supportBundleCollectorFn(name string, sc SocialConnecto
 	}
 }
 
+// redactedSettingSuffixes are the suffixes of the settings masked by RedactedConfig.
+var redactedSettingSuffixes = []string{"_secret", "_password", "_key"}
+
+// RedactedConfig returns the settings of the provider keyed by their name, including the endpoints resolved from the
+// OpenID Connect discovery document. The client secret, TLS client key and other secrets are masked when set, so
+// that the result can be included in support bundles.
+func (s *SocialBase) RedactedConfig() map[string]any {
+	config := make(map[string]any, len(s.info.Extra))
+	for key, value := range s.info.Extra {
+		config[key] = value
+	}
+
+	info := reflect.ValueOf(*s.info)
+	for i := 0; i < info.NumField(); i++ {
+		key, _, _ := strings.Cut(info.Type().Field(i).Tag.Get("mapstructure"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		config[key] = info.Field(i).Interface()
+	}
+
+	for key, value := range config {
+		if !isRedactedSetting(key) || reflect.ValueOf(value).IsZero() {
+			continue
+		}
+		config[key] = "[REDACTED]"
+	}
+	return config
+}
+
+func isRedactedSetting(key string) bool {
+	for _, suffix := range redactedSettingSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
 func (ss *SocialService) healthCheckSocialConnector(ctx context.Context, name string, oinfo *OAuthInfo, bWriter *bytes.Buffer) {
 	bWriter.WriteString("## Health checks\n\n")
 	client, err := ss.GetOAuthHttpClient(name)
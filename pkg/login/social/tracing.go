@@ -0,0 +1,48 @@
+package social
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+const (
+	tracerName = "github.com/grafana/grafana/pkg/login/social"
+
+	attributeKeyProvider = "social.provider"
+	attributeKeyOutcome  = "social.outcome"
+
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+)
+
+// startSpan starts a span for a step of the login with the provider. The tracer is looked up on each call, as
+// the tracing service sets the global tracer provider after the providers are created.
+func (s *SocialBase) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attribute.String(attributeKeyProvider, s.providerName)))
+}
+
+// endSpan records the outcome of the step and ends the span. Only the message ID of err is recorded, as error
+// messages may hold the login or the email of the user.
+func endSpan(span trace.Span, err error) {
+	if err == nil {
+		span.SetAttributes(attribute.String(attributeKeyOutcome, outcomeSuccess))
+		span.End()
+		return
+	}
+
+	description := outcomeFailure
+	var grafanaErr errutil.Error
+	if errors.As(err, &grafanaErr) {
+		description = grafanaErr.MessageID
+	}
+	span.SetAttributes(attribute.String(attributeKeyOutcome, outcomeFailure))
+	span.SetStatus(codes.Error, description)
+	span.End()
+}
@@ -0,0 +1,89 @@
+package social
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/oauth2"
+
+	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestSocialGenericOAuth_Tracing(t *testing.T) {
+	newIdP := func(t *testing.T, userInfoStatus int) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.Header().Set("Content-Type", "application/json")
+			var err error
+			switch request.URL.Path {
+			case "/token":
+				_, err = writer.Write([]byte(`{"access_token": "access-token", "token_type": "bearer"}`))
+			case "/user":
+				writer.WriteHeader(userInfoStatus)
+				_, err = writer.Write([]byte(`{"email": "john.doe@example.com", "role": "Editor"}`))
+			default:
+				writer.WriteHeader(http.StatusNotFound)
+			}
+			require.NoError(t, err)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	login := func(t *testing.T, server *httptest.Server) error {
+		provider, err := NewGenericOAuthProvider(map[string]any{
+			"token_url":           server.URL + "/token",
+			"api_url":             server.URL + "/user",
+			"role_attribute_path": "role",
+		}, setting.NewCfg(), featuremgmt.WithFeatures())
+		require.NoError(t, err)
+
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, server.Client())
+		token, err := provider.Exchange(ctx, "code")
+		require.NoError(t, err)
+		_, err = provider.UserInfo(ctx, server.Client(), token)
+		return err
+	}
+	spanNames := func(spans []sdktrace.ReadOnlySpan) []string {
+		names := make([]string, 0, len(spans))
+		for _, span := range spans {
+			names = append(names, span.Name())
+		}
+		return names
+	}
+
+	t.Run("should trace the steps of the login", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		tracing.InitializeTracerForTest(tracing.WithSpanProcessor(recorder))
+
+		require.NoError(t, login(t, newIdP(t, http.StatusOK)))
+
+		spans := recorder.Ended()
+		require.Equal(t, []string{"social.Exchange", "social.fetchUserInfo", "social.evaluateRole", "social.UserInfo"}, spanNames(spans))
+		for _, span := range spans {
+			require.Contains(t, span.Attributes(), attribute.String(attributeKeyProvider, "generic_oauth"))
+			require.Contains(t, span.Attributes(), attribute.String(attributeKeyOutcome, outcomeSuccess))
+		}
+	})
+
+	t.Run("should record only the message ID of failures", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		tracing.InitializeTracerForTest(tracing.WithSpanProcessor(recorder))
+
+		require.Error(t, login(t, newIdP(t, http.StatusInternalServerError)))
+
+		spans := recorder.Ended()
+		require.Equal(t, []string{"social.Exchange", "social.fetchUserInfo", "social.UserInfo"}, spanNames(spans))
+		for _, span := range spans[1:] {
+			require.Equal(t, sdktrace.Status{Code: codes.Error, Description: "oauth.user_info_fetch_failed"}, span.Status(), "the error message isn't recorded")
+			require.Contains(t, span.Attributes(), attribute.String(attributeKeyOutcome, outcomeFailure))
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package social
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+const (
+	defaultUserInfoBreakerWindow   = time.Minute
+	defaultUserInfoBreakerCooldown = 30 * time.Second
+)
+
+// userInfoBreaker is a circuit breaker around the user info requests of a provider. It opens after threshold
+// consecutive failures within window, after which requests fail fast for cooldown. Once the cooldown is over, a
+// single request is let through to probe the IdP: the breaker closes if it succeeds and opens again otherwise.
+type userInfoBreaker struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu           sync.Mutex
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	open         bool
+	probing      bool
+}
+
+// newUserInfoBreaker returns the breaker configured with userinfo_breaker_failures, userinfo_breaker_window and
+// userinfo_breaker_cooldown, or nil when userinfo_breaker_failures is unset or invalid.
+func newUserInfoBreaker(logger log.Logger, info *OAuthInfo) *userInfoBreaker {
+	value := info.Extra["userinfo_breaker_failures"]
+	if value == "" {
+		return nil
+	}
+
+	threshold, err := strconv.Atoi(value)
+	if err != nil || threshold <= 0 {
+		logger.Warn("Invalid userinfo_breaker_failures, the user info circuit breaker is disabled", "value", value)
+		return nil
+	}
+
+	return &userInfoBreaker{
+		threshold: threshold,
+		window:    parseUserInfoBreakerDuration(logger, "userinfo_breaker_window", info.Extra["userinfo_breaker_window"], defaultUserInfoBreakerWindow),
+		cooldown:  parseUserInfoBreakerDuration(logger, "userinfo_breaker_cooldown", info.Extra["userinfo_breaker_cooldown"], defaultUserInfoBreakerCooldown),
+		now:       time.Now,
+	}
+}
+
+func parseUserInfoBreakerDuration(logger log.Logger, name, value string, defaultValue time.Duration) time.Duration {
+	if value == "" {
+		return defaultValue
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		logger.Warn("Invalid "+name+", using the default", "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// allow returns false while the breaker is open, or when the breaker is half-open and another request is already
+// probing the IdP.
+func (b *userInfoBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return true
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+
+	b.probing = true
+	return true
+}
+
+// record records the outcome of a request let through by allow.
+func (b *userInfoBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if !failed {
+		b.open, b.probing, b.failures = false, false, 0
+		return
+	}
+
+	if b.probing {
+		b.probing = false
+		b.openedAt = now
+		return
+	}
+
+	if b.failures == 0 || now.Sub(b.firstFailure) > b.window {
+		b.failures, b.firstFailure = 0, now
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.open, b.openedAt, b.failures = true, now, 0
+	}
+}
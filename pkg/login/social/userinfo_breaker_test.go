@@ -0,0 +1,145 @@
+package social
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestUserInfoBreaker(t *testing.T) {
+	newBreaker := func() (*userInfoBreaker, *time.Time) {
+		now := time.Now()
+		b := newUserInfoBreaker(log.NewNopLogger(), &OAuthInfo{Extra: map[string]string{
+			"userinfo_breaker_failures": "3",
+			"userinfo_breaker_window":   "1m",
+			"userinfo_breaker_cooldown": "30s",
+		}})
+		b.now = func() time.Time { return now }
+		return b, &now
+	}
+	fail := func(b *userInfoBreaker, n int) {
+		for i := 0; i < n; i++ {
+			require.True(t, b.allow())
+			b.record(true)
+		}
+	}
+
+	t.Run("should be disabled by default", func(t *testing.T) {
+		require.Nil(t, newUserInfoBreaker(log.NewNopLogger(), &OAuthInfo{}))
+		require.Nil(t, newUserInfoBreaker(log.NewNopLogger(), &OAuthInfo{Extra: map[string]string{"userinfo_breaker_failures": "0"}}))
+	})
+
+	t.Run("should use the default window and cooldown", func(t *testing.T) {
+		b := newUserInfoBreaker(log.NewNopLogger(), &OAuthInfo{Extra: map[string]string{"userinfo_breaker_failures": "5"}})
+		require.Equal(t, 5, b.threshold)
+		require.Equal(t, defaultUserInfoBreakerWindow, b.window)
+		require.Equal(t, defaultUserInfoBreakerCooldown, b.cooldown)
+	})
+
+	t.Run("should open after consecutive failures", func(t *testing.T) {
+		b, _ := newBreaker()
+		fail(b, 2)
+		require.True(t, b.allow())
+		b.record(true)
+		require.False(t, b.allow())
+	})
+
+	t.Run("should reset the failures on success", func(t *testing.T) {
+		b, _ := newBreaker()
+		fail(b, 2)
+		require.True(t, b.allow())
+		b.record(false)
+		fail(b, 2)
+		require.True(t, b.allow())
+	})
+
+	t.Run("should not count failures outside the window", func(t *testing.T) {
+		b, now := newBreaker()
+		fail(b, 2)
+		*now = now.Add(2 * time.Minute)
+		fail(b, 2)
+		require.True(t, b.allow())
+	})
+
+	t.Run("should let a single probe through after the cooldown and close on success", func(t *testing.T) {
+		b, now := newBreaker()
+		fail(b, 3)
+		*now = now.Add(29 * time.Second)
+		require.False(t, b.allow())
+
+		*now = now.Add(time.Second)
+		require.True(t, b.allow())
+		require.False(t, b.allow(), "only one request probes the IdP")
+
+		b.record(false)
+		require.True(t, b.allow())
+		require.True(t, b.allow())
+	})
+
+	t.Run("should open again when the probe fails", func(t *testing.T) {
+		b, now := newBreaker()
+		fail(b, 3)
+		*now = now.Add(30 * time.Second)
+		require.True(t, b.allow())
+		b.record(true)
+		require.False(t, b.allow())
+
+		*now = now.Add(30 * time.Second)
+		require.True(t, b.allow())
+	})
+}
+
+func TestSocialOkta_UserInfo_CircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests.Add(1)
+		if !healthy.Load() {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		_, err := writer.Write([]byte(`{ "email": "okta-octopus@grafana.com" }`))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	provider, err := NewOktaProvider(map[string]any{
+		"api_url":                   server.URL + "/user",
+		"userinfo_breaker_failures": "2",
+		"userinfo_breaker_cooldown": "1h",
+	}, setting.NewCfg(), featuremgmt.WithFeatures())
+	require.NoError(t, err)
+	now := time.Now()
+	provider.userInfoBreaker.now = func() time.Time { return now }
+
+	token := (&oauth2.Token{}).WithExtra(map[string]any{"id_token": createTestIDToken(t, map[string]any{"email": "okto.octopus@test.com"})})
+
+	for i := 0; i < 2; i++ {
+		_, err := provider.UserInfo(context.Background(), server.Client(), token)
+		require.ErrorIs(t, err, ErrUserInfoFetch)
+	}
+	require.Equal(t, int32(2), requests.Load())
+
+	_, err = provider.UserInfo(context.Background(), server.Client(), token)
+	require.ErrorIs(t, err, ErrUserInfoCircuitOpen)
+	require.Equal(t, int32(2), requests.Load(), "the IdP is not contacted while the breaker is open")
+
+	healthy.Store(true)
+	now = now.Add(time.Hour)
+	_, err = provider.UserInfo(context.Background(), server.Client(), token)
+	require.NoError(t, err)
+	_, err = provider.UserInfo(context.Background(), server.Client(), token)
+	require.NoError(t, err)
+	require.Equal(t, int32(4), requests.Load())
+}
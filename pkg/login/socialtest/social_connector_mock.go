@@ -41,6 +41,20 @@ func (_m *MockSocialConnector) AuthCodeURL(state string, opts ...oauth2.AuthCode
 	return r0
 }
 
+// CheckHealth provides a mock function with given fields: ctx, client
+func (_m *MockSocialConnector) CheckHealth(ctx context.Context, client *http.Client) error {
+	ret := _m.Called(ctx, client)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *http.Client) error); ok {
+		r0 = rf(ctx, client)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Client provides a mock function with given fields: ctx, t
 func (_m *MockSocialConnector) Client(ctx context.Context, t *oauth2.Token) *http.Client {
 	ret := _m.Called(ctx, t)
@@ -134,6 +148,46 @@ func (_m *MockSocialConnector) IsSignupAllowed() bool {
 	return r0
 }
 
+// PreviewMapping provides a mock function with given fields: ctx, rawJSON
+func (_m *MockSocialConnector) PreviewMapping(ctx context.Context, rawJSON []byte) (social.MappingResult, error) {
+	ret := _m.Called(ctx, rawJSON)
+
+	var r0 social.MappingResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []byte) (social.MappingResult, error)); ok {
+		return rf(ctx, rawJSON)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []byte) social.MappingResult); ok {
+		r0 = rf(ctx, rawJSON)
+	} else {
+		r0 = ret.Get(0).(social.MappingResult)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []byte) error); ok {
+		r1 = rf(ctx, rawJSON)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RedactedConfig provides a mock function with given fields:
+func (_m *MockSocialConnector) RedactedConfig() map[string]any {
+	ret := _m.Called()
+
+	var r0 map[string]any
+	if rf, ok := ret.Get(0).(func() map[string]any); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]any)
+		}
+	}
+
+	return r0
+}
+
 // SupportBundleContent provides a mock function with given fields: _a0
 func (_m *MockSocialConnector) SupportBundleContent(_a0 *bytes.Buffer) error {
 	ret := _m.Called(_a0)
@@ -148,6 +202,32 @@ func (_m *MockSocialConnector) SupportBundleContent(_a0 *bytes.Buffer) error {
 	return r0
 }
 
+// TeamMemberships provides a mock function with given fields: ctx, client, token
+func (_m *MockSocialConnector) TeamMemberships(ctx context.Context, client *http.Client, token *oauth2.Token) ([]string, error) {
+	ret := _m.Called(ctx, client, token)
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *http.Client, *oauth2.Token) ([]string, error)); ok {
+		return rf(ctx, client, token)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *http.Client, *oauth2.Token) []string); ok {
+		r0 = rf(ctx, client, token)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *http.Client, *oauth2.Token) error); ok {
+		r1 = rf(ctx, client, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // TokenSource provides a mock function with given fields: ctx, t
 func (_m *MockSocialConnector) TokenSource(ctx context.Context, t *oauth2.Token) oauth2.TokenSource {
 	ret := _m.Called(ctx, t)
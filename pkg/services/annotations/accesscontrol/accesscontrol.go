@@ -2,15 +2,27 @@ package accesscontrol
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/localcache"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models/roletype"
 	ac "github.com/grafana/grafana/pkg/services/accesscontrol"
 	"github.com/grafana/grafana/pkg/services/annotations"
 	"github.com/grafana/grafana/pkg/services/auth/identity"
+	"github.com/grafana/grafana/pkg/services/dashboards"
 	"github.com/grafana/grafana/pkg/services/dashboards/dashboardaccess"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
-	"github.com/grafana/grafana/pkg/services/sqlstore/permissions"
-	"github.com/grafana/grafana/pkg/services/sqlstore/searchstore"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
 	"github.com/grafana/grafana/pkg/util/errutil"
 )
 
@@ -20,6 +32,16 @@ var (
 		"annotations.accesscontrol.read",
 		errutil.WithPublicMessage("User missing permissions"),
 	)
+	ErrWriteForbidden = errutil.NewBase(
+		errutil.StatusForbidden,
+		"annotations.accesscontrol.write",
+		errutil.WithPublicMessage("User missing permissions"),
+	)
+	ErrDeleteForbidden = errutil.NewBase(
+		errutil.StatusForbidden,
+		"annotations.accesscontrol.delete",
+		errutil.WithPublicMessage("User missing permissions"),
+	)
 	ErrAccessControlInternal = errutil.NewBase(
 		errutil.StatusInternal,
 		"annotations.accesscontrol.internal",
@@ -28,21 +50,144 @@ var (
 )
 
 type AuthService struct {
-	db       db.DB
-	features featuremgmt.FeatureToggles
+	// readBypassRoles are the org roles allowed to read all annotations of their org regardless of dashboard permissions
+	readBypassRoles []roletype.RoleType
+	// grafanaAdminReadAll allows Grafana admins to read all annotations regardless of their org role and dashboard permissions
+	grafanaAdminReadAll bool
+	// dashboardsResolver resolves the dashboards and folders the user has access to
+	dashboardsResolver VisibleDashboardsResolver
+	// accessCache caches the resolved access resources for access_cache_ttl, nil when caching is disabled
+	accessCache *localcache.CacheService
+	// readPermission is the dashboard permission needed to read the annotations of a dashboard
+	readPermission dashboardaccess.PermissionType
+	// anonymousRole is the org role whose permissions apply to reading annotations without a signed in user, empty
+	// when it is forbidden
+	anonymousRole roletype.RoleType
+	// anonymousPermissions loads the permissions of the anonymous role, nil when reading without a signed in user is
+	// forbidden
+	anonymousPermissions ac.Service
+	log                  log.Logger
 }
 
-func NewAuthService(db db.DB, features featuremgmt.FeatureToggles) *AuthService {
-	return &AuthService{
-		db:       db,
+// AuthServiceOption configures an AuthService.
+type AuthServiceOption func(*AuthService)
+
+// WithAnonymousPermissions lets Authorize read annotations without a signed in user with the permissions of the
+// anonymous org role, loaded with service, when anonymous access and [annotations] anonymous_read are enabled.
+func WithAnonymousPermissions(service ac.Service) AuthServiceOption {
+	return func(authz *AuthService) {
+		if authz.anonymousRole != "" {
+			authz.anonymousPermissions = service
+		}
+	}
+}
+
+// NewAuthService returns an AuthService resolving the dashboards and folders the user has access to from the database.
+func NewAuthService(db db.DB, features featuremgmt.FeatureToggles, cfg *setting.Cfg, opts ...AuthServiceOption) *AuthService {
+	return NewAuthServiceWithResolver(cfg, newDashboardSearchResolver(db, features, cfg), opts...)
+}
+
+// NewAuthServiceWithExternalResolver returns an AuthService resolving the dashboards and folders the user has access to
+// with the external resolver, e.g. a client of an external authorization service, when the annotationsExternalAuthz
+// feature toggle is enabled, and from the database otherwise.
+func NewAuthServiceWithExternalResolver(db db.DB, features featuremgmt.FeatureToggles, cfg *setting.Cfg, external VisibleDashboardsResolver, opts ...AuthServiceOption) *AuthService {
+	return NewAuthServiceWithResolver(cfg, &externalAuthzResolver{
 		features: features,
+		external: external,
+		fallback: newDashboardSearchResolver(db, features, cfg),
+	}, opts...)
+}
+
+// NewAuthServiceWithResolver returns an AuthService resolving the dashboards and folders the user has access to
+// with the given resolver, e.g. a cached or remote one.
+func NewAuthServiceWithResolver(cfg *setting.Cfg, resolver VisibleDashboardsResolver, opts ...AuthServiceOption) *AuthService {
+	readBypassRoles := make([]roletype.RoleType, 0, len(cfg.AnnotationReadBypassRoles))
+	for _, role := range cfg.AnnotationReadBypassRoles {
+		readBypassRoles = append(readBypassRoles, roletype.RoleType(role))
+	}
+
+	var accessCache *localcache.CacheService
+	if cfg.AnnotationAccessCacheTTL > 0 {
+		accessCache = localcache.New(cfg.AnnotationAccessCacheTTL, 2*cfg.AnnotationAccessCacheTTL)
+	}
+
+	var anonymousRole roletype.RoleType
+	if cfg.AnonymousEnabled && cfg.AnnotationAnonymousRead {
+		anonymousRole = roletype.RoleType(cfg.AnonymousOrgRole)
+	}
+
+	authz := &AuthService{
+		readBypassRoles:     readBypassRoles,
+		grafanaAdminReadAll: cfg.AnnotationGrafanaAdminReadAll,
+		dashboardsResolver:  resolver,
+		accessCache:         accessCache,
+		readPermission:      readDashboardPermission(cfg.AnnotationReadDashboardPermission),
+		anonymousRole:       anonymousRole,
+		log:                 log.New("annotations.accesscontrol"),
+	}
+	for _, opt := range opts {
+		opt(authz)
+	}
+	return authz
+}
+
+// readDashboardPermission returns the dashboard permission named by read_dashboard_permission, View by default.
+func readDashboardPermission(name string) dashboardaccess.PermissionType {
+	switch name {
+	case dashboardaccess.PERMISSION_EDIT.String():
+		return dashboardaccess.PERMISSION_EDIT
+	case dashboardaccess.PERMISSION_ADMIN.String():
+		return dashboardaccess.PERMISSION_ADMIN
+	default:
+		return dashboardaccess.PERMISSION_VIEW
 	}
 }
 
 // Authorize checks if the user has permission to read annotations, then returns a struct containing dashboards and scope types that the user has access to.
-func (authz *AuthService) Authorize(ctx context.Context, orgID int64, user identity.Requester) (*AccessResources, error) {
+// When dashboardUIDs are given, the dashboards are limited to those of them the user has access to, which avoids scanning all dashboards of the org
+// when the caller already knows the candidate set. Each decision is logged at debug level for auditing.
+func (authz *AuthService) Authorize(ctx context.Context, orgID int64, user identity.Requester, dashboardUIDs ...string) (*AccessResources, error) {
+	resources, err := authz.authorize(ctx, orgID, user, dashboardUIDs)
+	authz.logDecision(orgID, user, resources, err)
+	return resources, err
+}
+
+// logDecision logs the outcome of Authorize. Only the number of visible dashboards and folders is logged, not the
+// resources themselves, since they can be many.
+func (authz *AuthService) logDecision(orgID int64, user identity.Requester, resources *AccessResources, err error) {
+	var userID string
+	if user != nil && !user.IsNil() {
+		namespace, id := user.GetNamespacedID()
+		userID = namespace + ":" + id
+	}
+
+	if err != nil {
+		authz.log.Debug("Annotations read authorization", "orgID", orgID, "userID", userID, "granted", false, "error", err)
+		return
+	}
+
+	scopeTypes := make([]string, 0, len(resources.ScopeTypeSet))
+	for t := range resources.ScopeTypeSet {
+		scopeTypes = append(scopeTypes, t.String())
+	}
+	sort.Strings(scopeTypes)
+
+	authz.log.Debug("Annotations read authorization", "orgID", orgID, "userID", userID, "granted", true,
+		"scopeTypes", scopeTypes, "skipAccessControlFilter", resources.SkipAccessControlFilter,
+		"dashboards", len(resources.Dashboards), "folders", len(resources.Folders))
+}
+
+func (authz *AuthService) authorize(ctx context.Context, orgID int64, user identity.Requester, dashboardUIDs []string) (*AccessResources, error) {
 	if user == nil || user.IsNil() {
-		return nil, ErrReadForbidden.Errorf("missing user")
+		if authz.anonymousPermissions == nil {
+			return nil, ErrReadForbidden.Errorf("missing user")
+		}
+
+		anonymous, err := authz.anonymousRequester(ctx, orgID)
+		if err != nil {
+			return nil, err
+		}
+		user = anonymous
 	}
 
 	scopes, has := user.GetPermissions()[ac.ActionAnnotationsRead]
@@ -50,76 +195,295 @@ func (authz *AuthService) Authorize(ctx context.Context, orgID int64, user ident
 		return nil, ErrReadForbidden.Errorf("user does not have permission to read annotations")
 	}
 
-	scopeTypes := annotationScopeTypes(scopes)
+	if authz.hasReadBypass(orgID, user) {
+		scopeTypes := allAnnotationScopeTypes()
+		return &AccessResources{
+			ScopeTypes:              scopeTypes,
+			ScopeTypeSet:            newScopeTypeSet(scopeTypes),
+			SkipAccessControlFilter: true,
+		}, nil
+	}
 
-	var visibleDashboards map[string]int64
-	var err error
-	if _, ok := scopeTypes[annotations.Dashboard.String()]; ok {
-		visibleDashboards, err = authz.userVisibleDashboards(ctx, user, orgID)
-		if err != nil {
-			return nil, ErrAccessControlInternal.Errorf("failed to fetch dashboards: %w", err)
-		}
+	return authz.accessResources(ctx, orgID, user, scopes, authz.readPermission, dashboardUIDs)
+}
+
+// AuthorizeWithScopes returns the access resources for pre-resolved scopes, for service accounts and background jobs
+// that already loaded their permissions. The annotation scopes select the scope types, like the annotation read scopes
+// of a user in Authorize, the dashboard and folder scopes limit the dashboards, like the dashboard and folder read
+// scopes of a user. The permissions of no user are read, and read_bypass_roles doesn't apply.
+func (authz *AuthService) AuthorizeWithScopes(ctx context.Context, orgID int64, scopes []string) (*AccessResources, error) {
+	if len(scopes) == 0 {
+		return nil, ErrReadForbidden.Errorf("no scopes to read annotations")
 	}
 
-	return &AccessResources{
-		Dashboards: visibleDashboards,
-		ScopeTypes: scopeTypes,
-	}, nil
+	return authz.accessResources(ctx, orgID, newScopesRequester(orgID, scopes), scopes, authz.readPermission, nil)
 }
 
-func (authz *AuthService) userVisibleDashboards(ctx context.Context, user identity.Requester, orgID int64) (map[string]int64, error) {
-	recursiveQueriesSupported, err := authz.db.RecursiveQueriesAreSupported()
+// CanReadDashboardAnnotations returns whether the user can read the annotations of the dashboard, without resolving
+// all the dashboards and folders of Authorize. Users allowed to read all annotations of the org, such as
+// the read_bypass_roles, are allowed without a query, otherwise only the visibility of that dashboard is resolved.
+func (authz *AuthService) CanReadDashboardAnnotations(ctx context.Context, orgID int64, user identity.Requester, dashboardUID string) (bool, error) {
+	if user == nil || user.IsNil() || dashboardUID == "" {
+		return false, nil
+	}
+
+	scopes, has := user.GetPermissions()[ac.ActionAnnotationsRead]
+	if !has {
+		return false, nil
+	}
+
+	if authz.hasReadBypass(orgID, user) {
+		return true, nil
+	}
+
+	if !newScopeTypeSet(annotationScopeTypes(scopes)).Has(annotations.Dashboard) {
+		return false, nil
+	}
+
+	visibleDashboards, _, err := authz.dashboardsResolver.VisibleDashboards(ctx, user, orgID, authz.readPermission, []string{dashboardUID})
 	if err != nil {
-		return nil, err
+		return false, ErrAccessControlInternal.Errorf("failed to fetch dashboards: %w", err)
 	}
 
-	filters := []any{
-		permissions.NewAccessControlDashboardPermissionFilter(user, dashboardaccess.PERMISSION_VIEW, searchstore.TypeDashboard, authz.features, recursiveQueriesSupported),
-		searchstore.OrgFilter{OrgId: orgID},
+	_, visible := visibleDashboards[dashboardUID]
+	return visible, nil
+}
+
+// anonymousRequester returns an anonymous requester of the org with the anonymous role and its permissions, which
+// the dashboard permission filter also looks up by role.
+func (authz *AuthService) anonymousRequester(ctx context.Context, orgID int64) (identity.Requester, error) {
+	anonymous := &user.SignedInUser{OrgID: orgID, OrgRole: authz.anonymousRole, IsAnonymous: true}
+	permissions, err := authz.anonymousPermissions.GetUserPermissions(ctx, anonymous, ac.Options{})
+	if err != nil {
+		return nil, ErrAccessControlInternal.Errorf("failed to load the permissions of the anonymous role: %w", err)
 	}
 
-	sb := &searchstore.Builder{Dialect: authz.db.GetDialect(), Filters: filters, Features: authz.features}
+	anonymous.Permissions = map[int64]map[string][]string{orgID: ac.GroupScopesByAction(permissions)}
+	return anonymous, nil
+}
 
-	visibleDashboards := make(map[string]int64)
+// scopesRequester is the requester of pre-resolved scopes. No roles hold its permissions, so the dashboard
+// permission filter matches them as they are instead of looking them up in the database.
+type scopesRequester struct {
+	*user.SignedInUser
+}
 
-	var page int64 = 1
-	var limit int64 = 1000
-	for {
-		var res []dashboardProjection
-		sql, params := sb.ToSQL(limit, page)
+// newScopesRequester returns a requester holding the scopes for reading annotations, dashboards and folders.
+func newScopesRequester(orgID int64, scopes []string) *scopesRequester {
+	return &scopesRequester{&user.SignedInUser{
+		OrgID: orgID,
+		Permissions: map[int64]map[string][]string{orgID: {
+			ac.ActionAnnotationsRead:        scopes,
+			dashboards.ActionDashboardsRead: scopes,
+			dashboards.ActionFoldersRead:    scopes,
+		}},
+	}}
+}
 
-		err = authz.db.WithDbSession(ctx, func(sess *db.Session) error {
-			return sess.SQL(sql, params...).Find(&res)
-		})
+// AuthorizeBatch checks if each of the users has permission to read annotations like Authorize, and returns their
+// access resources keyed by the namespaced ID of the user (e.g. user:1 or service-account:1). Setup shared by the users, such as whether the database supports recursive
+// queries, is resolved once for the batch. Errors of individual users are joined into the returned error instead
+// of aborting the batch, so the access resources of the other users are still returned.
+func (authz *AuthService) AuthorizeBatch(ctx context.Context, orgID int64, users []identity.Requester) (map[string]*AccessResources, error) {
+	batch := authz
+	if b, ok := authz.dashboardsResolver.(batchResolver); ok {
+		resolver, err := b.batch()
 		if err != nil {
-			return nil, err
+			return nil, ErrAccessControlInternal.Errorf("failed to prepare the batch: %w", err)
 		}
+		batchAuthz := *authz
+		batchAuthz.dashboardsResolver = resolver
+		batch = &batchAuthz
+	}
 
-		for _, p := range res {
-			visibleDashboards[p.UID] = p.ID
+	resources := make(map[string]*AccessResources, len(users))
+	var errs []error
+	for i, user := range users {
+		if user == nil || user.IsNil() {
+			errs = append(errs, ErrReadForbidden.Errorf("missing user at index %d", i))
+			continue
 		}
 
-		// if the result is less than the limit, we have reached the end
-		if len(res) < int(limit) {
-			break
+		namespace, id := user.GetNamespacedID()
+		if _, err := identity.IntIdentifier(namespace, id); err != nil {
+			errs = append(errs, ErrReadForbidden.Errorf("invalid user at index %d: %w", i, err))
+			continue
 		}
+		userID := namespace + ":" + id
 
-		page++
+		res, err := batch.Authorize(ctx, orgID, user)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", userID, err))
+			continue
+		}
+		resources[userID] = res
 	}
 
-	return visibleDashboards, nil
+	return resources, errors.Join(errs...)
 }
 
-func annotationScopeTypes(scopes []string) map[any]struct{} {
-	allScopeTypes := map[any]struct{}{
-		annotations.Dashboard.String():    {},
-		annotations.Organization.String(): {},
+// AuthorizeWrite checks if the user has permission to update annotations, then returns a struct containing dashboards and scope types that the user
+// may modify annotations of.
+func (authz *AuthService) AuthorizeWrite(ctx context.Context, orgID int64, user identity.Requester, dashboardUIDs ...string) (*AccessResources, error) {
+	if user == nil || user.IsNil() {
+		return nil, ErrWriteForbidden.Errorf("missing user")
+	}
+
+	scopes, has := user.GetPermissions()[ac.ActionAnnotationsWrite]
+	if !has {
+		return nil, ErrWriteForbidden.Errorf("user does not have permission to write annotations")
+	}
+
+	return authz.accessResources(ctx, orgID, user, scopes, dashboardaccess.PERMISSION_EDIT, dashboardUIDs)
+}
+
+// AuthorizeDelete checks if the user has permission to delete annotations, then returns a struct containing dashboards and scope types that the user
+// may delete annotations of.
+func (authz *AuthService) AuthorizeDelete(ctx context.Context, orgID int64, user identity.Requester, dashboardUIDs ...string) (*AccessResources, error) {
+	if user == nil || user.IsNil() {
+		return nil, ErrDeleteForbidden.Errorf("missing user")
+	}
+
+	scopes, has := user.GetPermissions()[ac.ActionAnnotationsDelete]
+	if !has {
+		return nil, ErrDeleteForbidden.Errorf("user does not have permission to delete annotations")
+	}
+
+	return authz.accessResources(ctx, orgID, user, scopes, dashboardaccess.PERMISSION_EDIT, dashboardUIDs)
+}
+
+// accessResources resolves the scope types of the annotation scopes and, for the dashboard scope type, the dashboards
+// and folders the user has the given permission on. Nothing is resolved when the user only has the organization scope.
+// The result is cached when access_cache_ttl is set and must not be modified.
+func (authz *AuthService) accessResources(ctx context.Context, orgID int64, user identity.Requester, scopes []string, permission dashboardaccess.PermissionType, dashboardUIDs []string) (*AccessResources, error) {
+	scopeTypes := annotationScopeTypes(scopes)
+	scopeTypeSet := newScopeTypeSet(scopeTypes)
+	if !scopeTypeSet.Has(annotations.Dashboard) {
+		return &AccessResources{
+			ScopeTypes:   scopeTypes,
+			ScopeTypeSet: scopeTypeSet,
+		}, nil
+	}
+
+	if authz.accessCache == nil {
+		return authz.resolveAccessResources(ctx, orgID, user, scopeTypes, scopeTypeSet, permission, dashboardUIDs)
+	}
+
+	key := accessCacheKey(orgID, user, scopes, permission, dashboardUIDs)
+	if cached, ok := authz.accessCache.Get(key); ok {
+		return cached.(*AccessResources), nil
+	}
+
+	resources, err := authz.resolveAccessResources(ctx, orgID, user, scopeTypes, scopeTypeSet, permission, dashboardUIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	authz.accessCache.SetDefault(key, resources)
+	return resources, nil
+}
+
+// resolveAccessResources resolves the dashboards and folders the user has the given permission on.
+func (authz *AuthService) resolveAccessResources(ctx context.Context, orgID int64, user identity.Requester, scopeTypes map[any]struct{}, scopeTypeSet ScopeTypeSet, permission dashboardaccess.PermissionType, dashboardUIDs []string) (*AccessResources, error) {
+	visibleDashboards, recursiveQueriesUsed, err := authz.dashboardsResolver.VisibleDashboards(ctx, user, orgID, permission, dashboardUIDs)
+	if err != nil {
+		return nil, ErrAccessControlInternal.Errorf("failed to fetch dashboards: %w", err)
+	}
+
+	// the dashboards of the prefilter already include those visible through their folders
+	var visibleFolders map[string]int64
+	if len(dashboardUIDs) == 0 {
+		visibleFolders, err = authz.dashboardsResolver.VisibleFolders(ctx, user, orgID, permission)
+		if err != nil {
+			return nil, ErrAccessControlInternal.Errorf("failed to fetch folders: %w", err)
+		}
 	}
 
-	types, hasWildcardScope := ac.ParseScopes(ac.ScopeAnnotationsProvider.GetResourceScopeType(""), scopes)
+	return &AccessResources{
+		Dashboards:           visibleDashboards,
+		Folders:              visibleFolders,
+		ScopeTypes:           scopeTypes,
+		ScopeTypeSet:         scopeTypeSet,
+		RecursiveQueriesUsed: recursiveQueriesUsed,
+	}, nil
+}
+
+// accessCacheKey identifies the access resources of a user by the hash of their permissions, so that a change of
+// permissions resolves them again. The evaluated scopes are part of the key, as writes and deletes are resolved with
+// the same permission but from the scopes of different actions.
+func accessCacheKey(orgID int64, user identity.Requester, scopes []string, permission dashboardaccess.PermissionType, dashboardUIDs []string) string {
+	h := sha256.New()
+
+	evaluated := slices.Clone(scopes)
+	sort.Strings(evaluated)
+	for _, scope := range evaluated {
+		h.Write([]byte(strconv.Quote(scope)))
+		h.Write([]byte(","))
+	}
+	h.Write([]byte("|"))
+
+	permissions := user.GetPermissions()
+	actions := make([]string, 0, len(permissions))
+	for action := range permissions {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		scopes := slices.Clone(permissions[action])
+		sort.Strings(scopes)
+		h.Write([]byte(strconv.Quote(action)))
+		h.Write([]byte("="))
+		for _, scope := range scopes {
+			h.Write([]byte(strconv.Quote(scope)))
+			h.Write([]byte(","))
+		}
+		h.Write([]byte(";"))
+	}
+
+	uids := slices.Clone(dashboardUIDs)
+	sort.Strings(uids)
+
+	namespace, id := user.GetNamespacedID()
+	return fmt.Sprintf("%d:%s:%s:%d:%s:%s", orgID, namespace, id, permission, strings.Join(uids, ","), hex.EncodeToString(h.Sum(nil)))
+}
+
+// hasReadBypass returns true if the user's role in the org allows reading all annotations of the org, or if the
+// user is a Grafana admin and grafana_admin_read_all is set.
+func (authz *AuthService) hasReadBypass(orgID int64, user identity.Requester) bool {
+	if authz.grafanaAdminReadAll && user.GetIsGrafanaAdmin() {
+		return true
+	}
+
+	if len(authz.readBypassRoles) == 0 || user.GetOrgID() != orgID {
+		return false
+	}
+
+	return slices.Contains(authz.readBypassRoles, user.GetOrgRole())
+}
+
+// allAnnotationScopeTypes returns the registered scope types granted by a wildcard annotation scope.
+func allAnnotationScopeTypes() map[any]struct{} {
+	types := map[any]struct{}{}
+	for _, handler := range registeredScopeTypes() {
+		if handler.GrantedByWildcard {
+			types[handler.Name] = struct{}{}
+		}
+	}
+	return types
+}
+
+// annotationScopeTypes returns the registered scope types of the annotation scopes. Unregistered scope types are ignored.
+func annotationScopeTypes(scopes []string) map[any]struct{} {
+	parsed, hasWildcardScope := ac.ParseScopes(ac.ScopeAnnotationsProvider.GetResourceScopeType(""), scopes)
 	if hasWildcardScope {
-		types = allScopeTypes
+		return allAnnotationScopeTypes()
 	}
 
+	types := map[any]struct{}{}
+	for _, handler := range registeredScopeTypes() {
+		if _, ok := parsed[handler.Name]; ok {
+			types[handler.Name] = struct{}{}
+		}
+	}
 	return types
 }
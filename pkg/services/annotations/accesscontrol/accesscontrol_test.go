@@ -2,17 +2,29 @@ package accesscontrol
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana/pkg/components/simplejson"
 	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/log/logtest"
+	"github.com/grafana/grafana/pkg/infra/metrics"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/actest"
 	"github.com/grafana/grafana/pkg/services/annotations"
 	"github.com/grafana/grafana/pkg/services/annotations/testutil"
+	"github.com/grafana/grafana/pkg/services/auth/identity"
 	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/dashboards/dashboardaccess"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
 )
 
@@ -28,7 +40,7 @@ func TestIntegrationAuthorize(t *testing.T) {
 
 	sql := db.InitTestDB(t)
 
-	authz := NewAuthService(sql, featuremgmt.WithFeatures())
+	authz := NewAuthService(sql, featuremgmt.WithFeatures(), setting.NewCfg())
 
 	dash1 := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
 		UserID: 1,
@@ -55,6 +67,7 @@ func TestIntegrationAuthorize(t *testing.T) {
 	type testCase struct {
 		name              string
 		permissions       map[string][]string
+		dashboardUIDs     []string
 		expectedResources *AccessResources
 		expectedErr       error
 	}
@@ -104,6 +117,30 @@ func TestIntegrationAuthorize(t *testing.T) {
 				ScopeTypes: map[any]struct{}{dashScopeType: {}},
 			},
 		},
+		{
+			name: "should limit dashboards to the candidate dashboards",
+			permissions: map[string][]string{
+				accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+				dashboards.ActionDashboardsRead:     {dashboards.ScopeDashboardsAll},
+			},
+			dashboardUIDs: []string{dash2.UID},
+			expectedResources: &AccessResources{
+				Dashboards: map[string]int64{dash2.UID: dash2.ID},
+				ScopeTypes: map[any]struct{}{dashScopeType: {}},
+			},
+		},
+		{
+			name: "should intersect the candidate dashboards with the dashboards the user can read",
+			permissions: map[string][]string{
+				accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+				dashboards.ActionDashboardsRead:     {fmt.Sprintf("dashboards:uid:%s", dash1.UID)},
+			},
+			dashboardUIDs: []string{dash2.UID},
+			expectedResources: &AccessResources{
+				Dashboards: map[string]int64{},
+				ScopeTypes: map[any]struct{}{dashScopeType: {}},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -111,7 +148,7 @@ func TestIntegrationAuthorize(t *testing.T) {
 			u.Permissions = map[int64]map[string][]string{1: tc.permissions}
 			testutil.SetupRBACPermission(t, sql, role, u)
 
-			resources, err := authz.Authorize(context.Background(), 1, u)
+			resources, err := authz.Authorize(context.Background(), 1, u, tc.dashboardUIDs...)
 			require.NoError(t, err)
 
 			if tc.expectedResources.Dashboards != nil {
@@ -120,6 +157,7 @@ func TestIntegrationAuthorize(t *testing.T) {
 
 			if tc.expectedResources.ScopeTypes != nil {
 				require.Equal(t, tc.expectedResources.ScopeTypes, resources.ScopeTypes)
+				require.Equal(t, newScopeTypeSet(tc.expectedResources.ScopeTypes), resources.ScopeTypeSet)
 			}
 
 			if tc.expectedErr != nil {
@@ -128,3 +166,1352 @@ func TestIntegrationAuthorize(t *testing.T) {
 		})
 	}
 }
+
+func TestIntegrationAuthorizeWithScopes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	sql := db.InitTestDB(t)
+
+	authz := NewAuthService(sql, featuremgmt.WithFeatures(), setting.NewCfg())
+
+	dash1 := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard 1",
+		}),
+	})
+
+	dash2 := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard 2",
+		}),
+	})
+
+	testCases := []struct {
+		name               string
+		scopes             []string
+		expectedDashboards map[string]int64
+		expectedScopeTypes map[any]struct{}
+		expectedErr        error
+	}{
+		{
+			name:               "should have all dashboards for the dashboard scope and all dashboards",
+			scopes:             []string{accesscontrol.ScopeAnnotationsTypeDashboard, dashboards.ScopeDashboardsAll},
+			expectedDashboards: map[string]int64{dash1.UID: dash1.ID, dash2.UID: dash2.ID},
+			expectedScopeTypes: map[any]struct{}{dashScopeType: {}},
+		},
+		{
+			name:               "should have only the dashboards of the scopes",
+			scopes:             []string{accesscontrol.ScopeAnnotationsTypeDashboard, dashboards.ScopeDashboardsProvider.GetResourceScopeUID(dash1.UID)},
+			expectedDashboards: map[string]int64{dash1.UID: dash1.ID},
+			expectedScopeTypes: map[any]struct{}{dashScopeType: {}},
+		},
+		{
+			name:               "should have no dashboards without dashboard scopes",
+			scopes:             []string{accesscontrol.ScopeAnnotationsTypeDashboard},
+			expectedDashboards: map[string]int64{},
+			expectedScopeTypes: map[any]struct{}{dashScopeType: {}},
+		},
+		{
+			name:               "should have only organization scope and no dashboards",
+			scopes:             []string{accesscontrol.ScopeAnnotationsTypeOrganization, dashboards.ScopeDashboardsAll},
+			expectedScopeTypes: map[any]struct{}{orgScopeType: {}},
+		},
+		{
+			name:        "should fail without scopes",
+			expectedErr: ErrReadForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resources, err := authz.AuthorizeWithScopes(context.Background(), 1, tc.scopes)
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedDashboards, resources.Dashboards)
+			require.Equal(t, tc.expectedScopeTypes, resources.ScopeTypes)
+			require.Equal(t, newScopeTypeSet(tc.expectedScopeTypes), resources.ScopeTypeSet)
+		})
+	}
+}
+
+func TestIntegrationAuthorize_ReadBypassRoles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	sql := db.InitTestDB(t)
+
+	cfg := setting.NewCfg()
+	cfg.AnnotationReadBypassRoles = []string{string(org.RoleAdmin)}
+	authz := NewAuthService(sql, featuremgmt.WithFeatures(), cfg)
+
+	dash1 := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard 1",
+		}),
+	})
+
+	testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard 2",
+		}),
+	})
+
+	u := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+			dashboards.ActionDashboardsRead:     {fmt.Sprintf("dashboards:uid:%s", dash1.UID)},
+		}},
+	}
+	role := testutil.SetupRBACRole(t, sql, u)
+	testutil.SetupRBACPermission(t, sql, role, u)
+
+	t.Run("should skip dashboard resolution for a bypass role", func(t *testing.T) {
+		u.OrgRole = org.RoleAdmin
+
+		resources, err := authz.Authorize(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.True(t, resources.SkipAccessControlFilter)
+		require.Nil(t, resources.Dashboards)
+		require.Equal(t, map[any]struct{}{dashScopeType: {}, orgScopeType: {}}, resources.ScopeTypes)
+	})
+
+	t.Run("should scan dashboards for a role without bypass", func(t *testing.T) {
+		u.OrgRole = org.RoleEditor
+
+		resources, err := authz.Authorize(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.False(t, resources.SkipAccessControlFilter)
+		require.Equal(t, map[string]int64{dash1.UID: dash1.ID}, resources.Dashboards)
+		require.Equal(t, map[any]struct{}{dashScopeType: {}}, resources.ScopeTypes)
+	})
+}
+
+func TestIntegrationAuthorize_DashboardPageSize(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	sql := db.InitTestDB(t)
+
+	expectedDashboards := map[string]int64{}
+	for i := 1; i <= 3; i++ {
+		dash := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+			UserID: 1,
+			OrgID:  1,
+			Dashboard: simplejson.NewFromAny(map[string]any{
+				"title": fmt.Sprintf("Dashboard %d", i),
+			}),
+		})
+		expectedDashboards[dash.UID] = dash.ID
+	}
+
+	u := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+			dashboards.ActionDashboardsRead:     {dashboards.ScopeDashboardsAll},
+		}},
+	}
+	role := testutil.SetupRBACRole(t, sql, u)
+	testutil.SetupRBACPermission(t, sql, role, u)
+
+	testCases := []struct {
+		name        string
+		pageSize    int64
+		concurrency int
+	}{
+		{name: "should fetch all dashboards over several pages", pageSize: 2},
+		{name: "should fetch all dashboards when the last page is full", pageSize: 1},
+		{name: "should fall back to the default page size for a zero page size", pageSize: 0},
+		{name: "should fall back to the default page size for a negative page size", pageSize: -1},
+		{name: "should fetch all dashboards over several concurrent pages", pageSize: 1, concurrency: 2},
+		{name: "should fetch all dashboards when the concurrency exceeds the number of pages", pageSize: 1, concurrency: 10},
+		{name: "should fetch all dashboards sequentially for a negative concurrency", pageSize: 1, concurrency: -1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := setting.NewCfg()
+			cfg.AnnotationDashboardPageSize = tc.pageSize
+			cfg.AnnotationDashboardConcurrency = tc.concurrency
+			authz := NewAuthService(sql, featuremgmt.WithFeatures(), cfg)
+
+			resources, err := authz.Authorize(context.Background(), 1, u)
+			require.NoError(t, err)
+			require.Equal(t, expectedDashboards, resources.Dashboards)
+		})
+	}
+}
+
+func TestIntegrationAuthorize_DashboardTargets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	sql := db.InitTestDB(t)
+
+	uids := make([]string, 0, 3)
+	for i := 1; i <= 3; i++ {
+		dash := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+			UserID: 1,
+			OrgID:  1,
+			Dashboard: simplejson.NewFromAny(map[string]any{
+				"title": fmt.Sprintf("Dashboard %d", i),
+			}),
+		})
+		uids = append(uids, dash.UID)
+	}
+
+	u := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+			dashboards.ActionDashboardsRead:     {dashboards.ScopeDashboardsAll},
+		}},
+	}
+	role := testutil.SetupRBACRole(t, sql, u)
+	testutil.SetupRBACPermission(t, sql, role, u)
+
+	testCases := []struct {
+		name          string
+		targets       []string
+		expectedPages int
+	}{
+		{name: "should stop paging once the target is found", targets: uids[:1], expectedPages: 1},
+		{name: "should stop paging once all the targets are found", targets: uids[:2], expectedPages: 2},
+		{name: "should fetch all the pages when a target is not visible", targets: []string{uids[0], "missing"}, expectedPages: 2},
+		{name: "should fetch all the pages without targets", expectedPages: 4},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sessions := 0
+			cfg := setting.NewCfg()
+			cfg.AnnotationDashboardPageSize = 1
+			resolver := newDashboardSearchResolver(countingDB{DB: sql, sessions: &sessions}, featuremgmt.WithFeatures(), cfg)
+
+			visible, _, err := resolver.VisibleDashboards(context.Background(), u, 1, dashboardaccess.PERMISSION_VIEW, tc.targets)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedPages, sessions)
+			for _, uid := range tc.targets {
+				if uid != "missing" {
+					require.Contains(t, visible, uid)
+				}
+			}
+		})
+	}
+}
+
+// cancelingDB counts the database sessions and cancels the request context after the first one.
+type cancelingDB struct {
+	db.DB
+	cancel   context.CancelFunc
+	sessions *int
+}
+
+func (d cancelingDB) WithDbSession(ctx context.Context, callback sqlstore.DBTransactionFunc) error {
+	*d.sessions++
+	defer d.cancel()
+	return d.DB.WithDbSession(ctx, callback)
+}
+
+func TestIntegrationAuthorize_Cancellation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	sql := db.InitTestDB(t)
+
+	for i := 1; i <= 3; i++ {
+		testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+			UserID: 1,
+			OrgID:  1,
+			Dashboard: simplejson.NewFromAny(map[string]any{
+				"title": fmt.Sprintf("Dashboard %d", i),
+			}),
+		})
+	}
+
+	u := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+			dashboards.ActionDashboardsRead:     {dashboards.ScopeDashboardsAll},
+		}},
+	}
+	role := testutil.SetupRBACRole(t, sql, u)
+	testutil.SetupRBACPermission(t, sql, role, u)
+
+	t.Run("should stop paging when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sessions := 0
+		cfg := setting.NewCfg()
+		cfg.AnnotationDashboardPageSize = 1
+		authz := NewAuthService(cancelingDB{DB: sql, cancel: cancel, sessions: &sessions}, featuremgmt.WithFeatures(), cfg)
+
+		_, err := authz.Authorize(ctx, 1, u)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, 1, sessions)
+	})
+
+	t.Run("should fail when a page query exceeds the query timeout", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.AnnotationDashboardQueryTimeout = time.Nanosecond
+		authz := NewAuthService(slowDB{DB: sql, delay: time.Millisecond}, featuremgmt.WithFeatures(), cfg)
+
+		_, err := authz.Authorize(context.Background(), 1, u)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+// slowDB delays every database session, to simulate the latency of a remote database.
+type slowDB struct {
+	db.DB
+	delay time.Duration
+}
+
+func (d slowDB) WithDbSession(ctx context.Context, callback sqlstore.DBTransactionFunc) error {
+	time.Sleep(d.delay)
+	return d.DB.WithDbSession(ctx, callback)
+}
+
+func BenchmarkAuthorize_DashboardConcurrency(b *testing.B) {
+	sql := db.InitTestDB(b)
+
+	for i := 1; i <= 100; i++ {
+		testutil.CreateDashboard(b, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+			UserID: 1,
+			OrgID:  1,
+			Dashboard: simplejson.NewFromAny(map[string]any{
+				"title": fmt.Sprintf("Dashboard %d", i),
+			}),
+		})
+	}
+
+	u := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+			dashboards.ActionDashboardsRead:     {dashboards.ScopeDashboardsAll},
+		}},
+	}
+
+	for _, concurrency := range []int{1, 4, 10} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			cfg := setting.NewCfg()
+			cfg.AnnotationDashboardPageSize = 10
+			cfg.AnnotationDashboardConcurrency = concurrency
+			authz := NewAuthService(slowDB{DB: sql, delay: time.Millisecond}, featuremgmt.WithFeatures(), cfg)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				resources, err := authz.Authorize(context.Background(), 1, u)
+				require.NoError(b, err)
+				require.Len(b, resources.Dashboards, 100)
+			}
+		})
+	}
+}
+
+func TestIntegrationAuthorize_Folders(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	sql := db.InitTestDB(t)
+
+	authz := NewAuthService(sql, featuremgmt.WithFeatures(), setting.NewCfg())
+
+	folder1 := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID:   1,
+		OrgID:    1,
+		IsFolder: true,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Folder 1",
+		}),
+	})
+
+	folder2 := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID:   1,
+		OrgID:    1,
+		IsFolder: true,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Folder 2",
+		}),
+	})
+
+	testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard 1",
+		}),
+	})
+
+	u := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+	}
+	role := testutil.SetupRBACRole(t, sql, u)
+
+	testCases := []struct {
+		name               string
+		permissions        map[string][]string
+		expectedDashboards map[string]int64
+		expectedFolders    map[string]int64
+	}{
+		{
+			name: "should include the folders of a user with folder-only view permission",
+			permissions: map[string][]string{
+				accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+				dashboards.ActionFoldersRead:        {dashboards.ScopeFoldersProvider.GetResourceScopeUID(folder1.UID)},
+			},
+			expectedDashboards: map[string]int64{},
+			expectedFolders:    map[string]int64{folder1.UID: folder1.ID},
+		},
+		{
+			name: "should include all folders the user can view",
+			permissions: map[string][]string{
+				accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+				dashboards.ActionFoldersRead:        {dashboards.ScopeFoldersAll},
+			},
+			expectedDashboards: map[string]int64{},
+			expectedFolders:    map[string]int64{folder1.UID: folder1.ID, folder2.UID: folder2.ID},
+		},
+		{
+			name: "should not include folders without folder permissions",
+			permissions: map[string][]string{
+				accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+			},
+			expectedDashboards: map[string]int64{},
+			expectedFolders:    map[string]int64{},
+		},
+		{
+			name: "should not resolve folders without the dashboard scope type",
+			permissions: map[string][]string{
+				accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeOrganization},
+				dashboards.ActionFoldersRead:        {dashboards.ScopeFoldersAll},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u.Permissions = map[int64]map[string][]string{1: tc.permissions}
+			testutil.SetupRBACPermission(t, sql, role, u)
+
+			resources, err := authz.Authorize(context.Background(), 1, u)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedDashboards, resources.Dashboards)
+			require.Equal(t, tc.expectedFolders, resources.Folders)
+		})
+	}
+}
+
+// recursiveQueriesDB overrides whether the database supports recursive queries.
+type recursiveQueriesDB struct {
+	db.DB
+	supported bool
+}
+
+func (d recursiveQueriesDB) RecursiveQueriesAreSupported() (bool, error) {
+	return d.supported, nil
+}
+
+func TestIntegrationAuthorize_RecursiveQueriesUsed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	sql := db.InitTestDB(t)
+
+	dash1 := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard 1",
+		}),
+	})
+
+	u := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsAll},
+			dashboards.ActionDashboardsRead:     {dashboards.ScopeDashboardsAll},
+		}},
+	}
+	role := testutil.SetupRBACRole(t, sql, u)
+	testutil.SetupRBACPermission(t, sql, role, u)
+
+	testCases := []struct {
+		name      string
+		supported bool
+	}{
+		{name: "should report recursive queries as used when the database supports them", supported: true},
+		{name: "should report recursive queries as not used when the database doesn't support them", supported: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			authz := NewAuthService(recursiveQueriesDB{DB: sql, supported: tc.supported}, featuremgmt.WithFeatures(), setting.NewCfg())
+
+			resources, err := authz.Authorize(context.Background(), 1, u)
+			require.NoError(t, err)
+			require.Equal(t, tc.supported, resources.RecursiveQueriesUsed)
+			require.Equal(t, map[string]int64{dash1.UID: dash1.ID}, resources.Dashboards)
+		})
+	}
+}
+
+func TestIntegrationAuthorize_ExternalResolver(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	sql := db.InitTestDB(t)
+
+	dash1 := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard 1",
+		}),
+	})
+
+	u := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsAll},
+			dashboards.ActionDashboardsRead:     {dashboards.ScopeDashboardsAll},
+		}},
+	}
+	role := testutil.SetupRBACRole(t, sql, u)
+	testutil.SetupRBACPermission(t, sql, role, u)
+
+	t.Run("should resolve with the external resolver when the feature toggle is enabled", func(t *testing.T) {
+		external := &fakeResolver{dashboards: map[string]int64{"external": 10}, folders: map[string]int64{"folder": 11}}
+		features := featuremgmt.WithFeatures(featuremgmt.FlagAnnotationsExternalAuthz)
+		authz := NewAuthServiceWithExternalResolver(sql, features, setting.NewCfg(), external)
+
+		resources, err := authz.Authorize(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.Equal(t, map[string]int64{"external": 10}, resources.Dashboards)
+		require.Equal(t, map[string]int64{"folder": 11}, resources.Folders)
+		require.Equal(t, 2, external.calls)
+	})
+
+	t.Run("should resolve from the database when the feature toggle is disabled", func(t *testing.T) {
+		external := &fakeResolver{dashboards: map[string]int64{"external": 10}}
+		authz := NewAuthServiceWithExternalResolver(sql, featuremgmt.WithFeatures(), setting.NewCfg(), external)
+
+		resources, err := authz.Authorize(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.Equal(t, map[string]int64{dash1.UID: dash1.ID}, resources.Dashboards)
+		require.Zero(t, external.calls)
+
+		batch, err := authz.AuthorizeBatch(context.Background(), 1, []identity.Requester{u})
+		require.NoError(t, err)
+		require.Equal(t, map[string]int64{dash1.UID: dash1.ID}, batch["user:1"].Dashboards)
+		require.Zero(t, external.calls)
+	})
+}
+
+func TestIntegrationAuthorizeWriteAndDelete(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	sql := db.InitTestDB(t)
+
+	authz := NewAuthService(sql, featuremgmt.WithFeatures(), setting.NewCfg())
+
+	dash1 := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard 1",
+		}),
+	})
+
+	dash2 := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard 2",
+		}),
+	})
+
+	u := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+	}
+	role := testutil.SetupRBACRole(t, sql, u)
+
+	type authorizeFunc func(ctx context.Context, orgID int64, user identity.Requester, dashboardUIDs ...string) (*AccessResources, error)
+
+	testCases := []struct {
+		name              string
+		authorize         authorizeFunc
+		permissions       map[string][]string
+		expectedResources *AccessResources
+		expectedErr       error
+	}{
+		{
+			name:      "write should only include dashboards the user can edit",
+			authorize: authz.AuthorizeWrite,
+			permissions: map[string][]string{
+				accesscontrol.ActionAnnotationsWrite: {accesscontrol.ScopeAnnotationsTypeDashboard},
+				dashboards.ActionDashboardsRead:      {dashboards.ScopeDashboardsAll},
+				dashboards.ActionDashboardsWrite:     {fmt.Sprintf("dashboards:uid:%s", dash1.UID)},
+			},
+			expectedResources: &AccessResources{
+				Dashboards: map[string]int64{dash1.UID: dash1.ID},
+				ScopeTypes: map[any]struct{}{dashScopeType: {}},
+			},
+		},
+		{
+			name:      "delete should include all dashboards the user can edit",
+			authorize: authz.AuthorizeDelete,
+			permissions: map[string][]string{
+				accesscontrol.ActionAnnotationsDelete: {accesscontrol.ScopeAnnotationsAll},
+				dashboards.ActionDashboardsRead:       {dashboards.ScopeDashboardsAll},
+				dashboards.ActionDashboardsWrite:      {dashboards.ScopeDashboardsAll},
+			},
+			expectedResources: &AccessResources{
+				Dashboards: map[string]int64{dash1.UID: dash1.ID, dash2.UID: dash2.ID},
+				ScopeTypes: map[any]struct{}{dashScopeType: {}, orgScopeType: {}},
+			},
+		},
+		{
+			name:      "write should fail without the annotation write action",
+			authorize: authz.AuthorizeWrite,
+			permissions: map[string][]string{
+				accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsAll},
+			},
+			expectedErr: ErrWriteForbidden,
+		},
+		{
+			name:      "delete should fail without the annotation delete action",
+			authorize: authz.AuthorizeDelete,
+			permissions: map[string][]string{
+				accesscontrol.ActionAnnotationsWrite: {accesscontrol.ScopeAnnotationsAll},
+			},
+			expectedErr: ErrDeleteForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u.Permissions = map[int64]map[string][]string{1: tc.permissions}
+			testutil.SetupRBACPermission(t, sql, role, u)
+
+			resources, err := tc.authorize(context.Background(), 1, u)
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedResources.Dashboards, resources.Dashboards)
+			require.Equal(t, tc.expectedResources.ScopeTypes, resources.ScopeTypes)
+		})
+	}
+}
+
+type fakeResolver struct {
+	dashboards    map[string]int64
+	folders       map[string]int64
+	err           error
+	dashboardUIDs []string
+	permission    dashboardaccess.PermissionType
+	user          identity.Requester
+	calls         int
+}
+
+func (r *fakeResolver) VisibleDashboards(_ context.Context, user identity.Requester, _ int64, permission dashboardaccess.PermissionType, dashboardUIDs []string) (map[string]int64, bool, error) {
+	r.calls++
+	r.user = user
+	r.permission = permission
+	r.dashboardUIDs = dashboardUIDs
+	return r.dashboards, true, r.err
+}
+
+func (r *fakeResolver) VisibleFolders(_ context.Context, _ identity.Requester, _ int64, _ dashboardaccess.PermissionType) (map[string]int64, error) {
+	r.calls++
+	return r.folders, nil
+}
+
+func TestAuthorize_Resolver(t *testing.T) {
+	u := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead:  {accesscontrol.ScopeAnnotationsTypeDashboard},
+			accesscontrol.ActionAnnotationsWrite: {accesscontrol.ScopeAnnotationsTypeDashboard},
+		}},
+	}
+
+	t.Run("should use the dashboards and folders of the resolver", func(t *testing.T) {
+		resolver := &fakeResolver{dashboards: map[string]int64{"dash1": 1}, folders: map[string]int64{"folder1": 2}}
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), resolver)
+
+		res, err := authz.Authorize(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.Equal(t, map[string]int64{"dash1": 1}, res.Dashboards)
+		require.Equal(t, map[string]int64{"folder1": 2}, res.Folders)
+		require.True(t, res.RecursiveQueriesUsed)
+		require.Equal(t, dashboardaccess.PERMISSION_VIEW, resolver.permission)
+	})
+
+	t.Run("should not resolve the folders when the dashboards are prefiltered", func(t *testing.T) {
+		resolver := &fakeResolver{dashboards: map[string]int64{"dash1": 1}, folders: map[string]int64{"folder1": 2}}
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), resolver)
+
+		res, err := authz.Authorize(context.Background(), 1, u, "dash1", "dash2")
+		require.NoError(t, err)
+		require.Equal(t, map[string]int64{"dash1": 1}, res.Dashboards)
+		require.Empty(t, res.Folders)
+		require.Equal(t, []string{"dash1", "dash2"}, resolver.dashboardUIDs)
+		require.Equal(t, 1, resolver.calls)
+	})
+
+	t.Run("should resolve the dashboards with the configured read permission", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.AnnotationReadDashboardPermission = "Edit"
+		resolver := &fakeResolver{}
+		authz := NewAuthServiceWithResolver(cfg, resolver)
+
+		_, err := authz.Authorize(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.Equal(t, dashboardaccess.PERMISSION_EDIT, resolver.permission)
+
+		_, err = authz.AuthorizeWithScopes(context.Background(), 1, []string{accesscontrol.ScopeAnnotationsTypeDashboard})
+		require.NoError(t, err)
+		require.Equal(t, dashboardaccess.PERMISSION_EDIT, resolver.permission)
+	})
+
+	t.Run("should resolve the dashboards with the edit permission for writes", func(t *testing.T) {
+		resolver := &fakeResolver{}
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), resolver)
+
+		_, err := authz.AuthorizeWrite(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.Equal(t, dashboardaccess.PERMISSION_EDIT, resolver.permission)
+	})
+
+	t.Run("should not resolve dashboards for a user with only the organization scope", func(t *testing.T) {
+		orgUser := &user.SignedInUser{
+			UserID: 1,
+			OrgID:  1,
+			Permissions: map[int64]map[string][]string{1: {
+				accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeOrganization},
+			}},
+		}
+		resolver := &fakeResolver{dashboards: map[string]int64{"dash1": 1}}
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), resolver)
+
+		res, err := authz.Authorize(context.Background(), 1, orgUser)
+		require.NoError(t, err)
+		require.Zero(t, resolver.calls)
+		require.Empty(t, res.Dashboards)
+		require.Equal(t, map[any]struct{}{orgScopeType: {}}, res.ScopeTypes)
+		require.True(t, res.ScopeTypeSet.Has(annotations.Organization))
+		require.False(t, res.ScopeTypeSet.Has(annotations.Dashboard))
+	})
+
+	t.Run("should fail when the resolver fails", func(t *testing.T) {
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), &fakeResolver{err: errors.New("unavailable")})
+
+		_, err := authz.Authorize(context.Background(), 1, u)
+		require.ErrorIs(t, err, ErrAccessControlInternal)
+	})
+}
+
+func TestAuthorize_GrafanaAdminReadAll(t *testing.T) {
+	u := &user.SignedInUser{
+		UserID:         1,
+		OrgID:          1,
+		OrgRole:        org.RoleViewer,
+		IsGrafanaAdmin: true,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead:  {accesscontrol.ScopeAnnotationsTypeDashboard},
+			accesscontrol.ActionAnnotationsWrite: {accesscontrol.ScopeAnnotationsTypeDashboard},
+		}},
+	}
+
+	t.Run("should give a Grafana admin unrestricted read access", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.AnnotationGrafanaAdminReadAll = true
+		resolver := &fakeResolver{dashboards: map[string]int64{"dash1": 1}}
+		authz := NewAuthServiceWithResolver(cfg, resolver)
+
+		res, err := authz.Authorize(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.True(t, res.SkipAccessControlFilter)
+		require.Nil(t, res.Dashboards)
+		require.Equal(t, map[any]struct{}{dashScopeType: {}, orgScopeType: {}}, res.ScopeTypes)
+		require.Zero(t, resolver.calls)
+
+		res, err = authz.AuthorizeWrite(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.False(t, res.SkipAccessControlFilter, "writes are not affected")
+		require.Equal(t, map[string]int64{"dash1": 1}, res.Dashboards)
+	})
+
+	t.Run("should restrict a Grafana admin by default", func(t *testing.T) {
+		resolver := &fakeResolver{dashboards: map[string]int64{"dash1": 1}}
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), resolver)
+
+		res, err := authz.Authorize(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.False(t, res.SkipAccessControlFilter)
+		require.Equal(t, map[string]int64{"dash1": 1}, res.Dashboards)
+	})
+}
+
+func TestCanReadDashboardAnnotations(t *testing.T) {
+	newUser := func(role org.RoleType, scopes ...string) *user.SignedInUser {
+		return &user.SignedInUser{
+			UserID:  1,
+			OrgID:   1,
+			OrgRole: role,
+			Permissions: map[int64]map[string][]string{1: {
+				accesscontrol.ActionAnnotationsRead: scopes,
+			}},
+		}
+	}
+
+	t.Run("should allow a user reading all annotations of the org without resolving the dashboard", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.AnnotationReadBypassRoles = []string{string(org.RoleAdmin)}
+		resolver := &fakeResolver{}
+		authz := NewAuthServiceWithResolver(cfg, resolver)
+
+		allowed, err := authz.CanReadDashboardAnnotations(context.Background(), 1, newUser(org.RoleAdmin, accesscontrol.ScopeAnnotationsTypeOrganization), "dash1")
+		require.NoError(t, err)
+		require.True(t, allowed)
+		require.Zero(t, resolver.calls)
+	})
+
+	t.Run("should allow a user with the dashboard scope on a visible dashboard", func(t *testing.T) {
+		resolver := &fakeResolver{dashboards: map[string]int64{"dash1": 1}}
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), resolver)
+
+		allowed, err := authz.CanReadDashboardAnnotations(context.Background(), 1, newUser(org.RoleViewer, accesscontrol.ScopeAnnotationsTypeDashboard), "dash1")
+		require.NoError(t, err)
+		require.True(t, allowed)
+		require.Equal(t, 1, resolver.calls)
+		require.Equal(t, []string{"dash1"}, resolver.dashboardUIDs)
+	})
+
+	t.Run("should deny a dashboard the user can't see", func(t *testing.T) {
+		resolver := &fakeResolver{}
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), resolver)
+
+		allowed, err := authz.CanReadDashboardAnnotations(context.Background(), 1, newUser(org.RoleViewer, accesscontrol.ScopeAnnotationsAll), "dash1")
+		require.NoError(t, err)
+		require.False(t, allowed)
+	})
+
+	t.Run("should deny a user without the dashboard scope without resolving the dashboard", func(t *testing.T) {
+		resolver := &fakeResolver{dashboards: map[string]int64{"dash1": 1}}
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), resolver)
+
+		allowed, err := authz.CanReadDashboardAnnotations(context.Background(), 1, newUser(org.RoleViewer, accesscontrol.ScopeAnnotationsTypeOrganization), "dash1")
+		require.NoError(t, err)
+		require.False(t, allowed)
+		require.Zero(t, resolver.calls)
+
+		allowed, err = authz.CanReadDashboardAnnotations(context.Background(), 1, &user.SignedInUser{UserID: 1, OrgID: 1}, "dash1")
+		require.NoError(t, err)
+		require.False(t, allowed)
+	})
+
+	t.Run("should return the resolver errors", func(t *testing.T) {
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), &fakeResolver{err: errors.New("unavailable")})
+
+		_, err := authz.CanReadDashboardAnnotations(context.Background(), 1, newUser(org.RoleViewer, accesscontrol.ScopeAnnotationsTypeDashboard), "dash1")
+		require.ErrorIs(t, err, ErrAccessControlInternal)
+	})
+}
+
+func TestAuthorize_AnonymousRead(t *testing.T) {
+	anonymousPermissions := &actest.FakeService{ExpectedPermissions: []accesscontrol.Permission{
+		{Action: accesscontrol.ActionAnnotationsRead, Scope: accesscontrol.ScopeAnnotationsTypeDashboard},
+		{Action: accesscontrol.ActionAnnotationsRead, Scope: accesscontrol.ScopeAnnotationsTypeOrganization},
+		{Action: dashboards.ActionDashboardsRead, Scope: dashboards.ScopeDashboardsAll},
+	}}
+
+	t.Run("should forbid reading without a signed in user by default", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.AnonymousEnabled = true
+		authz := NewAuthServiceWithResolver(cfg, &fakeResolver{}, WithAnonymousPermissions(anonymousPermissions))
+
+		_, err := authz.Authorize(context.Background(), 1, nil)
+		require.ErrorIs(t, err, ErrReadForbidden)
+	})
+
+	t.Run("should read with the permissions of the anonymous role without a signed in user", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.AnonymousEnabled = true
+		cfg.AnonymousOrgRole = string(org.RoleViewer)
+		cfg.AnnotationAnonymousRead = true
+		resolver := &fakeResolver{dashboards: map[string]int64{"dash1": 1}}
+		authz := NewAuthServiceWithResolver(cfg, resolver, WithAnonymousPermissions(anonymousPermissions))
+
+		res, err := authz.Authorize(context.Background(), 1, nil)
+		require.NoError(t, err)
+		require.Equal(t, map[string]int64{"dash1": 1}, res.Dashboards)
+		require.True(t, res.ScopeTypeSet.Has(annotations.Dashboard))
+		require.True(t, res.ScopeTypeSet.Has(annotations.Organization))
+		require.Equal(t, dashboardaccess.PERMISSION_VIEW, resolver.permission)
+
+		namespace, _ := resolver.user.GetNamespacedID()
+		require.Equal(t, identity.NamespaceAnonymous, namespace)
+		require.Equal(t, org.RoleViewer, resolver.user.GetOrgRole())
+		require.Equal(t, []string{dashboards.ScopeDashboardsAll}, resolver.user.GetPermissions()[dashboards.ActionDashboardsRead])
+	})
+
+	t.Run("should forbid reading when the anonymous role cannot read annotations", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.AnonymousEnabled = true
+		cfg.AnonymousOrgRole = string(org.RoleViewer)
+		cfg.AnnotationAnonymousRead = true
+		authz := NewAuthServiceWithResolver(cfg, &fakeResolver{}, WithAnonymousPermissions(&actest.FakeService{}))
+
+		_, err := authz.Authorize(context.Background(), 1, nil)
+		require.ErrorIs(t, err, ErrReadForbidden)
+	})
+
+	t.Run("should return the errors loading the permissions of the anonymous role", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.AnonymousEnabled = true
+		cfg.AnonymousOrgRole = string(org.RoleViewer)
+		cfg.AnnotationAnonymousRead = true
+		authz := NewAuthServiceWithResolver(cfg, &fakeResolver{}, WithAnonymousPermissions(&actest.FakeService{ExpectedErr: errors.New("unavailable")}))
+
+		_, err := authz.Authorize(context.Background(), 1, nil)
+		require.ErrorIs(t, err, ErrAccessControlInternal)
+	})
+
+	t.Run("should forbid reading when anonymous access is disabled", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.AnnotationAnonymousRead = true
+		authz := NewAuthServiceWithResolver(cfg, &fakeResolver{}, WithAnonymousPermissions(anonymousPermissions))
+
+		_, err := authz.Authorize(context.Background(), 1, nil)
+		require.ErrorIs(t, err, ErrReadForbidden)
+	})
+}
+
+func TestAuthorize_Log(t *testing.T) {
+	u := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard, accesscontrol.ScopeAnnotationsTypeOrganization},
+		}},
+	}
+
+	t.Run("should log the granted scope types and the number of visible dashboards", func(t *testing.T) {
+		logger := &logtest.Fake{}
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), &fakeResolver{dashboards: map[string]int64{"dash1": 1, "dash2": 2}, folders: map[string]int64{"folder1": 3}})
+		authz.log = logger
+
+		_, err := authz.Authorize(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.Equal(t, 1, logger.DebugLogs.Calls)
+		require.Equal(t, "Annotations read authorization", logger.DebugLogs.Message)
+		require.Equal(t, []any{
+			"orgID", int64(1), "userID", "user:1", "granted", true,
+			"scopeTypes", []string{dashScopeType, orgScopeType}, "skipAccessControlFilter", false,
+			"dashboards", 2, "folders", 1,
+		}, logger.DebugLogs.Ctx)
+	})
+
+	t.Run("should log denials", func(t *testing.T) {
+		logger := &logtest.Fake{}
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), &fakeResolver{})
+		authz.log = logger
+
+		_, err := authz.Authorize(context.Background(), 1, &user.SignedInUser{UserID: 2, OrgID: 1})
+		require.ErrorIs(t, err, ErrReadForbidden)
+		require.Equal(t, 1, logger.DebugLogs.Calls)
+		require.Equal(t, []any{"orgID", int64(1), "userID", "user:2", "granted", false, "error", err}, logger.DebugLogs.Ctx)
+	})
+}
+
+// countingDB counts the database sessions.
+type countingDB struct {
+	db.DB
+	sessions *int
+}
+
+func (d countingDB) WithDbSession(ctx context.Context, callback sqlstore.DBTransactionFunc) error {
+	*d.sessions++
+	return d.DB.WithDbSession(ctx, callback)
+}
+
+func TestIntegrationAuthorize_AccessCache(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	sql := db.InitTestDB(t)
+
+	dash := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard 1",
+		}),
+	})
+
+	newUser := func() *user.SignedInUser {
+		return &user.SignedInUser{
+			UserID: 1,
+			OrgID:  1,
+			Permissions: map[int64]map[string][]string{1: {
+				accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+				dashboards.ActionDashboardsRead:     {dashboards.ScopeDashboardsAll},
+			}},
+		}
+	}
+	u := newUser()
+	role := testutil.SetupRBACRole(t, sql, u)
+	testutil.SetupRBACPermission(t, sql, role, u)
+
+	t.Run("should query the database once within the ttl", func(t *testing.T) {
+		sessions := 0
+		cfg := setting.NewCfg()
+		cfg.AnnotationAccessCacheTTL = time.Minute
+		authz := NewAuthService(countingDB{DB: sql, sessions: &sessions}, featuremgmt.WithFeatures(), cfg)
+
+		first, err := authz.Authorize(context.Background(), 1, newUser())
+		require.NoError(t, err)
+		require.Equal(t, map[string]int64{dash.UID: dash.ID}, first.Dashboards)
+		queried := sessions
+
+		second, err := authz.Authorize(context.Background(), 1, newUser())
+		require.NoError(t, err)
+		require.Equal(t, first, second)
+		require.Equal(t, queried, sessions)
+	})
+
+	t.Run("should query the database again when the permissions change", func(t *testing.T) {
+		sessions := 0
+		cfg := setting.NewCfg()
+		cfg.AnnotationAccessCacheTTL = time.Minute
+		authz := NewAuthService(countingDB{DB: sql, sessions: &sessions}, featuremgmt.WithFeatures(), cfg)
+
+		_, err := authz.Authorize(context.Background(), 1, newUser())
+		require.NoError(t, err)
+		queried := sessions
+
+		changed := newUser()
+		changed.Permissions[1][accesscontrol.ActionAnnotationsWrite] = []string{accesscontrol.ScopeAnnotationsTypeDashboard}
+		_, err = authz.Authorize(context.Background(), 1, changed)
+		require.NoError(t, err)
+		require.Greater(t, sessions, queried)
+	})
+
+	t.Run("should query the database on every call without a ttl", func(t *testing.T) {
+		sessions := 0
+		authz := NewAuthService(countingDB{DB: sql, sessions: &sessions}, featuremgmt.WithFeatures(), setting.NewCfg())
+
+		_, err := authz.Authorize(context.Background(), 1, newUser())
+		require.NoError(t, err)
+		queried := sessions
+
+		_, err = authz.Authorize(context.Background(), 1, newUser())
+		require.NoError(t, err)
+		require.Greater(t, sessions, queried)
+	})
+}
+
+func TestAuthorize_AccessCacheActions(t *testing.T) {
+	newAuthz := func() (*AuthService, *fakeResolver) {
+		cfg := setting.NewCfg()
+		cfg.AnnotationAccessCacheTTL = time.Minute
+		resolver := &fakeResolver{dashboards: map[string]int64{"dash1": 1}}
+		return NewAuthServiceWithResolver(cfg, resolver), resolver
+	}
+
+	t.Run("should not reuse the write resources for a delete", func(t *testing.T) {
+		u := &user.SignedInUser{
+			UserID: 1,
+			OrgID:  1,
+			Permissions: map[int64]map[string][]string{1: {
+				accesscontrol.ActionAnnotationsWrite:  {accesscontrol.ScopeAnnotationsTypeDashboard, accesscontrol.ScopeAnnotationsTypeOrganization},
+				accesscontrol.ActionAnnotationsDelete: {accesscontrol.ScopeAnnotationsTypeDashboard},
+			}},
+		}
+		authz, _ := newAuthz()
+
+		write, err := authz.AuthorizeWrite(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.True(t, write.ScopeTypeSet.Has(annotations.Organization))
+
+		del, err := authz.AuthorizeDelete(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.True(t, del.ScopeTypeSet.Has(annotations.Dashboard))
+		require.False(t, del.ScopeTypeSet.Has(annotations.Organization))
+		require.Equal(t, map[string]int64{"dash1": 1}, del.Dashboards)
+	})
+
+	t.Run("should deny a delete after a cached write for a user who can only write", func(t *testing.T) {
+		u := &user.SignedInUser{
+			UserID: 1,
+			OrgID:  1,
+			Permissions: map[int64]map[string][]string{1: {
+				accesscontrol.ActionAnnotationsWrite: {accesscontrol.ScopeAnnotationsTypeDashboard},
+			}},
+		}
+		authz, resolver := newAuthz()
+
+		_, err := authz.AuthorizeWrite(context.Background(), 1, u)
+		require.NoError(t, err)
+		calls := resolver.calls
+
+		_, err = authz.AuthorizeWrite(context.Background(), 1, u)
+		require.NoError(t, err)
+		require.Equal(t, calls, resolver.calls, "the write resources are cached")
+
+		_, err = authz.AuthorizeDelete(context.Background(), 1, u)
+		require.ErrorIs(t, err, ErrDeleteForbidden)
+	})
+}
+
+// recursiveLookupsDB counts the lookups of whether the database supports recursive queries.
+type recursiveLookupsDB struct {
+	db.DB
+	lookups *int
+}
+
+func (d recursiveLookupsDB) RecursiveQueriesAreSupported() (bool, error) {
+	*d.lookups++
+	return d.DB.RecursiveQueriesAreSupported()
+}
+
+func TestIntegrationAuthorizeBatch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	sql := db.InitTestDB(t)
+
+	dash := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard 1",
+		}),
+	})
+
+	dashboardUser := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+			dashboards.ActionDashboardsRead:     {dashboards.ScopeDashboardsAll},
+		}},
+	}
+	role := testutil.SetupRBACRole(t, sql, dashboardUser)
+	testutil.SetupRBACPermission(t, sql, role, dashboardUser)
+
+	orgUser := &user.SignedInUser{
+		UserID: 2,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeOrganization},
+		}},
+	}
+	forbiddenUser := &user.SignedInUser{
+		UserID:      3,
+		OrgID:       1,
+		Permissions: map[int64]map[string][]string{1: {}},
+	}
+	secondDashboardUser := &user.SignedInUser{
+		UserID: 4,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+		}},
+	}
+
+	serviceAccount := &user.SignedInUser{
+		UserID:           1,
+		OrgID:            1,
+		IsServiceAccount: true,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeOrganization},
+		}},
+	}
+
+	lookups := 0
+	authz := NewAuthService(recursiveLookupsDB{DB: sql, lookups: &lookups}, featuremgmt.WithFeatures(), setting.NewCfg())
+
+	resources, err := authz.AuthorizeBatch(context.Background(), 1, []identity.Requester{dashboardUser, orgUser, forbiddenUser, secondDashboardUser, nil, serviceAccount})
+	require.ErrorIs(t, err, ErrReadForbidden)
+	require.ErrorContains(t, err, "user:3:")
+	require.ErrorContains(t, err, "missing user at index 4")
+	require.Equal(t, 1, lookups)
+
+	require.Len(t, resources, 4)
+	require.Equal(t, map[string]int64{dash.UID: dash.ID}, resources["user:1"].Dashboards)
+	require.Equal(t, map[any]struct{}{dashScopeType: {}}, resources["user:1"].ScopeTypes)
+	require.Empty(t, resources["user:2"].Dashboards)
+	require.Equal(t, map[any]struct{}{orgScopeType: {}}, resources["user:2"].ScopeTypes)
+	require.NotContains(t, resources, "user:3")
+	require.Empty(t, resources["user:4"].Dashboards)
+	require.Empty(t, resources["service-account:1"].Dashboards)
+	require.Equal(t, map[any]struct{}{orgScopeType: {}}, resources["service-account:1"].ScopeTypes)
+}
+
+func TestAuthorizeBatch_Resolver(t *testing.T) {
+	u := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+		}},
+	}
+
+	t.Run("should use a resolver without batch support for every user", func(t *testing.T) {
+		resolver := &fakeResolver{dashboards: map[string]int64{"dash1": 1}}
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), resolver)
+
+		resources, err := authz.AuthorizeBatch(context.Background(), 1, []identity.Requester{u})
+		require.NoError(t, err)
+		require.Equal(t, map[string]int64{"dash1": 1}, resources["user:1"].Dashboards)
+		require.Equal(t, 2, resolver.calls)
+	})
+
+	t.Run("should collect the resolver errors of each user", func(t *testing.T) {
+		authz := NewAuthServiceWithResolver(setting.NewCfg(), &fakeResolver{err: errors.New("unavailable")})
+
+		resources, err := authz.AuthorizeBatch(context.Background(), 1, []identity.Requester{u})
+		require.ErrorIs(t, err, ErrAccessControlInternal)
+		require.Empty(t, resources)
+	})
+}
+
+func TestIntegrationAuthorize_Metrics(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	sql := db.InitTestDB(t)
+
+	for i := 1; i <= 3; i++ {
+		testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+			UserID: 1,
+			OrgID:  1,
+			Dashboard: simplejson.NewFromAny(map[string]any{
+				"title": fmt.Sprintf("Dashboard %d", i),
+			}),
+		})
+	}
+
+	u := &user.SignedInUser{
+		UserID: 1,
+		OrgID:  1,
+		Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionAnnotationsRead: {accesscontrol.ScopeAnnotationsTypeDashboard},
+			dashboards.ActionDashboardsRead:     {dashboards.ScopeDashboardsAll},
+		}},
+	}
+	role := testutil.SetupRBACRole(t, sql, u)
+	testutil.SetupRBACPermission(t, sql, role, u)
+
+	cfg := setting.NewCfg()
+	cfg.AnnotationDashboardPageSize = 1
+	authz := NewAuthService(sql, featuremgmt.WithFeatures(), cfg)
+
+	pagesCount, pagesSum := histogramSample(t, metrics.MAnnotationsAuthzPages, "1")
+	dashboardsCount, dashboardsSum := histogramSample(t, metrics.MAnnotationsAuthzDashboards, "1")
+	durationCount, _ := histogramSample(t, metrics.MAnnotationsAuthzDuration, "1")
+
+	_, err := authz.Authorize(context.Background(), 1, u)
+	require.NoError(t, err)
+
+	// three full pages of one dashboard and the empty page ending the search
+	count, sum := histogramSample(t, metrics.MAnnotationsAuthzPages, "1")
+	require.Equal(t, pagesCount+1, count)
+	require.Equal(t, pagesSum+4, sum)
+
+	count, sum = histogramSample(t, metrics.MAnnotationsAuthzDashboards, "1")
+	require.Equal(t, dashboardsCount+1, count)
+	require.Equal(t, dashboardsSum+3, sum)
+
+	count, _ = histogramSample(t, metrics.MAnnotationsAuthzDuration, "1")
+	require.Equal(t, durationCount+1, count)
+
+	// a full page and the short page ending the search, not the later pages of the batch
+	cfg.AnnotationDashboardPageSize = 2
+	cfg.AnnotationDashboardConcurrency = 4
+	authz = NewAuthService(sql, featuremgmt.WithFeatures(), cfg)
+
+	pagesCount, pagesSum = histogramSample(t, metrics.MAnnotationsAuthzPages, "1")
+
+	resources, err := authz.Authorize(context.Background(), 1, u)
+	require.NoError(t, err)
+	require.Len(t, resources.Dashboards, 3)
+
+	count, sum = histogramSample(t, metrics.MAnnotationsAuthzPages, "1")
+	require.Equal(t, pagesCount+1, count)
+	require.Equal(t, pagesSum+2, sum)
+}
+
+func histogramSample(t *testing.T, vec *prometheus.HistogramVec, org string) (uint64, float64) {
+	t.Helper()
+
+	m := &dto.Metric{}
+	require.NoError(t, vec.WithLabelValues(org).(prometheus.Metric).Write(m))
+	return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+}
+
+func TestOrgBucket(t *testing.T) {
+	tests := []struct {
+		orgID    int64
+		expected string
+	}{
+		{orgID: 0, expected: "0"},
+		{orgID: 1, expected: "1"},
+		{orgID: 2, expected: "2-9"},
+		{orgID: 9, expected: "2-9"},
+		{orgID: 10, expected: "10-99"},
+		{orgID: 999, expected: "100-999"},
+		{orgID: 1000, expected: "1000-9999"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			require.Equal(t, tt.expected, orgBucket(tt.orgID))
+		})
+	}
+}
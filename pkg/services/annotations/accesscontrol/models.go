@@ -1,11 +1,140 @@
 package accesscontrol
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/annotations"
+)
+
+// maxLoggedDashboards is the number of dashboards or folders above which AccessResources.String only renders their count.
+const maxLoggedDashboards = 50
+
 // AccessResources contains resources that are used to filter annotations based on RBAC.
 type AccessResources struct {
 	// Dashboards is a map of dashboard UIDs to IDs
 	Dashboards map[string]int64
-	// ScopeTypes contains the scope types that the user has access to. At most `dashboard` and `organization`
+	// Folders is a map of folder UIDs to IDs, for matching annotations of dashboards within folders the user has access to
+	Folders map[string]int64
+	// ScopeTypes contains the scope types that the user has access to, among the ones registered with RegisterScopeType
 	ScopeTypes map[any]struct{}
+	// ScopeTypeSet contains the same scope types as ScopeTypes, keyed by annotation type.
+	// ScopeTypes is kept until all callers use ScopeTypeSet.
+	ScopeTypeSet ScopeTypeSet
+	// RecursiveQueriesUsed is true when Dashboards was resolved with recursive queries, so that permissions inherited
+	// from nested folders are taken into account. It is false when the database doesn't support them.
+	RecursiveQueriesUsed bool
+	// SkipAccessControlFilter is true when the user can read all annotations of the org, in which case Dashboards is not resolved
+	SkipAccessControlFilter bool
+}
+
+// CacheKey returns a stable hash of the access resources, suitable for keying caches of query results.
+// The key does not depend on map iteration order and changes whenever the visible resources change.
+func (r *AccessResources) CacheKey() string {
+	h := sha256.New()
+
+	h.Write([]byte("dashboards:"))
+	for _, uid := range sortedUIDs(r.Dashboards) {
+		h.Write([]byte(strconv.Quote(uid)))
+		h.Write([]byte("="))
+		h.Write([]byte(strconv.FormatInt(r.Dashboards[uid], 10)))
+		h.Write([]byte(","))
+	}
+
+	h.Write([]byte(";folders:"))
+	for _, uid := range sortedUIDs(r.Folders) {
+		h.Write([]byte(strconv.Quote(uid)))
+		h.Write([]byte("="))
+		h.Write([]byte(strconv.FormatInt(r.Folders[uid], 10)))
+		h.Write([]byte(","))
+	}
+
+	h.Write([]byte(";scopes:"))
+	for _, t := range r.sortedScopeTypes() {
+		h.Write([]byte(strconv.Quote(t)))
+		h.Write([]byte(","))
+	}
+
+	h.Write([]byte(";skipFilter:"))
+	h.Write([]byte(strconv.FormatBool(r.SkipAccessControlFilter)))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// String renders the access resources with dashboards sorted by UID, for stable log output.
+// Only the number of dashboards or folders is rendered when there are more than maxLoggedDashboards.
+func (r *AccessResources) String() string {
+	var b strings.Builder
+
+	writeUIDs(&b, "dashboards", r.Dashboards)
+	if len(r.Folders) > 0 {
+		b.WriteString(" ")
+		writeUIDs(&b, "folders", r.Folders)
+	}
+
+	fmt.Fprintf(&b, " scopes=[%s] skipFilter=%t recursiveQueries=%t",
+		strings.Join(r.sortedScopeTypes(), " "), r.SkipAccessControlFilter, r.RecursiveQueriesUsed)
+
+	return b.String()
+}
+
+func writeUIDs(b *strings.Builder, name string, ids map[string]int64) {
+	if len(ids) > maxLoggedDashboards {
+		fmt.Fprintf(b, "%s=%d", name, len(ids))
+		return
+	}
+
+	fmt.Fprintf(b, "%s=[", name)
+	for i, uid := range sortedUIDs(ids) {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		fmt.Fprintf(b, "%s:%d", uid, ids[uid])
+	}
+	b.WriteString("]")
+}
+
+func sortedUIDs(ids map[string]int64) []string {
+	uids := make([]string, 0, len(ids))
+	for uid := range ids {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	return uids
+}
+
+func (r *AccessResources) sortedScopeTypes() []string {
+	scopeTypes := make([]string, 0, len(r.ScopeTypes))
+	for t := range r.ScopeTypes {
+		scopeTypes = append(scopeTypes, fmt.Sprint(t))
+	}
+	sort.Strings(scopeTypes)
+	return scopeTypes
+}
+
+// ScopeTypeSet is a set of annotation scope types.
+type ScopeTypeSet map[annotations.Type]struct{}
+
+// newScopeTypeSet converts scope types keyed by their string names, as returned by ac.ParseScopes, into a ScopeTypeSet.
+// Unknown scope types are ignored.
+func newScopeTypeSet(scopeTypes map[any]struct{}) ScopeTypeSet {
+	set := make(ScopeTypeSet, len(scopeTypes))
+	for _, t := range []annotations.Type{annotations.Organization, annotations.Dashboard} {
+		if _, ok := scopeTypes[t.String()]; ok {
+			set[t] = struct{}{}
+		}
+	}
+	return set
+}
+
+// Has returns true if the set contains the given scope type.
+func (s ScopeTypeSet) Has(t annotations.Type) bool {
+	_, ok := s[t]
+	return ok
 }
 
 type dashboardProjection struct {
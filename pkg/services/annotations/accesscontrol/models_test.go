@@ -0,0 +1,135 @@
+package accesscontrol
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/annotations"
+)
+
+func TestAccessResources_CacheKey(t *testing.T) {
+	base := func() *AccessResources {
+		return &AccessResources{
+			Dashboards: map[string]int64{"dash1": 1, "dash2": 2, "dash3": 3},
+			ScopeTypes: map[any]struct{}{dashScopeType: {}, orgScopeType: {}},
+		}
+	}
+
+	t.Run("should be stable across map ordering", func(t *testing.T) {
+		expected := base().CacheKey()
+		for i := 0; i < 50; i++ {
+			require.Equal(t, expected, base().CacheKey())
+		}
+
+		reordered := &AccessResources{
+			Dashboards: map[string]int64{},
+			ScopeTypes: map[any]struct{}{},
+		}
+		reordered.Dashboards["dash3"] = 3
+		reordered.Dashboards["dash1"] = 1
+		reordered.Dashboards["dash2"] = 2
+		reordered.ScopeTypes[orgScopeType] = struct{}{}
+		reordered.ScopeTypes[dashScopeType] = struct{}{}
+		require.Equal(t, expected, reordered.CacheKey())
+	})
+
+	t.Run("should change when the dashboard set changes", func(t *testing.T) {
+		expected := base().CacheKey()
+
+		added := base()
+		added.Dashboards["dash4"] = 4
+		require.NotEqual(t, expected, added.CacheKey())
+
+		removed := base()
+		delete(removed.Dashboards, "dash1")
+		require.NotEqual(t, expected, removed.CacheKey())
+
+		changedID := base()
+		changedID.Dashboards["dash1"] = 10
+		require.NotEqual(t, expected, changedID.CacheKey())
+	})
+
+	t.Run("should change when the scope types change", func(t *testing.T) {
+		expected := base().CacheKey()
+
+		orgOnly := base()
+		delete(orgOnly.ScopeTypes, dashScopeType)
+		require.NotEqual(t, expected, orgOnly.CacheKey())
+	})
+
+	t.Run("should change when the folder set changes", func(t *testing.T) {
+		expected := base().CacheKey()
+
+		withFolder := base()
+		withFolder.Folders = map[string]int64{"folder1": 10}
+		require.NotEqual(t, expected, withFolder.CacheKey())
+	})
+
+	t.Run("should treat nil and empty dashboards the same", func(t *testing.T) {
+		require.Equal(t, (&AccessResources{}).CacheKey(), (&AccessResources{Dashboards: map[string]int64{}}).CacheKey())
+		require.NotEqual(t, (&AccessResources{}).CacheKey(), base().CacheKey())
+	})
+}
+
+func TestAccessResources_String(t *testing.T) {
+	t.Run("should render dashboards sorted by UID", func(t *testing.T) {
+		resources := &AccessResources{
+			Dashboards:           map[string]int64{"dash3": 3, "dash1": 1, "dash2": 2},
+			ScopeTypes:           map[any]struct{}{orgScopeType: {}, dashScopeType: {}},
+			RecursiveQueriesUsed: true,
+		}
+
+		expected := "dashboards=[dash1:1 dash2:2 dash3:3] scopes=[dashboard organization] skipFilter=false recursiveQueries=true"
+		for i := 0; i < 50; i++ {
+			require.Equal(t, expected, resources.String())
+		}
+	})
+
+	t.Run("should render folders sorted by UID", func(t *testing.T) {
+		resources := &AccessResources{
+			Dashboards: map[string]int64{"dash1": 1},
+			Folders:    map[string]int64{"folder2": 20, "folder1": 10},
+		}
+
+		require.Equal(t, "dashboards=[dash1:1] folders=[folder1:10 folder2:20] scopes=[] skipFilter=false recursiveQueries=false", resources.String())
+	})
+
+	t.Run("should render empty resources", func(t *testing.T) {
+		require.Equal(t, "dashboards=[] scopes=[] skipFilter=false recursiveQueries=false", (&AccessResources{}).String())
+	})
+
+	t.Run("should only render the count of dashboards above the threshold", func(t *testing.T) {
+		resources := &AccessResources{Dashboards: map[string]int64{}, SkipAccessControlFilter: true}
+		for i := 0; i <= maxLoggedDashboards; i++ {
+			resources.Dashboards[fmt.Sprintf("dash%d", i)] = int64(i)
+		}
+
+		require.Equal(t, fmt.Sprintf("dashboards=%d scopes=[] skipFilter=true recursiveQueries=false", maxLoggedDashboards+1), resources.String())
+	})
+}
+
+func TestScopeTypeSet(t *testing.T) {
+	t.Run("should contain the scope types parsed from scopes", func(t *testing.T) {
+		set := newScopeTypeSet(map[any]struct{}{dashScopeType: {}})
+		require.True(t, set.Has(annotations.Dashboard))
+		require.False(t, set.Has(annotations.Organization))
+	})
+
+	t.Run("should contain all scope types for all annotation scope types", func(t *testing.T) {
+		set := newScopeTypeSet(allAnnotationScopeTypes())
+		require.True(t, set.Has(annotations.Dashboard))
+		require.True(t, set.Has(annotations.Organization))
+	})
+
+	t.Run("should ignore unknown scope types", func(t *testing.T) {
+		set := newScopeTypeSet(map[any]struct{}{"folder": {}})
+		require.Empty(t, set)
+	})
+
+	t.Run("should be empty for a nil set", func(t *testing.T) {
+		var set ScopeTypeSet
+		require.False(t, set.Has(annotations.Dashboard))
+	})
+}
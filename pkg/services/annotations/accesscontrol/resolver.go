@@ -0,0 +1,311 @@
+package accesscontrol
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/metrics"
+	"github.com/grafana/grafana/pkg/services/auth/identity"
+	"github.com/grafana/grafana/pkg/services/dashboards/dashboardaccess"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/sqlstore/permissions"
+	"github.com/grafana/grafana/pkg/services/sqlstore/searchstore"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+const defaultDashboardPageSize = 1000
+
+// VisibleDashboardsResolver resolves the dashboards and folders a user has a permission on, which limit the
+// dashboard annotations the user has access to.
+type VisibleDashboardsResolver interface {
+	// VisibleDashboards returns the UIDs mapped to IDs of the dashboards of the org the user has the permission on,
+	// limited to dashboardUIDs when given, and whether recursive queries were used to resolve them.
+	VisibleDashboards(ctx context.Context, user identity.Requester, orgID int64, permission dashboardaccess.PermissionType, dashboardUIDs []string) (map[string]int64, bool, error)
+	// VisibleFolders returns the UIDs mapped to IDs of the folders of the org the user has the permission on.
+	VisibleFolders(ctx context.Context, user identity.Requester, orgID int64, permission dashboardaccess.PermissionType) (map[string]int64, error)
+}
+
+// dashboardSearchResolver is the default VisibleDashboardsResolver, searching the database with the
+// dashboard permission filters.
+type dashboardSearchResolver struct {
+	db       db.DB
+	features featuremgmt.FeatureToggles
+	// dashboardPageSize is the number of dashboards fetched per page when resolving the dashboards visible to a user
+	dashboardPageSize int64
+	// dashboardConcurrency is the number of pages fetched concurrently when resolving the dashboards visible to a user
+	dashboardConcurrency int
+	// dashboardQueryTimeout bounds each page query when resolving the dashboards visible to a user, no timeout if zero
+	dashboardQueryTimeout time.Duration
+	// recursiveQueries is whether the database supports recursive queries when it was looked up once for a batch,
+	// nil when it is looked up on every call
+	recursiveQueries *bool
+}
+
+// batchResolver is implemented by the resolvers that can share setup across the users of an AuthorizeBatch call.
+type batchResolver interface {
+	// batch returns a resolver for the users of one batch.
+	batch() (VisibleDashboardsResolver, error)
+}
+
+func newDashboardSearchResolver(db db.DB, features featuremgmt.FeatureToggles, cfg *setting.Cfg) *dashboardSearchResolver {
+	return &dashboardSearchResolver{
+		db:                    db,
+		features:              features,
+		dashboardPageSize:     cfg.AnnotationDashboardPageSize,
+		dashboardConcurrency:  cfg.AnnotationDashboardConcurrency,
+		dashboardQueryTimeout: cfg.AnnotationDashboardQueryTimeout,
+	}
+}
+
+// batch returns a copy of the resolver looking up whether recursive queries are supported only once.
+func (r *dashboardSearchResolver) batch() (VisibleDashboardsResolver, error) {
+	recursiveQueriesSupported, err := r.db.RecursiveQueriesAreSupported()
+	if err != nil {
+		return nil, err
+	}
+
+	batch := *r
+	batch.recursiveQueries = &recursiveQueriesSupported
+	return &batch, nil
+}
+
+func (r *dashboardSearchResolver) recursiveQueriesSupported() (bool, error) {
+	if r.recursiveQueries != nil {
+		return *r.recursiveQueries, nil
+	}
+
+	return r.db.RecursiveQueriesAreSupported()
+}
+
+// VisibleDashboards returns the dashboards the user has the given permission on and whether recursive queries were used to resolve them.
+// The duration, the number of pages fetched and the number of dashboards found are recorded by org bucket.
+func (r *dashboardSearchResolver) VisibleDashboards(ctx context.Context, user identity.Requester, orgID int64, permission dashboardaccess.PermissionType, dashboardUIDs []string) (map[string]int64, bool, error) {
+	start := time.Now()
+	orgLabel := orgBucket(orgID)
+	defer func() {
+		metrics.MAnnotationsAuthzDuration.WithLabelValues(orgLabel).Observe(time.Since(start).Seconds())
+	}()
+
+	recursiveQueriesSupported, err := r.recursiveQueriesSupported()
+	if err != nil {
+		return nil, false, err
+	}
+
+	filters := []any{
+		permissions.NewAccessControlDashboardPermissionFilter(user, permission, searchstore.TypeDashboard, r.features, recursiveQueriesSupported, permissionFilterOptions(user)...),
+		searchstore.OrgFilter{OrgId: orgID},
+	}
+
+	if len(dashboardUIDs) > 0 {
+		filters = append(filters, searchstore.DashboardFilter{UIDs: dashboardUIDs})
+	}
+
+	visibleDashboards, pages, err := r.search(ctx, filters, dashboardUIDs)
+	if err != nil {
+		return nil, false, err
+	}
+
+	metrics.MAnnotationsAuthzPages.WithLabelValues(orgLabel).Observe(float64(pages))
+	metrics.MAnnotationsAuthzDashboards.WithLabelValues(orgLabel).Observe(float64(len(visibleDashboards)))
+
+	return visibleDashboards, recursiveQueriesSupported, nil
+}
+
+// VisibleFolders returns the folders the user has the given permission on, so that annotations of dashboards
+// within them can be matched.
+func (r *dashboardSearchResolver) VisibleFolders(ctx context.Context, user identity.Requester, orgID int64, permission dashboardaccess.PermissionType) (map[string]int64, error) {
+	recursiveQueriesSupported, err := r.recursiveQueriesSupported()
+	if err != nil {
+		return nil, err
+	}
+
+	filters := []any{
+		permissions.NewAccessControlDashboardPermissionFilter(user, permission, searchstore.TypeFolder, r.features, recursiveQueriesSupported, permissionFilterOptions(user)...),
+		searchstore.OrgFilter{OrgId: orgID},
+	}
+
+	visibleFolders, _, err := r.search(ctx, filters, nil)
+	return visibleFolders, err
+}
+
+// permissionFilterOptions returns the options of the dashboard permission filter for the user. The permissions of
+// pre-resolved scopes are self-contained.
+func permissionFilterOptions(user identity.Requester) []permissions.FilterOption {
+	if _, ok := user.(*scopesRequester); ok {
+		return []permissions.FilterOption{permissions.WithSelfContainedPermissions()}
+	}
+	return nil
+}
+
+// orgBucket groups org IDs by order of magnitude, to label metrics without one series per org.
+func orgBucket(orgID int64) string {
+	if orgID <= 1 {
+		return strconv.FormatInt(orgID, 10)
+	}
+
+	lower := int64(1)
+	for lower*10 <= orgID {
+		lower *= 10
+	}
+	if lower == 1 {
+		return "2-9"
+	}
+	return strconv.FormatInt(lower, 10) + "-" + strconv.FormatInt(lower*10-1, 10)
+}
+
+// search pages through the dashboards matching the filters and returns their UIDs mapped to their IDs, and the
+// number of pages fetched. Pages are fetched in batches of dashboardConcurrency pages queried concurrently. Once a
+// page comes back short, the later pages of the batch are not launched, or canceled, and not counted.
+// When targetUIDs are given, paging stops as soon as all of them are found instead of fetching the end of the results.
+func (r *dashboardSearchResolver) search(ctx context.Context, filters []any, targetUIDs []string) (map[string]int64, int, error) {
+	sb := &searchstore.Builder{Dialect: r.db.GetDialect(), Filters: filters, Features: r.features}
+
+	found := make(map[string]int64)
+	pages := 0
+
+	limit := r.dashboardPageSize
+	if limit <= 0 {
+		limit = defaultDashboardPageSize
+	}
+	concurrency := max(r.dashboardConcurrency, 1)
+
+	for page := int64(1); ; page += int64(concurrency) {
+		// stop paging once the request is gone, e.g. when the client disconnects
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		// each page of the batch is written to its own slot and merged once the batch is done
+		results := make([][]dashboardProjection, concurrency)
+		g, gctx := errgroup.WithContext(ctx)
+
+		cancels := make([]context.CancelFunc, concurrency)
+		pageCtxs := make([]context.Context, concurrency)
+		for i := range pageCtxs {
+			pageCtxs[i], cancels[i] = context.WithCancel(gctx)
+		}
+
+		// end is the number of pages of the batch up to the first short page, the later pages are not needed
+		var mu sync.Mutex
+		end := concurrency
+		for i := range results {
+			i := i
+			sql, params := sb.ToSQL(limit, page+int64(i))
+			g.Go(func() error {
+				defer cancels[i]()
+
+				mu.Lock()
+				skip := i >= end
+				mu.Unlock()
+				if skip {
+					return nil
+				}
+
+				queryCtx := pageCtxs[i]
+				if r.dashboardQueryTimeout > 0 {
+					var cancel context.CancelFunc
+					queryCtx, cancel = context.WithTimeout(queryCtx, r.dashboardQueryTimeout)
+					defer cancel()
+				}
+
+				var res []dashboardProjection
+				err := r.db.WithDbSession(queryCtx, func(sess *db.Session) error {
+					return sess.SQL(sql, params...).Find(&res)
+				})
+
+				mu.Lock()
+				defer mu.Unlock()
+				// an earlier page came back short while this one was queried
+				if i >= end {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+
+				results[i] = res
+				// if the result is less than the limit, we have reached the end
+				if len(res) < int(limit) {
+					end = i + 1
+					for _, cancel := range cancels[end:] {
+						cancel()
+					}
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, 0, err
+		}
+		pages += end
+
+		for _, res := range results[:end] {
+			for _, p := range res {
+				found[p.UID] = p.ID
+			}
+		}
+		if end < concurrency || len(results[end-1]) < int(limit) || foundAll(found, targetUIDs) {
+			break
+		}
+	}
+
+	return found, pages, nil
+}
+
+// foundAll returns true when all the targetUIDs are found, false when there are none.
+func foundAll(found map[string]int64, targetUIDs []string) bool {
+	if len(targetUIDs) == 0 {
+		return false
+	}
+	for _, uid := range targetUIDs {
+		if _, ok := found[uid]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// externalAuthzResolver resolves with an external authorization service when the annotationsExternalAuthz feature
+// toggle is enabled, and with the fallback resolver otherwise. The toggle is checked on every call.
+type externalAuthzResolver struct {
+	features featuremgmt.FeatureToggles
+	external VisibleDashboardsResolver
+	fallback VisibleDashboardsResolver
+}
+
+func (r *externalAuthzResolver) resolver(ctx context.Context) VisibleDashboardsResolver {
+	if r.features.IsEnabled(ctx, featuremgmt.FlagAnnotationsExternalAuthz) {
+		return r.external
+	}
+	return r.fallback
+}
+
+func (r *externalAuthzResolver) VisibleDashboards(ctx context.Context, user identity.Requester, orgID int64, permission dashboardaccess.PermissionType, dashboardUIDs []string) (map[string]int64, bool, error) {
+	return r.resolver(ctx).VisibleDashboards(ctx, user, orgID, permission, dashboardUIDs)
+}
+
+func (r *externalAuthzResolver) VisibleFolders(ctx context.Context, user identity.Requester, orgID int64, permission dashboardaccess.PermissionType) (map[string]int64, error) {
+	return r.resolver(ctx).VisibleFolders(ctx, user, orgID, permission)
+}
+
+// batch returns a copy of the resolver with the batch resolvers of the external and fallback resolvers, when they have one.
+func (r *externalAuthzResolver) batch() (VisibleDashboardsResolver, error) {
+	batch := *r
+	for _, resolver := range []*VisibleDashboardsResolver{&batch.external, &batch.fallback} {
+		b, ok := (*resolver).(batchResolver)
+		if !ok {
+			continue
+		}
+		batched, err := b.batch()
+		if err != nil {
+			return nil, err
+		}
+		*resolver = batched
+	}
+	return &batch, nil
+}
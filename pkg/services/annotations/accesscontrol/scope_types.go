@@ -0,0 +1,47 @@
+package accesscontrol
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/services/annotations"
+)
+
+// ScopeTypeHandler describes an annotation scope type, such as `dashboard` for the `annotations:type:dashboard` scope.
+type ScopeTypeHandler struct {
+	// Name is the scope type as it appears in the annotation scopes
+	Name string
+	// GrantedByWildcard is true when the wildcard annotation scope grants the scope type
+	GrantedByWildcard bool
+}
+
+var scopeTypeRegistry = struct {
+	sync.RWMutex
+	handlers map[string]ScopeTypeHandler
+}{handlers: map[string]ScopeTypeHandler{}}
+
+func init() {
+	RegisterScopeType(ScopeTypeHandler{Name: annotations.Dashboard.String(), GrantedByWildcard: true})
+	RegisterScopeType(ScopeTypeHandler{Name: annotations.Organization.String(), GrantedByWildcard: true})
+}
+
+// RegisterScopeType registers an annotation scope type, replacing the one registered with the same name.
+// Only registered scope types are recognized in the annotation scopes of a user.
+func RegisterScopeType(handler ScopeTypeHandler) {
+	scopeTypeRegistry.Lock()
+	defer scopeTypeRegistry.Unlock()
+	scopeTypeRegistry.handlers[handler.Name] = handler
+}
+
+// registeredScopeTypes returns the registered scope types sorted by name.
+func registeredScopeTypes() []ScopeTypeHandler {
+	scopeTypeRegistry.RLock()
+	defer scopeTypeRegistry.RUnlock()
+
+	handlers := make([]ScopeTypeHandler, 0, len(scopeTypeRegistry.handlers))
+	for _, handler := range scopeTypeRegistry.handlers {
+		handlers = append(handlers, handler)
+	}
+	sort.Slice(handlers, func(i, j int) bool { return handlers[i].Name < handlers[j].Name })
+	return handlers
+}
@@ -0,0 +1,40 @@
+package accesscontrol
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ac "github.com/grafana/grafana/pkg/services/accesscontrol"
+)
+
+func TestAnnotationScopeTypes(t *testing.T) {
+	registered := maps.Clone(scopeTypeRegistry.handlers)
+	t.Cleanup(func() { scopeTypeRegistry.handlers = registered })
+
+	RegisterScopeType(ScopeTypeHandler{Name: "custom", GrantedByWildcard: true})
+	RegisterScopeType(ScopeTypeHandler{Name: "explicit"})
+
+	scope := func(scopeType string) string {
+		return ac.ScopeAnnotationsProvider.GetResourceScopeType(scopeType)
+	}
+
+	t.Run("should recognize a registered scope type", func(t *testing.T) {
+		require.Equal(t, map[any]struct{}{"custom": {}, dashScopeType: {}},
+			annotationScopeTypes([]string{scope("custom"), scope(dashScopeType)}))
+	})
+
+	t.Run("should ignore unregistered scope types", func(t *testing.T) {
+		require.Empty(t, annotationScopeTypes([]string{scope("unknown")}))
+	})
+
+	t.Run("should grant the scope types registered for the wildcard", func(t *testing.T) {
+		require.Equal(t, map[any]struct{}{"custom": {}, dashScopeType: {}, orgScopeType: {}},
+			annotationScopeTypes([]string{ac.ScopeAnnotationsAll}))
+	})
+
+	t.Run("should keep the scope types registered without the wildcard explicit", func(t *testing.T) {
+		require.Equal(t, map[any]struct{}{"explicit": {}}, annotationScopeTypes([]string{scope("explicit")}))
+	})
+}
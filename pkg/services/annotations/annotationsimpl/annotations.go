@@ -7,6 +7,7 @@ import (
 
 	"github.com/grafana/grafana/pkg/infra/db"
 	"github.com/grafana/grafana/pkg/infra/log"
+	ac "github.com/grafana/grafana/pkg/services/accesscontrol"
 	"github.com/grafana/grafana/pkg/services/annotations"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/services/tag"
@@ -25,13 +26,14 @@ func ProvideService(
 	cfg *setting.Cfg,
 	features featuremgmt.FeatureToggles,
 	tagService tag.Service,
+	acService ac.Service,
 ) *RepositoryImpl {
 	l := log.New("annotations")
 
 	return &RepositoryImpl{
 		db:       db,
 		features: features,
-		authZ:    accesscontrol.NewAuthService(db, features),
+		authZ:    accesscontrol.NewAuthService(db, features, cfg, accesscontrol.WithAnonymousPermissions(acService)),
 		store:    NewXormStore(cfg, l, db, tagService),
 	}
 }
@@ -51,7 +53,12 @@ func (r *RepositoryImpl) Update(ctx context.Context, item *annotations.Item) err
 }
 
 func (r *RepositoryImpl) Find(ctx context.Context, query *annotations.ItemQuery) ([]*annotations.ItemDTO, error) {
-	resources, err := r.authZ.Authorize(ctx, query.OrgID, query.SignedInUser)
+	var dashboardUIDs []string
+	if query.DashboardUID != "" {
+		dashboardUIDs = []string{query.DashboardUID}
+	}
+
+	resources, err := r.authZ.Authorize(ctx, query.OrgID, query.SignedInUser, dashboardUIDs...)
 	if err != nil {
 		return make([]*annotations.ItemDTO, 0), err
 	}
@@ -15,6 +15,7 @@ import (
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/services/annotations"
 	"github.com/grafana/grafana/pkg/services/auth/identity"
+	"github.com/grafana/grafana/pkg/services/folder"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/services/tag"
 	"github.com/grafana/grafana/pkg/setting"
@@ -342,11 +343,13 @@ func (r *xormRepositoryImpl) Get(ctx context.Context, query *annotations.ItemQue
 			}
 		}
 
-		acFilter, err := r.getAccessControlFilter(query.SignedInUser, accessResources)
-		if err != nil {
-			return err
+		if !accessResources.SkipAccessControlFilter {
+			acFilter, err := r.getAccessControlFilter(query.SignedInUser, accessResources)
+			if err != nil {
+				return err
+			}
+			sql.WriteString(fmt.Sprintf(" AND (%s)", acFilter))
 		}
-		sql.WriteString(fmt.Sprintf(" AND (%s)", acFilter))
 
 		if query.Limit == 0 {
 			query.Limit = 100
@@ -374,29 +377,59 @@ func (r *xormRepositoryImpl) getAccessControlFilter(user identity.Requester, acc
 	}
 
 	if _, has := accessResources.ScopeTypes[annotations.Dashboard.String()]; has {
-		var dashboardIDs []int64
-		for _, id := range accessResources.Dashboards {
-			dashboardIDs = append(dashboardIDs, id)
+		filters = append(filters, fmt.Sprintf("a.dashboard_id IN (%s)", idsInClause(accessResources.Dashboards)))
+
+		// the dashboards within the folders the user has access to, which the dashboards above may not list
+		if len(accessResources.Folders) > 0 {
+			filters = append(filters, folderDashboardsFilters(accessResources.Folders)...)
 		}
+	}
 
-		var inClause string
-		if len(dashboardIDs) == 0 {
-			inClause = "SELECT * FROM (SELECT 0 LIMIT 0) tt" // empty set
-		} else {
-			b := make([]byte, 0, 3*len(dashboardIDs))
+	// none of the scope types of the user match annotations
+	if len(filters) == 0 {
+		return "1 = 0", nil
+	}
 
-			b = strconv.AppendInt(b, dashboardIDs[0], 10)
-			for _, num := range dashboardIDs[1:] {
-				b = append(b, ',')
-				b = strconv.AppendInt(b, num, 10)
-			}
+	return strings.Join(filters, " OR "), nil
+}
 
-			inClause = string(b)
-		}
-		filters = append(filters, fmt.Sprintf("a.dashboard_id IN (%s)", inClause))
+// folderDashboardsFilters returns the filters matching the annotations of the dashboards within the folders, or
+// within their subfolders down to the maximum nesting depth, as folder permissions cascade to subfolders.
+func folderDashboardsFilters(folders map[string]int64) []string {
+	ids := idsInClause(folders)
+	filters := make([]string, 0, folder.MaxNestedFolderDepth)
+	filters = append(filters, fmt.Sprintf("a.dashboard_id IN (SELECT d.id FROM dashboard d WHERE d.folder_id IN (%s))", ids))
+
+	joins := make([]string, 0, folder.MaxNestedFolderDepth-1)
+	prev := "d"
+	for i := 1; i < folder.MaxNestedFolderDepth; i++ {
+		t := fmt.Sprintf("f%d", i)
+		joins = append(joins, fmt.Sprintf("INNER JOIN dashboard %s ON %s.folder_id = %s.id", t, prev, t))
+		filters = append(filters, fmt.Sprintf("a.dashboard_id IN (SELECT d.id FROM dashboard d %s WHERE %s.folder_id IN (%s))", strings.Join(joins, " "), t, ids))
+		prev = t
 	}
 
-	return strings.Join(filters, " OR "), nil
+	return filters
+}
+
+// idsInClause returns the IDs of resources as the list of an IN clause, or a query of an empty set when there are none.
+func idsInClause(resources map[string]int64) string {
+	if len(resources) == 0 {
+		return "SELECT * FROM (SELECT 0 LIMIT 0) tt" // empty set
+	}
+
+	ids := make([]int64, 0, len(resources))
+	for _, id := range resources {
+		ids = append(ids, id)
+	}
+
+	b := make([]byte, 0, 3*len(ids))
+	b = strconv.AppendInt(b, ids[0], 10)
+	for _, num := range ids[1:] {
+		b = append(b, ',')
+		b = strconv.AppendInt(b, num, 10)
+	}
+	return string(b)
 }
 
 func (r *xormRepositoryImpl) Delete(ctx context.Context, params *annotations.DeleteParams) error {
@@ -163,6 +163,21 @@ func TestIntegrationAnnotations(t *testing.T) {
 			assert.Equal(t, items[0].Updated, items[0].Created)
 		})
 
+		t.Run("Can query for all annotations when the access control filter is skipped", func(t *testing.T) {
+			items, err := store.Get(context.Background(), &annotations.ItemQuery{
+				OrgID:        1,
+				From:         0,
+				To:           25,
+				SignedInUser: testUser,
+			}, &annotation_ac.AccessResources{
+				ScopeTypes:              map[any]struct{}{dashScopeType: {}, orgScopeType: {}},
+				SkipAccessControlFilter: true,
+			})
+
+			require.NoError(t, err)
+			assert.Len(t, items, 4)
+		})
+
 		badAnnotation := &annotations.Item{
 			OrgID:  1,
 			UserID: 1,
@@ -669,3 +684,109 @@ func benchmarkFindTags(b *testing.B, numAnnotations int) {
 		require.Equal(b, int64(1), result.Tags[1].Count)
 	}
 }
+
+func TestIntegrationAnnotations_FolderAccess(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	sql := db.InitTestDB(t)
+
+	cfg := setting.NewCfg()
+	cfg.AnnotationMaximumTagsLength = 60
+	store := NewXormStore(cfg, log.New("annotation.test"), sql, tagimpl.ProvideService(sql))
+
+	folder := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID:   1,
+		OrgID:    1,
+		IsFolder: true,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Folder 1",
+		}),
+	})
+	dashboard := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard in folder 1",
+		}),
+		FolderID:  folder.ID, // nolint:staticcheck
+		FolderUID: folder.UID,
+	})
+	subfolder := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID:   1,
+		OrgID:    1,
+		IsFolder: true,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Subfolder of folder 1",
+		}),
+		FolderID:  folder.ID, // nolint:staticcheck
+		FolderUID: folder.UID,
+	})
+	nestedSubfolder := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID:   1,
+		OrgID:    1,
+		IsFolder: true,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Subfolder of the subfolder",
+		}),
+		FolderID:  subfolder.ID, // nolint:staticcheck
+		FolderUID: subfolder.UID,
+	})
+	nestedDashboard := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard in the nested subfolder",
+		}),
+		FolderID:  nestedSubfolder.ID, // nolint:staticcheck
+		FolderUID: nestedSubfolder.UID,
+	})
+	otherDashboard := testutil.CreateDashboard(t, sql, featuremgmt.WithFeatures(), dashboards.SaveDashboardCommand{
+		UserID: 1,
+		OrgID:  1,
+		Dashboard: simplejson.NewFromAny(map[string]any{
+			"title": "Dashboard in the general folder",
+		}),
+	})
+
+	for _, dashboardID := range []int64{dashboard.ID, nestedDashboard.ID, otherDashboard.ID} {
+		err := store.Add(context.Background(), &annotations.Item{OrgID: 1, DashboardID: dashboardID, Epoch: 10})
+		require.NoError(t, err)
+	}
+
+	query := &annotations.ItemQuery{OrgID: 1, SignedInUser: &user.SignedInUser{OrgID: 1}}
+
+	t.Run("should find the annotations of the dashboards within the folders and their subfolders", func(t *testing.T) {
+		items, err := store.Get(context.Background(), query, &annotation_ac.AccessResources{
+			Dashboards: map[string]int64{},
+			Folders:    map[string]int64{folder.UID: folder.ID},
+			ScopeTypes: map[any]struct{}{dashScopeType: {}},
+		})
+		require.NoError(t, err)
+		dashboardIDs := make([]int64, 0, len(items))
+		for _, item := range items {
+			dashboardIDs = append(dashboardIDs, item.DashboardID)
+		}
+		require.ElementsMatch(t, []int64{dashboard.ID, nestedDashboard.ID}, dashboardIDs)
+	})
+
+	t.Run("should not find the annotations of the dashboards within the parent folders", func(t *testing.T) {
+		items, err := store.Get(context.Background(), query, &annotation_ac.AccessResources{
+			Dashboards: map[string]int64{},
+			Folders:    map[string]int64{nestedSubfolder.UID: nestedSubfolder.ID},
+			ScopeTypes: map[any]struct{}{dashScopeType: {}},
+		})
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		require.Equal(t, nestedDashboard.ID, items[0].DashboardID)
+	})
+
+	t.Run("should not match the folders without the dashboard scope type", func(t *testing.T) {
+		items, err := store.Get(context.Background(), query, &annotation_ac.AccessResources{
+			Folders:    map[string]int64{folder.UID: folder.ID},
+			ScopeTypes: map[any]struct{}{orgScopeType: {}},
+		})
+		require.NoError(t, err)
+		require.Empty(t, items)
+	})
+}
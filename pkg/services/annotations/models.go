@@ -112,6 +112,9 @@ type ItemDTO struct {
 
 type annotationType int
 
+// Type is the type of an annotation, either Organization or Dashboard.
+type Type = annotationType
+
 const (
 	Organization annotationType = iota
 	Dashboard
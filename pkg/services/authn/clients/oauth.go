@@ -28,6 +28,7 @@ const (
 	codeChallengeParamName       = "code_challenge"
 	codeChallengeMethodParamName = "code_challenge_method"
 	codeChallengeMethod          = "S256"
+	loginHintQueryName           = "login_hint"
 
 	oauthStateQueryName  = "state"
 	oauthStateCookieName = "oauth_state"
@@ -108,6 +109,13 @@ func (c *OAuth) Authenticate(ctx context.Context, r *authn.Request) (*authn.Iden
 		opts = append(opts, oauth2.SetAuthURLParam(codeVerifierParamName, pkceCookie.Value))
 	}
 
+	// forward the correlation ID of the login request to the IdP, a new one is generated when there is none
+	if header := c.oauthCfg.Extra["correlation_header"]; header != "" {
+		if id := r.HTTPRequest.Header.Get(header); id != "" {
+			ctx = social.WithCorrelationID(ctx, id)
+		}
+	}
+
 	clientCtx := context.WithValue(ctx, oauth2.HTTPClient, c.httpClient)
 	// exchange auth code to a valid token
 	token, err := c.connector.Exchange(clientCtx, r.HTTPRequest.URL.Query().Get("code"), opts...)
@@ -118,6 +126,10 @@ func (c *OAuth) Authenticate(ctx context.Context, r *authn.Request) (*authn.Iden
 
 	userInfo, err := c.connector.UserInfo(ctx, c.connector.Client(clientCtx, token), token)
 	if err != nil {
+		if reason, ok := social.GetDenialReason(err); ok {
+			c.log.FromContext(ctx).Info("Login denied by provider", "reason", reason, "error", err)
+		}
+
 		var sErr *social.Error
 		if errors.As(err, &sErr) {
 			return nil, fromSocialErr(sErr)
@@ -175,6 +187,13 @@ func (c *OAuth) RedirectURL(ctx context.Context, r *authn.Request) (*authn.Redir
 		opts = append(opts, oauth2.SetAuthURLParam(hostedDomainParamName, c.oauthCfg.HostedDomain))
 	}
 
+	// forward the login hint of the login request, e.g. the email of the user signing in again
+	if r != nil && r.HTTPRequest != nil {
+		if hint := r.HTTPRequest.URL.Query().Get(loginHintQueryName); hint != "" {
+			opts = append(opts, social.LoginHint(hint))
+		}
+	}
+
 	var plainPKCE string
 	if c.oauthCfg.UsePKCE {
 		pkce, hashedPKCE, err := genPKCECode()
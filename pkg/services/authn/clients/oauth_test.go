@@ -2,6 +2,8 @@ package clients
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"net/http"
 	"net/url"
 	"testing"
@@ -243,6 +245,7 @@ func TestOAuth_RedirectURL(t *testing.T) {
 	type testCase struct {
 		desc        string
 		oauthCfg    *social.OAuthInfo
+		req         *authn.Request
 		expectedErr error
 
 		numCallOptions    int
@@ -267,6 +270,15 @@ func TestOAuth_RedirectURL(t *testing.T) {
 			numCallOptions:    2,
 			authCodeUrlCalled: true,
 		},
+		{
+			desc:     "should generate redirect url with the login hint of the login request",
+			oauthCfg: &social.OAuthInfo{},
+			req: &authn.Request{HTTPRequest: &http.Request{
+				URL: &url.URL{RawQuery: "login_hint=octopus%40grafana.com"},
+			}},
+			numCallOptions:    1,
+			authCodeUrlCalled: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -283,7 +295,7 @@ func TestOAuth_RedirectURL(t *testing.T) {
 				},
 			}, nil)
 
-			redirect, err := c.RedirectURL(context.Background(), nil)
+			redirect, err := c.RedirectURL(context.Background(), tt.req)
 			assert.ErrorIs(t, err, tt.expectedErr)
 			assert.Equal(t, tt.authCodeUrlCalled, authCodeUrlCalled)
 
@@ -299,6 +311,24 @@ func TestOAuth_RedirectURL(t *testing.T) {
 	}
 }
 
+func TestGenPKCECode(t *testing.T) {
+	verifier, challenge, err := genPKCECode()
+	require.NoError(t, err)
+
+	// RFC 7636 requires a verifier of 43-128 unreserved characters
+	assert.GreaterOrEqual(t, len(verifier), 43)
+	assert.LessOrEqual(t, len(verifier), 128)
+	assert.Regexp(t, `^[A-Za-z0-9\-._~]+$`, verifier)
+
+	// S256: the challenge is the base64url encoded SHA-256 of the verifier
+	shasum := sha256.Sum256([]byte(verifier))
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(shasum[:]), challenge)
+
+	other, _, err := genPKCECode()
+	require.NoError(t, err)
+	assert.NotEqual(t, verifier, other)
+}
+
 type mockConnector struct {
 	AuthCodeURLFunc func(state string, opts ...oauth2.AuthCodeOption) string
 	social.SocialConnector
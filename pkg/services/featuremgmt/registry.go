@@ -1068,6 +1068,13 @@ var (
 			FrontendOnly: false,
 			Owner:        grafanaPluginsPlatformSquad,
 		},
+		{
+			Name:         "annotationsExternalAuthz",
+			Description:  "Resolves the dashboards visible to a user for annotation access control with an external authorization service",
+			Stage:        FeatureStageExperimental,
+			FrontendOnly: false,
+			Owner:        identityAccessTeam,
+		},
 	}
 )
 
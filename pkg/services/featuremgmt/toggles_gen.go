@@ -586,4 +586,8 @@ const (
 	// FlagPluginsSkipHostEnvVars
 	// Disables passing host environment variable to plugin processes
 	FlagPluginsSkipHostEnvVars = "pluginsSkipHostEnvVars"
+
+	// FlagAnnotationsExternalAuthz
+	// Resolves the dashboards visible to a user for annotation access control with an external authorization service
+	FlagAnnotationsExternalAuthz = "annotationsExternalAuthz"
 )
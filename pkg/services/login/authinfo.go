@@ -41,6 +41,8 @@ const (
 	GrafanaComAuthModule = "oauth_grafana_com"
 	GrafanaNetAuthModule = "oauth_grafananet"
 	OktaAuthModule       = "oauth_okta"
+	DiscordAuthModule    = "oauth_discord"
+	StubAuthModule       = "oauth_stub"
 
 	// labels
 	SAMLLabel = "SAML"
@@ -55,6 +57,8 @@ const (
 	GithubLabel       = "GitHub"
 	GrafanaComLabel   = "grafana.com"
 	OktaLabel         = "Okta"
+	DiscordLabel      = "Discord"
+	StubLabel         = "Stub"
 )
 
 // IsExternnalySynced is used to tell if the user roles are externally synced
@@ -88,6 +92,10 @@ func IsExternallySynced(cfg *setting.Cfg, authModule string) bool {
 		return !cfg.GoogleSkipOrgRoleSync
 	case OktaAuthModule:
 		return !cfg.OktaSkipOrgRoleSync
+	case DiscordAuthModule:
+		return !cfg.DiscordSkipOrgRoleSync
+	case StubAuthModule:
+		return !cfg.StubSkipOrgRoleSync
 	case AzureADAuthModule:
 		return !cfg.AzureADSkipOrgRoleSync
 	case GitLabAuthModule:
@@ -134,6 +142,10 @@ func IsProviderEnabled(cfg *setting.Cfg, authModule string) bool {
 		return cfg.GoogleAuthEnabled
 	case OktaAuthModule:
 		return cfg.OktaAuthEnabled
+	case DiscordAuthModule:
+		return cfg.DiscordAuthEnabled
+	case StubAuthModule:
+		return cfg.StubAuthEnabled
 	case AzureADAuthModule:
 		return cfg.AzureADEnabled
 	case GitLabAuthModule:
@@ -161,6 +173,10 @@ func GetAuthProviderLabel(authModule string) string {
 		return GitLabLabel
 	case OktaAuthModule:
 		return OktaLabel
+	case DiscordAuthModule:
+		return DiscordLabel
+	case StubAuthModule:
+		return StubLabel
 	case GrafanaComAuthModule, GrafanaNetAuthModule:
 		return GrafanaComLabel
 	case SAMLAuthModule:
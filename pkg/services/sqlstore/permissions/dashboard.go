@@ -34,6 +34,20 @@ type accessControlDashboardPermissionFilter struct {
 	// any recursive CTE queries (if supported)
 	recQueries                   []clause
 	recursiveQueriesAreSupported bool
+	// selfContainedPermissions is true if the permissions of the user are matched as they are, see WithSelfContainedPermissions
+	selfContainedPermissions bool
+}
+
+// FilterOption configures the filter created by NewAccessControlDashboardPermissionFilter.
+type FilterOption func(*accessControlDashboardPermissionFilter)
+
+// WithSelfContainedPermissions matches the permissions of the user as they are instead of looking up the permissions
+// of their roles in the database, for permissions the caller resolved. This is always the case for users
+// authenticated with the extended JWT module, whose permissions are set from the token.
+func WithSelfContainedPermissions() FilterOption {
+	return func(f *accessControlDashboardPermissionFilter) {
+		f.selfContainedPermissions = true
+	}
 }
 
 type PermissionsFilter interface {
@@ -48,7 +62,7 @@ type PermissionsFilter interface {
 // NewAccessControlDashboardPermissionFilter creates a new AccessControlDashboardPermissionFilter that is configured with specific actions calculated based on the dashboardaccess.PermissionType and query type
 // The filter is configured to use the new permissions filter (without subqueries) if the feature flag is enabled
 // The filter is configured to use the old permissions filter (with subqueries) if the feature flag is disabled
-func NewAccessControlDashboardPermissionFilter(user identity.Requester, permissionLevel dashboardaccess.PermissionType, queryType string, features featuremgmt.FeatureToggles, recursiveQueriesAreSupported bool) PermissionsFilter {
+func NewAccessControlDashboardPermissionFilter(user identity.Requester, permissionLevel dashboardaccess.PermissionType, queryType string, features featuremgmt.FeatureToggles, recursiveQueriesAreSupported bool, opts ...FilterOption) PermissionsFilter {
 	needEdit := permissionLevel > dashboardaccess.PERMISSION_VIEW
 
 	var folderActions []string
@@ -84,18 +98,20 @@ func NewAccessControlDashboardPermissionFilter(user identity.Requester, permissi
 		}
 	}
 
+	base := accessControlDashboardPermissionFilter{user: user, folderActions: folderActions, dashboardActions: dashboardActions, features: features,
+		recursiveQueriesAreSupported: recursiveQueriesAreSupported,
+	}
+	for _, opt := range opts {
+		opt(&base)
+	}
+
 	var f PermissionsFilter
 	if features.IsEnabledGlobally(featuremgmt.FlagPermissionsFilterRemoveSubquery) {
 		f = &accessControlDashboardPermissionFilterNoFolderSubquery{
-			accessControlDashboardPermissionFilter: accessControlDashboardPermissionFilter{
-				user: user, folderActions: folderActions, dashboardActions: dashboardActions, features: features,
-				recursiveQueriesAreSupported: recursiveQueriesAreSupported,
-			},
+			accessControlDashboardPermissionFilter: base,
 		}
 	} else {
-		f = &accessControlDashboardPermissionFilter{user: user, folderActions: folderActions, dashboardActions: dashboardActions, features: features,
-			recursiveQueriesAreSupported: recursiveQueriesAreSupported,
-		}
+		f = &base
 	}
 	f.buildClauses()
 	return f
@@ -139,7 +155,8 @@ func (f *accessControlDashboardPermissionFilter) buildClauses() {
 
 	// useSelfContainedPermissions is true if the user's permissions are stored and set from the JWT token
 	// currently it's used for the extended JWT module (when the user is authenticated via a JWT token generated by Grafana)
-	useSelfContainedPermissions := f.user.GetAuthenticatedBy() == login.ExtendedJWTModule
+	// and when the filter is created WithSelfContainedPermissions
+	useSelfContainedPermissions := f.selfContainedPermissions || f.user.GetAuthenticatedBy() == login.ExtendedJWTModule
 
 	if len(f.dashboardActions) > 0 {
 		toCheck := actionsToCheck(f.dashboardActions, f.user.GetPermissions(), dashWildcards, folderWildcards)
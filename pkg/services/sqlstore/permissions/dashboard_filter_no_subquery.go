@@ -52,7 +52,8 @@ func (f *accessControlDashboardPermissionFilterNoFolderSubquery) buildClauses()
 
 	// useSelfContainedPermissions is true if the user's permissions are stored and set from the JWT token
 	// currently it's used for the extended JWT module (when the user is authenticated via a JWT token generated by Grafana)
-	useSelfContainedPermissions := f.user.GetAuthenticatedBy() == login.ExtendedJWTModule
+	// and when the filter is created WithSelfContainedPermissions
+	useSelfContainedPermissions := f.selfContainedPermissions || f.user.GetAuthenticatedBy() == login.ExtendedJWTModule
 
 	if len(f.dashboardActions) > 0 {
 		toCheck := actionsToCheck(f.dashboardActions, f.user.GetPermissions(), dashWildcards, folderWildcards)
@@ -392,7 +392,16 @@ func TestIntegration_DashboardPermissionFilter_WithSelfContainedPermissions(t *t
 		recursiveQueriesAreSupported, err := store.RecursiveQueriesAreSupported()
 		require.NoError(t, err)
 
-		usr := &user.SignedInUser{OrgID: 1, OrgRole: org.RoleViewer, AuthenticatedBy: login.ExtendedJWTModule, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction(tt.signedInUserPermissions)}}
+		jwtUsr := &user.SignedInUser{OrgID: 1, OrgRole: org.RoleViewer, AuthenticatedBy: login.ExtendedJWTModule, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction(tt.signedInUserPermissions)}}
+		usr := &user.SignedInUser{OrgID: 1, OrgRole: org.RoleViewer, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction(tt.signedInUserPermissions)}}
+		modes := []struct {
+			name string
+			usr  *user.SignedInUser
+			opts []permissions.FilterOption
+		}{
+			{name: "extended JWT", usr: jwtUsr},
+			{name: "option", usr: usr, opts: []permissions.FilterOption{permissions.WithSelfContainedPermissions()}},
+		}
 
 		for _, features := range []*featuremgmt.FeatureManager{featuremgmt.WithFeatures(), featuremgmt.WithFeatures(featuremgmt.FlagPermissionsFilterRemoveSubquery)} {
 			m := features.GetEnabled(context.Background())
@@ -400,26 +409,28 @@ func TestIntegration_DashboardPermissionFilter_WithSelfContainedPermissions(t *t
 			for k := range m {
 				keys = append(keys, k)
 			}
-			t.Run(tt.desc+" with features "+strings.Join(keys, ","), func(t *testing.T) {
-				filter := permissions.NewAccessControlDashboardPermissionFilter(usr, tt.permission, tt.queryType, features, recursiveQueriesAreSupported)
-
-				var result int
-				err = store.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
-					q, params := filter.Where()
-					recQry, recQryParams := filter.With()
-					params = append(recQryParams, params...)
-					s := recQry + "\nSELECT COUNT(*) FROM dashboard WHERE " + q
-					leftJoin := filter.LeftJoin()
-					if leftJoin != "" {
-						s = recQry + "\nSELECT COUNT(*) FROM dashboard LEFT OUTER JOIN " + leftJoin + " WHERE " + q
-					}
-					_, err := sess.SQL(s, params...).Get(&result)
-					return err
+			for _, mode := range modes {
+				t.Run(tt.desc+" with "+mode.name+" and features "+strings.Join(keys, ","), func(t *testing.T) {
+					filter := permissions.NewAccessControlDashboardPermissionFilter(mode.usr, tt.permission, tt.queryType, features, recursiveQueriesAreSupported, mode.opts...)
+
+					var result int
+					err = store.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+						q, params := filter.Where()
+						recQry, recQryParams := filter.With()
+						params = append(recQryParams, params...)
+						s := recQry + "\nSELECT COUNT(*) FROM dashboard WHERE " + q
+						leftJoin := filter.LeftJoin()
+						if leftJoin != "" {
+							s = recQry + "\nSELECT COUNT(*) FROM dashboard LEFT OUTER JOIN " + leftJoin + " WHERE " + q
+						}
+						_, err := sess.SQL(s, params...).Get(&result)
+						return err
+					})
+					require.NoError(t, err)
+
+					assert.Equal(t, tt.expectedResult, result)
 				})
-				require.NoError(t, err)
-
-				assert.Equal(t, tt.expectedResult, result)
-			})
+			}
 		}
 	}
 }
@@ -5,6 +5,8 @@ import (
 	"regexp"
 	"strings"
 
+	"gopkg.in/ini.v1"
+
 	"github.com/grafana/grafana/pkg/services/ssosettings"
 	"github.com/grafana/grafana/pkg/setting"
 )
@@ -45,7 +47,7 @@ func (s *OAuthStrategy) ParseConfigFromSystem(_ context.Context) (map[string]any
 		"email_attribute_name":    section.Key("email_attribute_name").Value(),
 		"email_attribute_path":    section.Key("email_attribute_path").Value(),
 		"role_attribute_path":     section.Key("role_attribute_path").Value(),
-		"role_attribute_strict":   section.Key("role_attribute_strict").MustBool(false),
+		"role_attribute_strict":   roleAttributeStrict(section.Key("role_attribute_strict")),
 		"groups_attribute_path":   section.Key("groups_attribute_path").Value(),
 		"team_ids_attribute_path": section.Key("team_ids_attribute_path").Value(),
 		"allowed_domains":         section.Key("allowed_domains").Value(),
@@ -72,3 +74,11 @@ func (s *OAuthStrategy) ParseConfigFromSystem(_ context.Context) (map[string]any
 	}
 	return result, nil
 }
+
+// roleAttributeStrict returns role_attribute_strict as a bool, or as is for the "highest" and "error" modes.
+func roleAttributeStrict(key *ini.Key) any {
+	if strings.EqualFold(key.Value(), "highest") || strings.EqualFold(key.Value(), "error") {
+		return key.Value()
+	}
+	return key.MustBool(false)
+}
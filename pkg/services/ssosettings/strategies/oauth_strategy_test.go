@@ -0,0 +1,44 @@
+package strategies
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestOAuthStrategy_ParseConfigFromSystem_RoleAttributeStrict(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected any
+	}{
+		{name: "Should default to false", value: "", expected: false},
+		{name: "Should parse a bool", value: "true", expected: true},
+		{name: "Should keep the highest mode", value: "highest", expected: "highest"},
+		{name: "Should keep the error mode", value: "error", expected: "error"},
+		{name: "Should keep the error mode in any case", value: "Error", expected: "Error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iniFile := ini.Empty()
+			section, err := iniFile.NewSection("auth.generic_oauth")
+			require.NoError(t, err)
+			_, err = section.NewKey("role_attribute_strict", tt.value)
+			require.NoError(t, err)
+
+			cfg := setting.NewCfg()
+			cfg.Raw = iniFile
+			strategy := NewOAuthStrategy(cfg)
+			strategy.provider = "generic_oauth"
+
+			result, err := strategy.ParseConfigFromSystem(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result["role_attribute_strict"])
+		})
+	}
+}
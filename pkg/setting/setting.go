@@ -382,6 +382,14 @@ type Cfg struct {
 	// Annotations
 	AnnotationCleanupJobBatchSize      int64
 	AnnotationMaximumTagsLength        int64
+	AnnotationReadBypassRoles          []string
+	AnnotationGrafanaAdminReadAll      bool
+	AnnotationDashboardPageSize        int64
+	AnnotationDashboardConcurrency     int
+	AnnotationDashboardQueryTimeout    time.Duration
+	AnnotationAccessCacheTTL           time.Duration
+	AnnotationReadDashboardPermission  string
+	AnnotationAnonymousRead            bool
 	AlertingAnnotationCleanupSetting   AnnotationCleanupSettings
 	DashboardAnnotationCleanupSettings AnnotationCleanupSettings
 	APIAnnotationCleanupSettings       AnnotationCleanupSettings
@@ -535,6 +543,14 @@ type Cfg struct {
 	OktaAuthEnabled     bool
 	OktaSkipOrgRoleSync bool
 
+	// Discord OAuth
+	DiscordAuthEnabled     bool
+	DiscordSkipOrgRoleSync bool
+
+	// Stub OAuth
+	StubAuthEnabled     bool
+	StubSkipOrgRoleSync bool
+
 	// OAuth2 Server
 	OAuth2ServerEnabled bool
 
@@ -733,6 +749,20 @@ func (cfg *Cfg) readAnnotationSettings() error {
 		cfg.AnnotationMaximumTagsLength = 500
 	}
 
+	cfg.AnnotationReadBypassRoles = util.SplitString(section.Key("read_bypass_roles").MustString(""))
+	cfg.AnnotationGrafanaAdminReadAll = section.Key("grafana_admin_read_all").MustBool(false)
+	cfg.AnnotationDashboardPageSize = section.Key("dashboard_page_size").MustInt64(1000)
+	cfg.AnnotationDashboardConcurrency = section.Key("dashboard_concurrency").MustInt(1)
+	cfg.AnnotationDashboardQueryTimeout = section.Key("dashboard_query_timeout").MustDuration(0)
+	cfg.AnnotationAccessCacheTTL = section.Key("access_cache_ttl").MustDuration(0)
+	cfg.AnnotationReadDashboardPermission = section.Key("read_dashboard_permission").MustString("View")
+	cfg.AnnotationAnonymousRead = section.Key("anonymous_read").MustBool(false)
+	switch cfg.AnnotationReadDashboardPermission {
+	case "View", "Edit", "Admin":
+	default:
+		return fmt.Errorf("[annotations.read_dashboard_permission] must be one of View, Edit or Admin, got %q", cfg.AnnotationReadDashboardPermission)
+	}
+
 	dashboardAnnotation := cfg.Raw.Section("annotations.dashboard")
 	apiIAnnotation := cfg.Raw.Section("annotations.api")
 	alertingSection := cfg.Raw.Section("alerting")
@@ -1550,6 +1580,18 @@ func readAuthOktaSettings(cfg *Cfg) {
 	cfg.OktaSkipOrgRoleSync = sec.Key("skip_org_role_sync").MustBool(false)
 }
 
+func readAuthDiscordSettings(cfg *Cfg) {
+	sec := cfg.SectionWithEnvOverrides("auth.discord")
+	cfg.DiscordAuthEnabled = sec.Key("enabled").MustBool(false)
+	cfg.DiscordSkipOrgRoleSync = sec.Key("skip_org_role_sync").MustBool(false)
+}
+
+func readAuthStubSettings(cfg *Cfg) {
+	sec := cfg.SectionWithEnvOverrides("auth.stub")
+	cfg.StubAuthEnabled = sec.Key("enabled").MustBool(false)
+	cfg.StubSkipOrgRoleSync = sec.Key("skip_org_role_sync").MustBool(false)
+}
+
 func readAuthSettings(iniFile *ini.File, cfg *Cfg) (err error) {
 	auth := iniFile.Section("auth")
 
@@ -1621,6 +1663,12 @@ func readAuthSettings(iniFile *ini.File, cfg *Cfg) (err error) {
 	// Okta Auth
 	readAuthOktaSettings(cfg)
 
+	// Discord Auth
+	readAuthDiscordSettings(cfg)
+
+	// Stub Auth
+	readAuthStubSettings(cfg)
+
 	// GrafanaCom
 	readAuthGrafanaComSettings(cfg)
 	readAuthGrafanaNetSettings(cfg)
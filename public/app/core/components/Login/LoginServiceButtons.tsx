@@ -65,6 +65,12 @@ const loginServices: () => LoginServices = () => {
       name: config.oauth?.okta?.name || 'Okta',
       icon: config.oauth?.okta?.icon || ('okta' as const),
     },
+    stub: {
+      bgColor: '#464646',
+      enabled: oauthEnabled && Boolean(config.oauth.stub),
+      name: config.oauth?.stub?.name || 'Stub',
+      icon: config.oauth?.stub?.icon || ('signin' as const),
+    },
     oauth: {
       bgColor: '#262628',
       enabled: oauthEnabled && Boolean(config.oauth.generic_oauth),